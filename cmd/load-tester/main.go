@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -29,6 +30,13 @@ type LoadTester struct {
 	Client   *http.Client
 	Results  []TestResult
 	mutex    sync.Mutex
+	// ResetStats zeroes the cache server's stats before each test, sending
+	// APIKey when the server requires its admin key
+	ResetStats bool
+	APIKey     string
+	// backpressure tracks whether the cache server last reported
+	// X-Cache-Backpressure, so warnings are logged once per change
+	backpressure atomic.Bool
 }
 
 // NewLoadTester creates a new load tester
@@ -41,9 +49,24 @@ func NewLoadTester(cacheURL, appURL string) *LoadTester {
 	}
 }
 
-// DirectCacheTest tests the cache server directly
-func (lt *LoadTester) DirectCacheTest(concurrency, requests int) {
+// newClient returns a client that keeps up to conns idle connections per
+// host, speaking cleartext HTTP/2 with prior knowledge when h2c is set. The
+// server must run with EnableH2C for h2c requests to succeed.
+func newClient(h2c bool, conns int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = conns
+	if h2c {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// DirectCacheTest tests the cache server directly and returns the
+// requests per second it achieved
+func (lt *LoadTester) DirectCacheTest(concurrency, requests int) float64 {
 	fmt.Printf("🚀 Running direct cache test: %d concurrent workers, %d total requests\n", concurrency, requests)
+	lt.resetServerStats()
 
 	var wg sync.WaitGroup
 	var completed int64
@@ -86,11 +109,156 @@ func (lt *LoadTester) DirectCacheTest(concurrency, requests int) {
 	totalDuration := time.Since(startTime)
 
 	lt.printResults("Direct Cache Test", totalDuration, requests*2)
+	return float64(requests*2) / totalDuration.Seconds()
+}
+
+// ProtocolTest runs the direct cache test over HTTP/1.1 and then over
+// cleartext HTTP/2, and compares their throughput
+func (lt *LoadTester) ProtocolTest(concurrency, requests int) {
+	client := lt.Client
+	defer func() { lt.Client = client }()
+
+	fmt.Println("🔌 HTTP/1.1")
+	lt.Client = newClient(false, concurrency)
+	http1 := lt.DirectCacheTest(concurrency, requests)
+
+	time.Sleep(2 * time.Second)
+
+	fmt.Println("🔌 HTTP/2 (h2c)")
+	lt.Client = newClient(true, concurrency)
+	http2 := lt.DirectCacheTest(concurrency, requests)
+
+	str := strings.Repeat("=", 60)
+	fmt.Printf("\n %v \n", str)
+	fmt.Printf(" Protocol Comparison\n")
+	fmt.Printf("%v\n", str)
+	fmt.Printf("HTTP/1.1:          %.2f req/s\n", http1)
+	fmt.Printf("HTTP/2 (h2c):      %.2f req/s\n", http2)
+	if http1 > 0 {
+		fmt.Printf("Change:            %+.1f%%\n", (http2-http1)/http1*100)
+	}
+}
+
+// TagIntersectionTest stores requests items under three overlapping tags of
+// very different sizes, then times all-tags invalidation previews of their
+// intersection, which the server computes from the smallest tag
+func (lt *LoadTester) TagIntersectionTest(concurrency, requests int) {
+	fmt.Printf("🏷️  Running tag intersection test: %d items, %d concurrent previews\n", requests, concurrency)
+	lt.resetServerStats()
+
+	var wg sync.WaitGroup
+	perWorker := requests / concurrency
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				n := workerID*perWorker + j
+				// Every item is wide, every other one half, every 50th narrow
+				tags := []string{"bench:wide"}
+				if n%2 == 0 {
+					tags = append(tags, "bench:half")
+				}
+				if n%50 == 0 {
+					tags = append(tags, "bench:narrow")
+				}
+				lt.setCacheValue(fmt.Sprintf("tags:item:%d", n), map[string]int{"n": n}, 300, tags)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	previews := 20
+	startTime := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < previews; j++ {
+				start := time.Now()
+				resp, err := lt.Client.Get(lt.CacheURL + "/api/v1/invalidate/tags/preview?tags=bench:wide,bench:half,bench:narrow&mode=all&sample=0")
+				result := TestResult{Duration: time.Since(start), Error: err, RequestType: "PREVIEW"}
+				if resp != nil {
+					result.StatusCode = resp.StatusCode
+					resp.Body.Close()
+				}
+				lt.addResult(result)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lt.printResults("Tag Intersection Test", time.Since(startTime), concurrency*previews)
+}
+
+// ScanResistanceTest measures how well the server's eviction policy keeps a
+// hot set while one-off keys stream through: readers fetch 500 hot keys,
+// storing them again on a miss, while as many scanners write scanKeys keys
+// that are never read. Run it against servers with different EvictionPolicy settings
+// and a MaxSize between the hot set and the scan.
+func (lt *LoadTester) ScanResistanceTest(concurrency, scanKeys int) {
+	fmt.Printf("🔍 Running scan resistance test: %d readers, %d scanned keys\n", concurrency, scanKeys)
+	lt.resetServerStats()
+
+	const hotKeys = 500
+	for i := 0; i < hotKeys; i++ {
+		lt.setCacheValue(fmt.Sprintf("scan:hot:%d", i), i, 3600, nil)
+	}
+
+	var hits, lookups atomic.Int64
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	startTime := time.Now()
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				key := fmt.Sprintf("scan:hot:%d", (workerID*7919+j*31)%hotKeys)
+				result := lt.getCacheValue(key)
+				lt.addResult(result)
+				lookups.Add(1)
+				if result.StatusCode == http.StatusOK {
+					hits.Add(1)
+				} else {
+					lt.setCacheValue(key, j, 3600, nil)
+				}
+			}
+		}(i)
+	}
+
+	var scanners sync.WaitGroup
+	perScanner := scanKeys / concurrency
+	for i := 0; i < concurrency; i++ {
+		scanners.Add(1)
+		go func(scannerID int) {
+			defer scanners.Done()
+			for j := 0; j < perScanner; j++ {
+				lt.setCacheValue(fmt.Sprintf("scan:oneoff:%d:%d", scannerID, j), j, 3600, nil)
+			}
+		}(i)
+	}
+	scanners.Wait()
+	close(done)
+	wg.Wait()
+
+	lt.printResults("Scan Resistance Test", time.Since(startTime), int(lookups.Load()))
+	if lookups.Load() > 0 {
+		fmt.Printf("Hot Set Hit Rate:  %.2f%% (%d/%d)\n",
+			float64(hits.Load())/float64(lookups.Load())*100, hits.Load(), lookups.Load())
+	}
 }
 
 // ApplicationTest tests through the sample application
 func (lt *LoadTester) ApplicationTest(concurrency, requests int) {
 	fmt.Printf("🌐 Running application test: %d concurrent workers, %d total requests\n", concurrency, requests)
+	lt.resetServerStats()
 
 	var wg sync.WaitGroup
 	var completed int64
@@ -134,6 +302,7 @@ func (lt *LoadTester) ApplicationTest(concurrency, requests int) {
 // MixedWorkloadTest simulates a realistic mixed workload
 func (lt *LoadTester) MixedWorkloadTest(duration time.Duration, concurrency int) {
 	fmt.Printf("⚡ Running mixed workload test: %d workers for %v\n", concurrency, duration)
+	lt.resetServerStats()
 
 	var wg sync.WaitGroup
 	var totalRequests int64
@@ -189,6 +358,33 @@ func (lt *LoadTester) MixedWorkloadTest(duration time.Duration, concurrency int)
 	lt.printResults("Mixed Workload Test", totalDuration, int(totalRequests))
 }
 
+// resetServerStats zeroes the cache server's counters so its reported hit
+// rate covers only the test about to run
+func (lt *LoadTester) resetServerStats() {
+	if !lt.ResetStats {
+		return
+	}
+
+	req, err := http.NewRequest("POST", lt.CacheURL+"/api/v1/stats/reset?confirm=true", nil)
+	if err != nil {
+		log.Printf("warning: stats reset failed: %v", err)
+		return
+	}
+	if lt.APIKey != "" {
+		req.Header.Set("X-API-Key", lt.APIKey)
+	}
+
+	resp, err := lt.Client.Do(req)
+	if err != nil {
+		log.Printf("warning: stats reset failed: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("warning: stats reset returned status %d", resp.StatusCode)
+	}
+}
+
 // Helper methods for different request types
 
 func (lt *LoadTester) setCacheValue(key string, value interface{}, ttl int, tags []string) TestResult {
@@ -217,6 +413,7 @@ func (lt *LoadTester) setCacheValue(key string, value interface{}, ttl int, tags
 
 	if resp != nil {
 		result.StatusCode = resp.StatusCode
+		lt.checkBackpressure(resp)
 		resp.Body.Close()
 	}
 
@@ -236,12 +433,28 @@ func (lt *LoadTester) getCacheValue(key string) TestResult {
 
 	if resp != nil {
 		result.StatusCode = resp.StatusCode
+		lt.checkBackpressure(resp)
 		resp.Body.Close()
 	}
 
 	return result
 }
 
+// checkBackpressure warns when the cache server starts or stops reporting
+// that it is nearly full
+func (lt *LoadTester) checkBackpressure(resp *http.Response) {
+	active := resp.Header.Get("X-Cache-Backpressure") == "true"
+	if lt.backpressure.Swap(active) == active {
+		return
+	}
+	if active {
+		log.Printf("warning: cache server is under backpressure (load %s), evictions are imminent",
+			resp.Header.Get("X-Cache-Load"))
+	} else {
+		log.Printf("cache server is no longer under backpressure")
+	}
+}
+
 func (lt *LoadTester) getUser(userID int) TestResult {
 	start := time.Now()
 	resp, err := lt.Client.Get(fmt.Sprintf("%s/api/users/%d", lt.AppURL, userID))
@@ -319,6 +532,21 @@ func (lt *LoadTester) addResult(result TestResult) {
 	lt.Results = append(lt.Results, result)
 }
 
+// percentile returns the p-th percentile of sorted durations, interpolating
+// linearly between the two nearest samples when p falls between them
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	fraction := rank - float64(lower)
+	return sorted[lower] + time.Duration(fraction*float64(sorted[lower+1]-sorted[lower]))
+}
+
 func (lt *LoadTester) printResults(testName string, totalDuration time.Duration, totalRequests int) {
 	lt.mutex.Lock()
 	defer lt.mutex.Unlock()
@@ -335,7 +563,7 @@ func (lt *LoadTester) printResults(testName string, totalDuration time.Duration,
 	// Calculate statistics
 	var totalTime time.Duration
 	var successCount, errorCount int
-	var cacheHits, cacheMisses int
+	var cacheHits, cacheMisses, cacheErrors int
 	statusCodes := make(map[int]int)
 	requestTypes := make(map[string]int)
 
@@ -371,6 +599,8 @@ func (lt *LoadTester) printResults(testName string, totalDuration time.Duration,
 			cacheHits++
 		} else if result.CacheStatus == "MISS" {
 			cacheMisses++
+		} else if result.CacheStatus == "ERROR" {
+			cacheErrors++
 		}
 	}
 
@@ -387,6 +617,9 @@ func (lt *LoadTester) printResults(testName string, totalDuration time.Duration,
 	if cacheHits+cacheMisses > 0 {
 		fmt.Printf("Cache Hit Rate:    %.2f%% (%d/%d)\n", float64(cacheHits)/float64(cacheHits+cacheMisses)*100, cacheHits, cacheHits+cacheMisses)
 	}
+	if cacheErrors > 0 {
+		fmt.Printf("Cache Errors:      %d (the app could not read from the cache)\n", cacheErrors)
+	}
 
 	fmt.Printf("\nResponse Times:\n")
 	fmt.Printf("  Min:             %v\n", minDuration)
@@ -395,10 +628,10 @@ func (lt *LoadTester) printResults(testName string, totalDuration time.Duration,
 
 	// Calculate percentiles
 	if len(durations) > 0 {
-		// Simple percentile calculation (not perfectly accurate but good enough)
-		p50 := durations[len(durations)*50/100]
-		p95 := durations[len(durations)*95/100]
-		p99 := durations[len(durations)*99/100]
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		p50 := percentile(durations, 50)
+		p95 := percentile(durations, 95)
+		p99 := percentile(durations, 99)
 
 		fmt.Printf("  50th percentile: %v\n", p50)
 		fmt.Printf("  95th percentile: %v\n", p95)
@@ -423,10 +656,13 @@ func main() {
 	var (
 		cacheURL    = flag.String("cache", "http://localhost:8080", "Cache server URL")
 		appURL      = flag.String("app", "http://localhost:3000", "Application server URL")
-		testType    = flag.String("test", "mixed", "Test type: direct, app, mixed, all")
+		testType    = flag.String("test", "mixed", "Test type: direct, app, mixed, protocols, tags, scan, all")
 		concurrency = flag.Int("c", 10, "Number of concurrent workers")
 		requests    = flag.Int("r", 1000, "Number of requests for direct/app tests")
 		duration    = flag.Duration("d", 60*time.Second, "Duration for mixed workload test")
+		h2c         = flag.Bool("h2c", false, "Talk cleartext HTTP/2 with prior knowledge; the cache server needs EnableH2C")
+		resetStats  = flag.Bool("reset-stats", false, "Reset the cache server's stats before each test")
+		apiKey      = flag.String("api-key", "", "X-API-Key for the stats reset, when the server sets AdminAPIKey")
 	)
 	flag.Parse()
 
@@ -441,6 +677,11 @@ func main() {
 	fmt.Println()
 
 	tester := NewLoadTester(*cacheURL, *appURL)
+	tester.ResetStats = *resetStats
+	tester.APIKey = *apiKey
+	if *h2c {
+		tester.Client = newClient(true, *concurrency)
+	}
 
 	switch *testType {
 	case "direct":
@@ -449,6 +690,12 @@ func main() {
 		tester.ApplicationTest(*concurrency, *requests)
 	case "mixed":
 		tester.MixedWorkloadTest(*duration, *concurrency)
+	case "protocols":
+		tester.ProtocolTest(*concurrency, *requests)
+	case "tags":
+		tester.TagIntersectionTest(*concurrency, *requests)
+	case "scan":
+		tester.ScanResistanceTest(*concurrency, *requests)
 	case "all":
 		fmt.Println("Running all test types...")
 		tester.DirectCacheTest(*concurrency, *requests/2)
@@ -457,7 +704,7 @@ func main() {
 		time.Sleep(2 * time.Second)
 		tester.MixedWorkloadTest(*duration/2, *concurrency)
 	default:
-		log.Fatal("Invalid test type. Use: direct, app, mixed, or all")
+		log.Fatal("Invalid test type. Use: direct, app, mixed, protocols, tags, scan, or all")
 	}
 
 	fmt.Println("\nLoad testing completed!")
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	// 1ms to 100ms, recorded in random order as concurrent workers would
+	durations := make([]time.Duration, 100)
+	for i := range durations {
+		durations[i] = time.Duration(i+1) * time.Millisecond
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(durations), func(i, j int) {
+		durations[i], durations[j] = durations[j], durations[i]
+	})
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	for _, tc := range []struct {
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{durations, 50, 50 * time.Millisecond},
+		{durations, 95, 95 * time.Millisecond},
+		{durations, 99, 99 * time.Millisecond},
+		{durations, 0, time.Millisecond},
+		{durations, 100, 100 * time.Millisecond},
+		// Halfway between the two samples
+		{[]time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, 50, 15 * time.Millisecond},
+		{[]time.Duration{7 * time.Millisecond}, 99, 7 * time.Millisecond},
+		{nil, 50, 0},
+	} {
+		got := percentile(tc.sorted, tc.p)
+		if diff := got - tc.want; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("P%v of %d durations = %v, want %v within 1ms", tc.p, len(tc.sorted), got, tc.want)
+		}
+	}
+}
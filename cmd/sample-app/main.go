@@ -1,15 +1,16 @@
 package main
 
 import (
-	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/1n1nth/DistroCache/pkg/client"
 	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -30,93 +31,15 @@ type Product struct {
 	Category string  `json:"category"`
 }
 
-// CacheClient handles communication with DistroCache
-type CacheClient struct {
-	BaseURL string
-	Client  *http.Client
-}
-
-// NewCacheClient creates a new cache client
-func NewCacheClient(baseURL string) *CacheClient {
-	return &CacheClient{
-		BaseURL: baseURL,
-		Client:  &http.Client{Timeout: 5 * time.Second},
-	}
-}
-
-// Get retrieves a value from cache
-func (c *CacheClient) Get(key string) (interface{}, error) {
-	resp, err := c.Client.Get(fmt.Sprintf("%s/api/v1/cache/%s", c.BaseURL, key))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("key not found")
-	}
-
-	var result struct {
-		Value interface{} `json:"value"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	return result.Value, nil
-}
-
-// Set stores a value in cache
-func (c *CacheClient) Set(key string, value interface{}, ttl int, tags []string) error {
-	reqBody := map[string]interface{}{
-		"value": value,
-		"ttl":   ttl,
-		"tags":  tags,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.Client.Post(
-		fmt.Sprintf("%s/api/v1/cache/%s", c.BaseURL, key),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	return nil
-}
-
-// InvalidateTag invalidates all cached items with a specific tag
-func (c *CacheClient) InvalidateTag(tag string) error {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/invalidate/tag/%s", c.BaseURL, tag), nil)
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	return nil
-}
-
 // TestApp represents our sample application
 type TestApp struct {
 	db    *sql.DB
-	cache *CacheClient
+	cache *client.CacheClient
 }
 
-// NewTestApp creates a new test application
-func NewTestApp() *TestApp {
+// NewTestApp creates a new test application that caches through cache; tests
+// can pass a client from cachetest.StartTestServer
+func NewTestApp(cache *client.CacheClient) *TestApp {
 	// Setup SQLite database
 	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
@@ -184,8 +107,28 @@ func NewTestApp() *TestApp {
 
 	return &TestApp{
 		db:    db,
-		cache: NewCacheClient("http://localhost:8080"),
+		cache: cache,
+	}
+}
+
+// cacheLookup reads key from the cache and sets X-Cache to HIT, MISS or
+// ERROR. A cache that fails for any reason other than a miss is logged and
+// reported as ERROR, so a misconfigured cache shows up instead of silently
+// sending every request to the database; callers fall through to it either
+// way.
+func (app *TestApp) cacheLookup(w http.ResponseWriter, key string) (interface{}, bool) {
+	value, err := app.cache.Get(key)
+	switch {
+	case err == nil:
+		w.Header().Set("X-Cache", "HIT")
+		return value, true
+	case errors.Is(err, client.ErrKeyNotFound):
+		w.Header().Set("X-Cache", "MISS")
+	default:
+		log.Printf("cache lookup of %s failed: %v", key, err)
+		w.Header().Set("X-Cache", "ERROR")
 	}
+	return nil, false
 }
 
 // getUser retrieves a user by ID with caching
@@ -196,15 +139,14 @@ func (app *TestApp) getUser(w http.ResponseWriter, r *http.Request) {
 
 	// Try cache first
 	start := time.Now()
-	if cachedUser, err := app.cache.Get(cacheKey); err == nil {
+	if cachedUser, hit := app.cacheLookup(w, cacheKey); hit {
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache", "HIT")
 		w.Header().Set("X-Response-Time", time.Since(start).String())
 		json.NewEncoder(w).Encode(cachedUser)
 		return
 	}
 
-	// Cache miss - query database
+	// Cache miss or error - query database
 	var user User
 	err := app.db.QueryRow("SELECT id, name, email, created FROM users WHERE id = ?", userID).
 		Scan(&user.ID, &user.Name, &user.Email, &user.Created)
@@ -222,7 +164,6 @@ func (app *TestApp) getUser(w http.ResponseWriter, r *http.Request) {
 	app.cache.Set(cacheKey, user, 300, []string{"users", fmt.Sprintf("user:%d", user.ID)})
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
 	w.Header().Set("X-Response-Time", time.Since(start).String())
 	json.NewEncoder(w).Encode(user)
 }
@@ -238,15 +179,14 @@ func (app *TestApp) getProducts(w http.ResponseWriter, r *http.Request) {
 
 	// Try cache first
 	start := time.Now()
-	if cachedProducts, err := app.cache.Get(cacheKey); err == nil {
+	if cachedProducts, hit := app.cacheLookup(w, cacheKey); hit {
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache", "HIT")
 		w.Header().Set("X-Response-Time", time.Since(start).String())
 		json.NewEncoder(w).Encode(cachedProducts)
 		return
 	}
 
-	// Cache miss - query database
+	// Cache miss or error - query database
 	var query string
 	var args []interface{}
 
@@ -280,7 +220,6 @@ func (app *TestApp) getProducts(w http.ResponseWriter, r *http.Request) {
 	app.cache.Set(cacheKey, products, 600, tags)
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
 	w.Header().Set("X-Response-Time", time.Since(start).String())
 	json.NewEncoder(w).Encode(products)
 }
@@ -537,8 +476,9 @@ func (app *TestApp) benchmarkHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	app := NewTestApp()
+	app := NewTestApp(client.NewCacheClient("http://localhost:8080"))
 	defer app.db.Close()
+	defer app.cache.Close()
 
 	r := mux.NewRouter()
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1n1nth/DistroCache/pkg/cachetest"
+	"github.com/1n1nth/DistroCache/pkg/client"
+	"github.com/gorilla/mux"
+)
+
+func TestGetUserCaches(t *testing.T) {
+	app := NewTestApp(cachetest.StartTestServer(t, nil))
+	t.Cleanup(func() { app.db.Close() })
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/users/{id}", app.getUser).Methods("GET")
+
+	for _, want := range []string{"MISS", "HIT"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users/1", nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET /api/users/1: status %d: %s", rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("X-Cache"); got != want {
+			t.Errorf("X-Cache = %q, want %s", got, want)
+		}
+	}
+}
+
+func TestGetUserReportsCacheErrors(t *testing.T) {
+	// Nothing listens on a closed server's address
+	down := httptest.NewServer(nil)
+	down.Close()
+	cache := client.NewCacheClient(down.URL)
+	app := NewTestApp(cache)
+	t.Cleanup(func() {
+		app.db.Close()
+		cache.Close()
+	})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/users/{id}", app.getUser).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users/1", nil))
+	if rec.Code != 200 {
+		t.Fatalf("GET /api/users/1 with the cache down: status %d, want the user from the database", rec.Code)
+	}
+	if got := rec.Header().Get("X-Cache"); got != "ERROR" {
+		t.Errorf("X-Cache = %q, want ERROR", got)
+	}
+}
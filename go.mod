@@ -0,0 +1,31 @@
+module github.com/1n1nth/DistroCache
+
+go 1.24.4
+
+require (
+	github.com/dgraph-io/badger/v4 v4.5.1
+	github.com/gorilla/mux v1.8.1
+	github.com/klauspost/compress v1.18.0
+	github.com/prometheus/client_golang v1.22.0
+	go.etcd.io/bbolt v1.4.0
+	golang.org/x/sync v0.10.0
+	golang.org/x/sys v0.30.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgraph-io/ristretto/v2 v2.1.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
+	github.com/google/flatbuffers v24.12.23+incompatible // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	go.opencensus.io v0.24.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+)
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultAccessBuckets are the access count buckets used when none are given
+var defaultAccessBuckets = []int64{1, 5, 10, 100, 1000}
+
+// AccessHistogram counts unexpired items by AccessCount, which starts at 1
+// when an item is set and grows with each hit. Each bucket counts the items
+// with at least that many accesses but fewer than the next bucket; the
+// largest bucket has no upper bound, and items below the smallest bucket are
+// counted under 0. Buckets are sorted and de-duplicated, negative ones are
+// dropped, and none gives 1, 5, 10, 100 and 1000.
+func (dc *DistroCache) AccessHistogram(buckets []int64) map[int64]int {
+	bounds := slices.DeleteFunc(slices.Clone(buckets), func(b int64) bool {
+		return b < 0
+	})
+	if len(bounds) == 0 {
+		bounds = slices.Clone(defaultAccessBuckets)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	if bounds[0] > 0 {
+		bounds = append([]int64{0}, bounds...)
+	}
+
+	counts := make([]int, len(bounds))
+
+	dc.mutex.RLock()
+	now := dc.now()
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		if !item.ExpiredAt(now) {
+			// Index of the largest bound not above the access count
+			count := dc.access.of(key, item).count
+			counts[sort.Search(len(bounds), func(i int) bool { return bounds[i] > count })-1]++
+		}
+		return true
+	})
+	dc.mutex.RUnlock()
+
+	histogram := make(map[int64]int, len(bounds))
+	for i, bound := range bounds {
+		histogram[bound] = counts[i]
+	}
+	return histogram
+}
+
+func (dc *DistroCache) handleAccessHistogram(w http.ResponseWriter, r *http.Request) {
+	var buckets []int64
+	if raw := r.URL.Query().Get("buckets"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			bucket, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil || bucket < 0 {
+				http.Error(w, "buckets must be a comma-separated list of non-negative integers", http.StatusBadRequest)
+				return
+			}
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, dc.AccessHistogram(buckets))
+}
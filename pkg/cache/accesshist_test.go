@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAccessHistogram(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	dc := newTestCache(t, func(config *CacheConfig) { config.Clock = clock })
+	ctx := context.Background()
+
+	// A set counts as the first access, so each key is read count-1 times
+	for key, count := range map[string]int{"a": 1, "b": 4, "c": 5, "d": 9, "e": 50, "f": 2000} {
+		if err := dc.Set(ctx, key, key, time.Hour, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < count; i++ {
+			dc.Get(ctx, key)
+		}
+	}
+	// Expired items are not counted
+	if err := dc.Set(ctx, "stale", "stale", time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+
+	want := map[int64]int{0: 0, 1: 2, 5: 2, 10: 1, 100: 0, 1000: 1}
+	if got := dc.AccessHistogram(nil); !maps.Equal(got, want) {
+		t.Errorf("AccessHistogram(nil) = %v, want %v", got, want)
+	}
+
+	// Unsorted buckets with a duplicate, over HTTP
+	var got map[string]int
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/stats/access-histogram?buckets=10,2,10", nil)
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if wantHTTP := map[string]int{"0": 1, "2": 3, "10": 2}; !maps.Equal(got, wantHTTP) {
+		t.Errorf("access histogram over HTTP = %v, want %v", got, wantHTTP)
+	}
+
+	mustServe(t, dc, http.StatusBadRequest, "GET", "/api/v1/stats/access-histogram?buckets=1,-5", nil)
+	mustServe(t, dc, http.StatusBadRequest, "GET", "/api/v1/stats/access-histogram?buckets=many", nil)
+}
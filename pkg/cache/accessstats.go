@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// accessRateWindow is the time constant of the per-item access rate: reads
+// older than this weigh about a third as much as a read just now
+const accessRateWindow = time.Minute
+
+// accessStats are an item's read statistics: AccessedAt, AccessCount and
+// AccessRate
+type accessStats struct {
+	accessedAt time.Time
+	count      int64
+	rate       float64
+}
+
+// rateAt returns the recent reads per second as of now
+func (s accessStats) rateAt(now time.Time) float64 {
+	elapsed := now.Sub(s.accessedAt)
+	if elapsed <= 0 {
+		return s.rate
+	}
+	return s.rate * math.Exp(-elapsed.Seconds()/accessRateWindow.Seconds())
+}
+
+// accessTracker keeps the read statistics of items in memory. Every hit
+// changes them, and writing the item back to the backend each time would,
+// for backends that store bytes, mean encoding it and committing a write
+// transaction per read. Items carry the statistics they had when last
+// stored; the tracker holds those changed since, and they are written back
+// when the cache is closed.
+type accessTracker struct {
+	mutex sync.Mutex
+	stats map[string]accessStats
+}
+
+func newAccessTracker() *accessTracker {
+	return &accessTracker{stats: make(map[string]accessStats)}
+}
+
+// of returns the read statistics of the item stored at key
+func (t *accessTracker) of(key string, item *CacheItem) accessStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.ofLocked(key, item)
+}
+
+// ofLocked is of for callers holding the tracker's mutex
+func (t *accessTracker) ofLocked(key string, item *CacheItem) accessStats {
+	if stats, tracked := t.stats[key]; tracked {
+		return stats
+	}
+	return accessStats{accessedAt: item.AccessedAt, count: item.AccessCount, rate: item.AccessRate}
+}
+
+// record counts a read of the item stored at key at now. AccessRate is an
+// exponentially weighted moving average of reads per second, decayed by the
+// time since the previous read.
+func (t *accessTracker) record(key string, item *CacheItem, now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	previous := t.ofLocked(key, item)
+	t.stats[key] = accessStats{
+		accessedAt: now,
+		count:      previous.count + 1,
+		rate:       previous.rateAt(now) + 1/accessRateWindow.Seconds(),
+	}
+}
+
+// forget drops the statistics of key, whose item was replaced or removed
+func (t *accessTracker) forget(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.stats, key)
+}
+
+// withAccessStats returns item with the read statistics tracked for key: a
+// copy when they differ from those it was stored with, so the stored item is
+// never changed
+func (dc *DistroCache) withAccessStats(key string, item *CacheItem) *CacheItem {
+	stats := dc.access.of(key, item)
+	if stats.count == item.AccessCount && stats.accessedAt.Equal(item.AccessedAt) {
+		return item
+	}
+	view := *item
+	view.AccessedAt, view.AccessCount, view.AccessRate = stats.accessedAt, stats.count, stats.rate
+	return &view
+}
+
+// persistAccessStats writes the tracked read statistics back into the
+// stored items; callers must hold the write lock
+func (dc *DistroCache) persistAccessStats(ctx context.Context) {
+	dc.access.mutex.Lock()
+	keys := make([]string, 0, len(dc.access.stats))
+	for key := range dc.access.stats {
+		keys = append(keys, key)
+	}
+	dc.access.mutex.Unlock()
+
+	for _, key := range keys {
+		if item, exists := dc.data.Get(ctx, key); exists {
+			// Best effort: stores holding copies may reject an item that grew
+			dc.data.Set(ctx, key, dc.withAccessStats(key, item))
+		}
+		dc.access.forget(key)
+	}
+}
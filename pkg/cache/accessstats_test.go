@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countingBackend is an in-memory backend that counts the items stored
+type countingBackend struct {
+	*InMemoryBackend
+	sets atomic.Int64
+}
+
+func (b *countingBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	b.sets.Add(1)
+	return b.InMemoryBackend.Set(ctx, key, item)
+}
+
+func TestGetDoesNotWriteToTheBackend(t *testing.T) {
+	backend := &countingBackend{InMemoryBackend: NewInMemoryBackend()}
+	dc := newTestCache(t, func(config *CacheConfig) { config.Backend = backend })
+	ctx := context.Background()
+
+	if err := dc.Set(ctx, "hot", "value", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	sets := backend.sets.Load()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				dc.Get(ctx, "hot")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := backend.sets.Load() - sets; got != 0 {
+		t.Errorf("100 hits stored the item %d times, want 0", got)
+	}
+	item, _ := dc.Peek(ctx, "hot")
+	if item.AccessCount != 101 {
+		t.Errorf("AccessCount = %d, want 101", item.AccessCount)
+	}
+	if stored, _ := backend.Get(ctx, "hot"); stored.AccessCount != 1 {
+		t.Errorf("stored AccessCount = %d, want 1 until the cache closes", stored.AccessCount)
+	}
+
+	if err := dc.Set(ctx, "hot", "replaced", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := dc.Peek(ctx, "hot"); item.AccessCount != 1 {
+		t.Errorf("AccessCount after a set = %d, want 1", item.AccessCount)
+	}
+}
+
+func TestAccessStatsPersistedOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	open := func() *DistroCache {
+		backend, err := NewBoltBackend(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := DefaultConfig()
+		config.Registry = prometheus.NewRegistry()
+		config.Backend = backend
+		return NewDistroCache(config)
+	}
+	ctx := context.Background()
+
+	dc := open()
+	if err := dc.Set(ctx, "key", "value", time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		dc.Get(ctx, "key")
+	}
+	if err := dc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dc = open()
+	defer dc.Close()
+	item, found := dc.Peek(ctx, "key")
+	if !found {
+		t.Fatal("key missing after reopening")
+	}
+	if item.AccessCount != 5 {
+		t.Errorf("AccessCount after reopening = %d, want 5", item.AccessCount)
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestKeyACL(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/config",
+		map[string]interface{}{"value": "secret", "acl": map[string]interface{}{
+			"read": []string{"key1"}, "write": []string{"key1", "key2"}}},
+		"X-API-Key", "key1")
+
+	mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/config", nil, "X-API-Key", "key1")
+	mustServe(t, dc, http.StatusForbidden, "GET", "/api/v1/cache/config", nil, "X-API-Key", "key2")
+	mustServe(t, dc, http.StatusForbidden, "POST", "/api/v1/cache/config",
+		map[string]interface{}{"value": "stolen"}, "X-API-Key", "key3")
+	mustServe(t, dc, http.StatusForbidden, "PATCH", "/api/v1/cache/config/metadata",
+		map[string]interface{}{"add_tags": []string{"x"}}, "X-API-Key", "key3")
+	mustServe(t, dc, http.StatusForbidden, "DELETE", "/api/v1/cache/config", nil, "X-API-Key", "key3")
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/config",
+		map[string]interface{}{"value": "rotated"}, "X-API-Key", "key2")
+
+	item, _ := dc.Peek(context.Background(), "config")
+	if item.Value != "rotated" {
+		t.Errorf("config = %v, want the allowed writer's value", item.Value)
+	}
+	if err := dc.Set(context.Background(), "config", "direct", time.Minute, nil); err != nil {
+		t.Errorf("direct Set on an ACL-protected key: %v", err)
+	}
+}
+
+// TestKeyACLCheckedWithTheWrite checks writes against the ACL in the same
+// critical section that stores them, so an ACL set between a handler's
+// checks and its write still applies
+func TestKeyACLCheckedWithTheWrite(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	if err := dc.SetWithOptions(ctx, "config", "secret", time.Minute, nil,
+		SetOptions{ACL: &KeyACL{Write: []string{"owner"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	intruder := withRequestInfo(ctx, requestInfo{APIKey: "intruder"})
+	if err := dc.Set(intruder, "config", "stolen", time.Minute, nil); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Set error = %v, want ErrForbidden", err)
+	}
+	if _, err := dc.Increment(intruder, "config", 1); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Increment error = %v, want ErrForbidden", err)
+	}
+	if _, err := dc.DeleteWithVersion(intruder, "config", 0); !errors.Is(err, ErrForbidden) {
+		t.Errorf("DeleteWithVersion error = %v, want ErrForbidden", err)
+	}
+	if _, err := dc.DeleteMany(intruder, []string{"other", "config"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("DeleteMany error = %v, want ErrForbidden", err)
+	}
+	if item, found := dc.Peek(ctx, "config"); !found || item.Value != "secret" {
+		t.Errorf("config = %v after refused writes, want secret", item)
+	}
+}
+
+func TestDeniedReadsDoNotCountAsHits(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/config",
+		map[string]interface{}{"value": "secret", "acl": map[string]interface{}{"read": []string{"key1"}}},
+		"X-API-Key", "key1")
+	before, _ := dc.Peek(context.Background(), "config")
+
+	for i := 0; i < 3; i++ {
+		mustServe(t, dc, http.StatusForbidden, "GET", "/api/v1/cache/config", nil, "X-API-Key", "key2")
+	}
+
+	if got := testutil.ToFloat64(dc.stats.Hits); got != 0 {
+		t.Errorf("denied reads counted %v hits, want 0", got)
+	}
+	if after, _ := dc.Peek(context.Background(), "config"); after.AccessCount != before.AccessCount {
+		t.Errorf("denied reads raised the access count from %d to %d", before.AccessCount, after.AccessCount)
+	}
+}
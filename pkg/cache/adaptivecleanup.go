@@ -0,0 +1,29 @@
+package cache
+
+import "time"
+
+// Thresholds of the expired share of a sweep that adapt the cleanup interval
+const (
+	adaptiveCleanupSpeedUp  = 0.10
+	adaptiveCleanupSlowDown = 0.01
+	minCleanupInterval      = time.Second
+)
+
+// nextCleanupInterval adapts interval to the last sweep: halved when more
+// than 10% of the items examined had expired, down to a second, and doubled
+// when fewer than 1% had, up to limit
+func nextCleanupInterval(interval, limit time.Duration, examined, expired int) time.Duration {
+	if examined == 0 {
+		return min(interval*2, limit)
+	}
+
+	ratio := float64(expired) / float64(examined)
+	switch {
+	case ratio > adaptiveCleanupSpeedUp:
+		return max(interval/2, min(minCleanupInterval, limit))
+	case ratio < adaptiveCleanupSlowDown:
+		return min(interval*2, limit)
+	default:
+		return interval
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestAdaptiveCleanupConverges replays sweeps on a mock clock while 1000
+// items with a 100ms TTL are written between each, and checks the interval
+// falls from a minute to a second and recovers once the writes stop
+func TestAdaptiveCleanupConverges(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.Clock = clock
+		config.MaxSize = 10000
+		config.CleanupInterval = time.Minute
+		config.AdaptiveCleanup = true
+	})
+	ctx := context.Background()
+
+	interval := dc.config.CleanupInterval
+	sweeps := 0
+	for ; interval > time.Second && sweeps < 10; sweeps++ {
+		for i := 0; i < 1000; i++ {
+			if err := dc.Set(ctx, fmt.Sprintf("session:%d:%d", sweeps, i), i, 100*time.Millisecond, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		clock.Advance(interval)
+		examined, expired := dc.cleanup()
+		interval = nextCleanupInterval(interval, dc.config.CleanupInterval, examined, expired)
+	}
+	// 60s halves to 1s in six sweeps
+	if interval != time.Second || sweeps > 6 {
+		t.Errorf("interval after %d sweeps = %v, want 1s within 6", sweeps, interval)
+	}
+
+	// With nothing expiring it backs off, but never past CleanupInterval
+	for i := 0; i < 10; i++ {
+		clock.Advance(interval)
+		examined, expired := dc.cleanup()
+		interval = nextCleanupInterval(interval, dc.config.CleanupInterval, examined, expired)
+	}
+	if interval != time.Minute {
+		t.Errorf("interval once idle = %v, want the configured 1m", interval)
+	}
+}
+
+func TestNextCleanupInterval(t *testing.T) {
+	for _, tc := range []struct {
+		interval, limit   time.Duration
+		examined, expired int
+		want              time.Duration
+	}{
+		{time.Minute, time.Minute, 100, 50, 30 * time.Second},
+		{1500 * time.Millisecond, time.Minute, 100, 50, time.Second},
+		{time.Second, time.Minute, 100, 50, time.Second},
+		{10 * time.Second, time.Minute, 100, 5, 10 * time.Second},
+		{10 * time.Second, time.Minute, 1000, 0, 20 * time.Second},
+		{40 * time.Second, time.Minute, 0, 0, time.Minute},
+		// A configured interval under a second is never raised to one
+		{500 * time.Millisecond, 500 * time.Millisecond, 100, 50, 500 * time.Millisecond},
+	} {
+		if got := nextCleanupInterval(tc.interval, tc.limit, tc.examined, tc.expired); got != tc.want {
+			t.Errorf("nextCleanupInterval(%v, %v, %d, %d) = %v, want %v",
+				tc.interval, tc.limit, tc.examined, tc.expired, got, tc.want)
+		}
+	}
+}
+
+func TestAdaptiveCleanupLoop(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.CleanupInterval = 2 * time.Second
+		config.AdaptiveCleanup = true
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 1000; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("session:%d", i), i, 100*time.Millisecond, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if dc.GetStats()["cleanup_interval"] == "1s" {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Errorf("cleanup_interval = %v after the first sweep, want 1s", dc.GetStats()["cleanup_interval"])
+}
@@ -0,0 +1,11 @@
+package cache
+
+import "net/http"
+
+// handleConfig returns the configuration the cache runs with, including its
+// guardrails such as MaxKeyLength and MaxTagsPerKey, for debugging a node.
+// Callbacks, the backend and AdminAPIKey are left out. Like every admin
+// route it requires AdminAPIKey when one is configured.
+func (dc *DistroCache) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, dc.config)
+}
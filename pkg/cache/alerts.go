@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// alertSamplesToFire is the number of consecutive low samples that raise an alert
+const alertSamplesToFire = 2
+
+// hitRateAlert is the payload POSTed to AlertWebhookURL
+type hitRateAlert struct {
+	Alert     string  `json:"alert"`
+	Status    string  `json:"status"`
+	Rate      float64 `json:"rate"`
+	Threshold float64 `json:"threshold"`
+	NodeID    string  `json:"node_id"`
+}
+
+// hitRateMonitor samples the hit rate and notifies a webhook when it stays
+// below the threshold, and again when it recovers
+type hitRateMonitor struct {
+	cache      *DistroCache
+	client     *http.Client
+	lowSamples int
+	firing     bool
+	lastHits   int64
+	lastMisses int64
+}
+
+// newHitRateMonitor creates a monitor for the cache's configured threshold
+func newHitRateMonitor(cache *DistroCache) *hitRateMonitor {
+	return &hitRateMonitor{
+		cache:  cache,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// run samples the hit rate every AlertCheckInterval until ctx is done
+func (m *hitRateMonitor) run(ctx context.Context) {
+	interval := m.cache.config.AlertCheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check takes one sample. The rate covers requests since the previous sample,
+// so recoveries are noticed promptly; intervals without traffic are skipped.
+func (m *hitRateMonitor) check() {
+	hits := m.cache.stats.hitCount.Load()
+	misses := m.cache.stats.missCount.Load()
+	deltaHits, deltaMisses := hits-m.lastHits, misses-m.lastMisses
+	m.lastHits, m.lastMisses = hits, misses
+
+	total := deltaHits + deltaMisses
+	if total <= 0 {
+		return
+	}
+	rate := float64(deltaHits) / float64(total)
+	threshold := m.cache.config.HitRateAlertThreshold
+
+	if rate < threshold {
+		m.lowSamples++
+		if !m.firing && m.lowSamples >= alertSamplesToFire {
+			m.firing = true
+			m.notify("firing", rate)
+		}
+		return
+	}
+
+	m.lowSamples = 0
+	if m.firing {
+		m.firing = false
+		m.notify("resolved", rate)
+	}
+}
+
+// notify POSTs an alert event to the webhook
+func (m *hitRateMonitor) notify(status string, rate float64) {
+	body, err := json.Marshal(hitRateAlert{
+		Alert:     "low_hit_rate",
+		Status:    status,
+		Rate:      rate,
+		Threshold: m.cache.config.HitRateAlertThreshold,
+		NodeID:    m.cache.config.NodeID,
+	})
+	if err != nil {
+		log.Printf("hit rate alert: %v", err)
+		return
+	}
+
+	if err := m.post(body); err != nil {
+		log.Printf("hit rate alert (%s) delivery failed: %v", status, err)
+	}
+}
+
+// post sends a single alert request
+func (m *hitRateMonitor) post(body []byte) error {
+	resp, err := m.client.Post(m.cache.config.AlertWebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// nextAlert returns the next alert the webhook receives, driving traffic
+// with generate until it arrives
+func nextAlert(t *testing.T, alerts <-chan hitRateAlert, generate func()) hitRateAlert {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		generate()
+		select {
+		case alert := <-alerts:
+			return alert
+		case <-deadline:
+			t.Fatal("no alert delivered")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestHitRateAlertFiresAndResolves(t *testing.T) {
+	alerts := make(chan hitRateAlert, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert hitRateAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		alerts <- alert
+	}))
+	t.Cleanup(webhook.Close)
+
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.HitRateAlertThreshold = 0.8
+		config.AlertWebhookURL = webhook.URL
+		config.AlertCheckInterval = 50 * time.Millisecond
+	})
+	ctx := context.Background()
+	if err := dc.Set(ctx, "present", "value", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	alert := nextAlert(t, alerts, func() { dc.Get(ctx, "absent") })
+	if alert.Alert != "low_hit_rate" || alert.Status != "firing" || alert.Rate != 0 ||
+		alert.Threshold != 0.8 || alert.NodeID != "node-1" {
+		t.Errorf("alert = %+v, want low_hit_rate firing at rate 0", alert)
+	}
+
+	alert = nextAlert(t, alerts, func() { dc.Get(ctx, "present") })
+	if alert.Alert != "low_hit_rate" || alert.Status != "resolved" || alert.Rate < 0.8 {
+		t.Errorf("resolution = %+v, want low_hit_rate resolved at a rate of at least 0.8", alert)
+	}
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ARC lists: resident recent and frequent keys, and their ghosts
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+	arcListCount
+)
+
+// arcEntry is a key and the ARC list it currently lives in
+type arcEntry struct {
+	key  string
+	list int
+}
+
+// arcPolicy is an Adaptive Replacement Cache. T1 holds keys seen once and T2
+// keys seen at least twice; B1 and B2 remember keys recently evicted from
+// each. The target size p of T1 adapts to the workload: a hit in B1 means
+// recency was undervalued and grows p, a hit in B2 shrinks it. Victims come
+// from T1 while it is larger than p, otherwise from T2, so a long scan of
+// one-off keys cycles through T1 without flushing the frequent keys in T2.
+type arcPolicy struct {
+	mutex    sync.Mutex
+	lists    [arcListCount]*list.List
+	entries  map[string]*list.Element
+	capacity int
+	p        int
+	victim   string
+}
+
+// newARCPolicy creates an ARC policy sized for capacity items
+func newARCPolicy(capacity int) *arcPolicy {
+	a := &arcPolicy{
+		entries:  make(map[string]*list.Element),
+		capacity: max(1, capacity),
+	}
+	for i := range a.lists {
+		a.lists[i] = list.New()
+	}
+	return a
+}
+
+// Insert adds a key to T1, or to T2 when it is a ghost hit, adapting p
+func (a *arcPolicy) Insert(key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	elem, exists := a.entries[key]
+	if !exists {
+		a.push(key, arcT1)
+		a.trimGhosts()
+		return
+	}
+
+	switch elem.Value.(*arcEntry).list {
+	case arcT1, arcT2:
+		// Overwrite of a resident key keeps its place
+		a.lists[elem.Value.(*arcEntry).list].MoveToFront(elem)
+	case arcB1:
+		a.p = min(a.capacity, a.p+max(1, a.lists[arcB2].Len()/max(1, a.lists[arcB1].Len())))
+		a.move(elem, arcT2)
+	case arcB2:
+		a.p = max(0, a.p-max(1, a.lists[arcB1].Len()/max(1, a.lists[arcB2].Len())))
+		a.move(elem, arcT2)
+	}
+}
+
+// Access moves a resident key to the front of T2
+func (a *arcPolicy) Access(key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if elem, exists := a.entries[key]; exists {
+		if l := elem.Value.(*arcEntry).list; l == arcT1 || l == arcT2 {
+			a.move(elem, arcT2)
+		}
+	}
+}
+
+// Remove forgets a key. The key chosen by the last Victim call is kept as a
+// ghost so a quick return can adapt p; deleted and expired keys are dropped.
+func (a *arcPolicy) Remove(key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	elem, exists := a.entries[key]
+	if !exists {
+		return
+	}
+
+	entry := elem.Value.(*arcEntry)
+	if key == a.victim && (entry.list == arcT1 || entry.list == arcT2) {
+		a.victim = ""
+		a.move(elem, entry.list+arcB1-arcT1)
+		a.trimGhosts()
+		return
+	}
+
+	a.lists[entry.list].Remove(elem)
+	delete(a.entries, key)
+}
+
+// Victim returns the least recently used key of T1 when T1 exceeds its
+// target size p, otherwise of T2. If every key of that list is skipped, the
+// other list is tried.
+func (a *arcPolicy) Victim(skip func(key string) bool) (string, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	first, second := arcT2, arcT1
+	if t1 := a.lists[arcT1].Len(); t1 > 0 && (t1 > a.p || a.lists[arcT2].Len() == 0) {
+		first, second = arcT1, arcT2
+	}
+
+	for _, from := range []int{first, second} {
+		for elem := a.lists[from].Back(); elem != nil; elem = elem.Prev() {
+			if key := elem.Value.(*arcEntry).key; skip == nil || !skip(key) {
+				a.victim = key
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// push adds key to the front of list l; callers must hold the lock
+func (a *arcPolicy) push(key string, l int) {
+	a.entries[key] = a.lists[l].PushFront(&arcEntry{key: key, list: l})
+}
+
+// move places elem at the front of list l; callers must hold the lock
+func (a *arcPolicy) move(elem *list.Element, l int) {
+	entry := elem.Value.(*arcEntry)
+	a.lists[entry.list].Remove(elem)
+	entry.list = l
+	a.entries[entry.key] = a.lists[l].PushFront(entry)
+}
+
+// trimGhosts bounds the ghost lists so that T1+B1 and T2+B2 each track at
+// most capacity keys; callers must hold the lock
+func (a *arcPolicy) trimGhosts() {
+	for _, pair := range [][2]int{{arcT1, arcB1}, {arcT2, arcB2}} {
+		resident, ghosts := a.lists[pair[0]], a.lists[pair[1]]
+		for ghosts.Len() > 0 && resident.Len()+ghosts.Len() > a.capacity {
+			oldest := ghosts.Back()
+			ghosts.Remove(oldest)
+			delete(a.entries, oldest.Value.(*arcEntry).key)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// scanHitRate runs a scan-heavy workload against a cache using policy: a
+// small hot set is read throughout a long stream of keys written once, and
+// hot misses are reloaded as a cache-aside client would. It returns the
+// fraction of hot reads that hit.
+func scanHitRate(t *testing.T, policy string) float64 {
+	const size, hot, scan = 100, 60, 5000
+
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxSize = size
+		config.EvictionPolicy = policy
+	})
+	ctx := context.Background()
+
+	for i := 0; i < hot; i++ {
+		key := fmt.Sprintf("hot:%d", i)
+		dc.Set(ctx, key, i, time.Hour, nil)
+		dc.Get(ctx, key)
+	}
+
+	hits, reads := 0, 0
+	for i := 0; i < scan; i++ {
+		dc.Set(ctx, fmt.Sprintf("scan:%d", i), i, time.Hour, nil)
+
+		key := fmt.Sprintf("hot:%d", i%hot)
+		reads++
+		if _, found := dc.Get(ctx, key); found {
+			hits++
+		} else {
+			dc.Set(ctx, key, i, time.Hour, nil)
+		}
+	}
+	return float64(hits) / float64(reads)
+}
+
+func TestARCBeatsLRUOnScans(t *testing.T) {
+	lru := scanHitRate(t, EvictionLRU)
+	arc := scanHitRate(t, EvictionARC)
+	t.Logf("hot hit rate: lru %.3f, arc %.3f", lru, arc)
+
+	if arc <= lru {
+		t.Errorf("ARC hit rate %.3f, want better than LRU's %.3f", arc, lru)
+	}
+}
+
+func TestARCVictim(t *testing.T) {
+	a := newARCPolicy(2)
+	a.Insert("a")
+	a.Insert("b")
+	a.Access("a")
+
+	// b has been seen once and a twice, so b goes first
+	if key, _ := a.Victim(nil); key != "b" {
+		t.Errorf("victim = %q, want b", key)
+	}
+	if key, _ := a.Victim(func(key string) bool { return key == "b" }); key != "a" {
+		t.Errorf("victim skipping b = %q, want a", key)
+	}
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// backpressureMiddleware adds X-Cache-Backpressure and X-Cache-Load headers
+// to every response once the cache is at least BackpressureThreshold full,
+// warning clients before it starts evicting. The load is measured when the
+// request arrives.
+func (dc *DistroCache) backpressureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if threshold := dc.config.BackpressureThreshold; threshold > 0 {
+			if load := dc.capacityRatio(); load >= threshold {
+				w.Header().Set("X-Cache-Backpressure", "true")
+				w.Header().Set("X-Cache-Load", strconv.FormatFloat(load, 'f', 2, 64))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
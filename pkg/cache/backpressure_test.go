@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBackpressureHeaders(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxSize = 100
+		config.BackpressureThreshold = 0.9
+	})
+	ctx := context.Background()
+
+	fill := func(n int) {
+		for i := dc.Len(); i < n; i++ {
+			if err := dc.Set(ctx, fmt.Sprintf("key:%d", i), i, time.Minute, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	health := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		dc.Router().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/health", nil))
+		return rec
+	}
+
+	fill(89)
+	rec := health()
+	if got := rec.Header().Get("X-Cache-Backpressure"); got != "" {
+		t.Errorf("X-Cache-Backpressure at 89%% full = %q, want none", got)
+	}
+
+	fill(91)
+	rec = health()
+	if got := rec.Header().Get("X-Cache-Backpressure"); got != "true" {
+		t.Errorf("X-Cache-Backpressure at 91%% full = %q, want true", got)
+	}
+	if got := rec.Header().Get("X-Cache-Load"); got != "0.91" {
+		t.Errorf("X-Cache-Load = %q, want 0.91", got)
+	}
+	if got := testutil.ToFloat64(dc.stats.LoadFactor); got != 0.91 {
+		t.Errorf("distrocache_load_factor = %v, want 0.91", got)
+	}
+}
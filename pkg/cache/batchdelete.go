@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// DeleteMany removes several items under a single acquisition of the write
+// lock, leaving a tombstone for each like Delete, and reports for each key
+// whether a live item was removed
+func (dc *DistroCache) DeleteMany(ctx context.Context, keys []string) (map[string]bool, error) {
+	for _, key := range keys {
+		if err := dc.validateKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := dc.removeItems(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for key, deleted := range results {
+		if deleted {
+			dc.applyFanOut(ctx, key)
+		}
+	}
+	return results, nil
+}
+
+// removeItems deletes the given items under one write lock
+func (dc *DistroCache) removeItems(ctx context.Context, keys []string) (map[string]bool, error) {
+	if err := dc.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer dc.unlock()
+
+	for _, key := range keys {
+		if err := dc.authorizeLocked(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	results := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		// Versions are assigned by the cache, so removal cannot be stale
+		results[key], _ = dc.removeLocked(ctx, key, 0)
+	}
+	dc.updateSizeGauges()
+	return results, nil
+}
+
+func (dc *DistroCache) handleDeleteMany(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if dc.config.MaxRequestBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, dc.config.MaxRequestBodyBytes)
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	keys := uniqueStrings(req.Keys)
+	if len(keys) == 0 {
+		http.Error(w, "At least one key is required", http.StatusBadRequest)
+		return
+	}
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		if err := checkKeyNamespace(r, key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scoped[i] = scopeName(r, key)
+	}
+
+	results, err := dc.DeleteMany(r.Context(), scoped)
+	if writeKeyError(w, err) {
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	deleted, notFound := []string{}, []string{}
+	for i, key := range keys {
+		if results[scoped[i]] {
+			deleted = append(deleted, key)
+		} else {
+			notFound = append(notFound, key)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deleted":   deleted,
+		"not_found": notFound,
+	})
+}
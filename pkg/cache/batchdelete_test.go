@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeleteMany(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	for _, key := range []string{"k1", "k3", "k4"} {
+		if err := dc.Set(ctx, key, "v", time.Minute, []string{"group", "tag-" + key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := mustServe(t, dc, http.StatusOK, "DELETE", "/api/v1/cache/batch",
+		map[string][]string{"keys": {"k1", "k2", "k3"}})
+	var result struct {
+		Deleted  []string `json:"deleted"`
+		NotFound []string `json:"not_found"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Deleted, []string{"k1", "k3"}) || !reflect.DeepEqual(result.NotFound, []string{"k2"}) {
+		t.Errorf("batch delete = %+v, want k1 and k3 deleted and k2 not found", result)
+	}
+
+	for _, key := range []string{"k1", "k3"} {
+		if _, found := dc.Peek(ctx, key); found {
+			t.Errorf("%s still present after batch delete", key)
+		}
+	}
+	if _, found := dc.Peek(ctx, "k4"); !found {
+		t.Error("k4 removed by a batch that did not name it")
+	}
+
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	if keys := dc.tagIndex["group"]; !reflect.DeepEqual(keys, []string{"k4"}) {
+		t.Errorf("group tag index = %v, want [k4]", keys)
+	}
+	for _, tag := range []string{"tag-k1", "tag-k3"} {
+		if keys, ok := dc.tagIndex[tag]; ok {
+			t.Errorf("tag %s still indexed with %v", tag, keys)
+		}
+	}
+	if dc.tagEntries != 2 {
+		t.Errorf("tagEntries = %d, want 2", dc.tagEntries)
+	}
+}
+
+func TestDeleteManyRejectsEmptyBatch(t *testing.T) {
+	dc := newTestCache(t, nil)
+	mustServe(t, dc, http.StatusBadRequest, "DELETE", "/api/v1/cache/batch", map[string][]string{"keys": {}})
+}
@@ -0,0 +1,2315 @@
+// Package cache is the DistroCache engine: a tag-aware in-memory cache with
+// pluggable storage, eviction and compression, and the HTTP API served by
+// cmd/cache-server. It can be embedded in another program and used directly
+// through DistroCache's methods without running the HTTP server.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheItem represents a cached item with metadata
+type CacheItem struct {
+	Key          string                 `json:"key"`
+	Value        interface{}            `json:"value"`
+	Version      int64                  `json:"version"`
+	TTL          time.Duration          `json:"ttl"`
+	CreatedAt    time.Time              `json:"created_at"`
+	AccessedAt   time.Time              `json:"accessed_at"`
+	AccessCount  int64                  `json:"access_count"`
+	AccessRate   float64                `json:"access_rate"`
+	Size         int64                  `json:"size"`
+	Tags         []string               `json:"tags,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	OnExpireURL  string                 `json:"on_expire_url,omitempty"`
+	ExternalETag string                 `json:"external_etag,omitempty"`
+	// ContentEncoding is "gzip" when Value holds a gzip-encoded HTTP body as
+	// standard base64, served as is to clients that accept gzip
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	// Compression names the algorithm Compressed holds the JSON-encoded
+	// value in; Value is nil while the value is stored compressed
+	Compression string `json:"compression,omitempty"`
+	Compressed  []byte `json:"compressed,omitempty"`
+	// Chunks is how many pieces of at most ChunkSize, stored as
+	// key:__chunk0 onwards, the encoded value was split into; Value and
+	// Compressed are nil while it is set
+	Chunks       int   `json:"chunks,omitempty"`
+	ChunkedBytes int64 `json:"chunked_bytes,omitempty"`
+	// ACL limits which API keys may read or write the item over HTTP
+	ACL *KeyACL `json:"acl,omitempty"`
+}
+
+// IsExpired checks if the cache item has expired by the system clock; the
+// cache itself checks against CacheConfig.Clock
+func (ci *CacheItem) IsExpired() bool {
+	return ci.ExpiredAt(time.Now())
+}
+
+// ErrStaleVersion is returned when a write carries a version older than
+// the version already stored (or deleted) for the key
+var ErrStaleVersion = errors.New("stale version")
+
+// ErrWriteThrough is returned when the write-through backend rejects a Set
+var ErrWriteThrough = errors.New("write-through failed")
+
+// ErrNotFound is returned when an operation targets a missing or expired key
+var ErrNotFound = errors.New("key not found")
+
+// KeyTooLongError is returned when a key exceeds CacheConfig.MaxKeyLength
+type KeyTooLongError struct {
+	Max    int
+	Actual int
+}
+
+func (e *KeyTooLongError) Error() string {
+	return fmt.Sprintf("key too long: %d bytes exceeds maximum of %d", e.Actual, e.Max)
+}
+
+// TooManyTagsError is returned when an item would carry more tags than
+// CacheConfig.MaxTagsPerKey
+type TooManyTagsError struct {
+	Max    int
+	Actual int
+}
+
+func (e *TooManyTagsError) Error() string {
+	return fmt.Sprintf("too many tags: %d exceeds maximum of %d", e.Actual, e.Max)
+}
+
+// tombstone records a deleted key so that delayed writes carrying an
+// older version cannot resurrect it
+type tombstone struct {
+	Version   int64
+	DeletedAt time.Time
+}
+
+// estimateSize returns the JSON-encoded size of a value in bytes
+func estimateSize(value interface{}) int64 {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// DistroCache represents the main cache structure
+type DistroCache struct {
+	data        StorageBackend
+	tagIndex    map[string][]string // tag -> keys
+	tagEntries  int                 // total keys across tagIndex
+	access      *accessTracker      // read statistics changed since items were stored
+	tagAccess   *tagAccessStats
+	tombstones  map[string]tombstone
+	mutex       sync.RWMutex
+	stats       *CacheStats
+	config      *CacheConfig
+	startedAt   time.Time // uptime counts from here; stats_since moves on reset
+	replicaMu   sync.RWMutex
+	replicas    []string
+	ring        *HashRing
+	loaderMu    sync.RWMutex
+	loaders     []loaderEntry
+	loadGroup   singleflight.Group
+	getGroup    singleflight.Group
+	rulesMu     sync.RWMutex
+	fanOut      []FanOutRule
+	webhooks    *webhookDispatcher
+	writeBehind *writeBehindQueue
+	idempotency *idempotencyStore
+	gcTuner     *gcTuner
+	leader      *leaderElector
+	eviction    EvictionPolicy
+	renderItem  itemRenderer
+	codec       Codec
+	compression *compressionStats
+	registerer  prometheus.Registerer
+	clock       Clock
+
+	// OnEvict callback and the removals it has yet to see, guarded by mutex
+	onEvict  EvictFunc
+	removals []removal
+
+	// Long-polls waiting for a key to expire, guarded by mutex
+	expiryWatchers map[string][]chan struct{}
+
+	// Latest fencing token issued per semaphore still stored, and the last
+	// token issued to any of them, guarded by mutex
+	fencingTokens    map[string]uint64
+	lastFencingToken uint64
+
+	// Chunks of values larger than ChunkSize by chunk key, guarded by
+	// mutex; nil when values are not chunked
+	chunks map[string][]byte
+
+	// What a transaction commit has changed so far, including keys evicted
+	// to make room, so it can be undone; guarded by mutex and nil outside
+	// a commit
+	undo *undoLog
+
+	// Transactions staged but not yet committed
+	transactions *transactionRegistry
+
+	// Background goroutines run until ctx is cancelled by Shutdown
+	ctx        context.Context
+	cancel     context.CancelFunc
+	background sync.WaitGroup
+	closeOnce  sync.Once
+	closeErr   error
+
+	// Stats streams and expiry watches end when streams is cancelled
+	streams      context.Context
+	closeStreams context.CancelFunc
+
+	// Insertion-ordered keys walked by incremental cleanup
+	keyOrder      []string
+	keyPos        map[string]int
+	cleanupCursor int
+
+	// Current cleanup period, which AdaptiveCleanup moves below CleanupInterval
+	cleanupInterval atomic.Int64
+}
+
+// Cleanup strategies
+const (
+	CleanupFull        = "full"
+	CleanupIncremental = "incremental"
+)
+
+// Policies for reads of an expired item
+const (
+	ExpiredReadDelete     = "delete"
+	ExpiredReadKeep       = "keep"
+	ExpiredReadServeStale = "serve_stale"
+)
+
+// CacheConfig holds configuration for the cache
+type CacheConfig struct {
+	MaxSize           int           `json:"max_size"`
+	DefaultTTL        time.Duration `json:"default_ttl"`
+	CleanupInterval   time.Duration `json:"cleanup_interval"`
+	Port              int           `json:"port"`
+	BindAddress       string        `json:"bind_address"`
+	NodeID            string        `json:"node_id"`
+	NodeIDStrategy    string        `json:"node_id_strategy"`
+	ReplicationFactor int           `json:"replication_factor"`
+	VirtualNodes      int           `json:"virtual_nodes"`
+	NodeWeight        int           `json:"node_weight"`
+	TombstoneTTL      time.Duration `json:"tombstone_ttl"`
+
+	// WriteThrough makes every write of a value (sets, increments, merge
+	// patches and transaction commits) persist it with WriteThroughFn before
+	// it is stored in memory. WriteThroughFn runs under the cache write lock
+	// once the write has passed every check, so the backend only sees writes
+	// the cache applies, in the order it applies them; a slow backend holds
+	// up every other write and read while it runs.
+	WriteThrough   bool                                      `json:"write_through"`
+	WriteThroughFn func(key string, value interface{}) error `json:"-"`
+
+	// WriteBehind queues modified values and flushes them to WriteBehindFn
+	// every WriteBehindInterval, so Sets return without waiting on the backend
+	WriteBehind         bool                                      `json:"write_behind"`
+	WriteBehindInterval time.Duration                             `json:"write_behind_interval"`
+	WriteBehindFn       func(key string, value interface{}) error `json:"-"`
+
+	// CleanupStrategy is "full" to sweep every item per tick, or "incremental"
+	// to examine at most CleanupBatchSize items per tick
+	CleanupStrategy  string `json:"cleanup_strategy"`
+	CleanupBatchSize int    `json:"cleanup_batch_size"`
+
+	// AdaptiveCleanup halves the cleanup period, down to a second, after a
+	// tick that found more than 10% of the items it examined expired, and
+	// doubles it back up to CleanupInterval after one that found under 1%
+	AdaptiveCleanup bool `json:"adaptive_cleanup"`
+
+	WebhookWorkers int `json:"webhook_workers"`
+	WebhookRetries int `json:"webhook_retries"`
+
+	// HTTP server limits; zero values fall back to the defaults in NewHTTPServer
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+	MaxHeaderBytes    int           `json:"max_header_bytes"`
+
+	// EnableH2C serves HTTP/2 over cleartext TCP (h2c) alongside HTTP/1.1,
+	// so many concurrent requests can share a few connections. Clients must
+	// speak HTTP/2 with prior knowledge; Upgrade: h2c is not supported.
+	EnableH2C bool `json:"enable_h2c"`
+	// MaxConcurrentStreams caps the in-flight requests per HTTP/2
+	// connection; zero uses Go's default of 250
+	MaxConcurrentStreams int `json:"max_concurrent_streams"`
+	// DisableKeepAlives closes each HTTP/1.1 connection after one request
+	DisableKeepAlives bool `json:"disable_keep_alives"`
+
+	// MissCost is the estimated latency of an origin call, used to report
+	// the latency saved by cache hits
+	MissCost time.Duration `json:"miss_cost"`
+
+	// MaxKeyLength limits key size in bytes; zero means unlimited
+	MaxKeyLength int `json:"max_key_length"`
+	// MaxTagsPerKey limits how many tags one item may carry; zero means
+	// unlimited
+	MaxTagsPerKey int `json:"max_tags_per_key"`
+
+	// MaxRequestBodyBytes caps the size of a set request body; zero means unlimited
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes"`
+
+	// IdempotencyWindowSeconds is how long a set response is remembered for
+	// replay to requests carrying the same X-Idempotency-Key; zero disables it
+	IdempotencyWindowSeconds int `json:"idempotency_window_seconds"`
+
+	// IdempotencyMaxKeys bounds the number of remembered idempotency keys;
+	// the oldest are forgotten first
+	IdempotencyMaxKeys int `json:"idempotency_max_keys"`
+
+	// CoalesceGets shares one Get and JSON encode between concurrent GET
+	// requests for the same key
+	CoalesceGets bool `json:"coalesce_gets"`
+
+	// EvictionPolicy chooses victims when the cache is full: "lru" (default),
+	// "slru" (segmented LRU that protects repeatedly read keys), "arc"
+	// (adaptive replacement, self-tuning between recency and frequency) or
+	// "lru-k" (evicts by the LRUKth most recent access)
+	EvictionPolicy string `json:"eviction_policy"`
+	// LRUK is how many recent accesses "lru-k" tracks per key (default 2)
+	LRUK int `json:"lru_k"`
+
+	// Backend stores the cache items; nil keeps them in an in-memory map.
+	// Items already in the backend are indexed at startup.
+	Backend StorageBackend `json:"-"`
+
+	// Registry receives the cache's Prometheus metrics and is served on
+	// /metrics; nil uses the global default registry. Give each cache its own
+	// registry to run several in one process, since registering the same
+	// metrics twice with one registry panics.
+	Registry *prometheus.Registry `json:"-"`
+
+	// OnEvict is called with every item removed from the cache and the
+	// reason, after the write lock is released; see SetOnEvict
+	OnEvict EvictFunc `json:"-"`
+
+	// MMapBackend stores items in fixed-size slots of the memory-mapped
+	// MMapFile instead of the Go heap. Items whose encoding exceeds
+	// MMapSlotSize bytes are rejected.
+	MMapBackend  bool   `json:"mmap_backend"`
+	MMapFile     string `json:"mmap_file"`
+	MMapSlotSize int    `json:"mmap_slot_size"`
+
+	// ExpiredReadPolicy decides what a read of an expired item does: delete it
+	// and miss ("delete", the default), miss but leave it in place ("keep"),
+	// or return it as a stale hit ("serve_stale")
+	ExpiredReadPolicy string `json:"expired_read_policy"`
+
+	// HitRateAlertThreshold raises a low_hit_rate alert on AlertWebhookURL when
+	// the hit rate stays below it for two consecutive AlertCheckInterval samples
+	HitRateAlertThreshold float64       `json:"hit_rate_alert_threshold"`
+	AlertWebhookURL       string        `json:"alert_webhook_url"`
+	AlertCheckInterval    time.Duration `json:"alert_check_interval"`
+
+	// BackpressureThreshold is the fraction of MaxSize (e.g. 0.9) at which
+	// responses carry X-Cache-Backpressure and X-Cache-Load headers; 0
+	// disables them
+	BackpressureThreshold float64 `json:"backpressure_threshold"`
+
+	// CompressionAlgo compresses stored values with "gzip" or "zstd"; "none"
+	// (default) stores them as is. Each item records its algorithm, so items
+	// stay readable after the setting changes.
+	CompressionAlgo string `json:"compression_algo"`
+	// CompressionMinBytes is the JSON size below which values are stored
+	// uncompressed
+	CompressionMinBytes int `json:"compression_min_bytes"`
+
+	// ChunkSize splits values whose encoded size exceeds it into chunks of
+	// at most this many bytes, so a large value is many small allocations
+	// rather than one. Zero stores values whole. Only the in-memory backend
+	// chunks; the others keep items encoded already.
+	ChunkSize int `json:"chunk_size"`
+	// StreamChunkSize is how many bytes of a value GET ?stream=true writes
+	// and flushes at a time
+	StreamChunkSize int `json:"stream_chunk_size"`
+
+	// ResponseFieldStyle names the fields of items returned by GET:
+	// "snake_case" (default, e.g. access_count) or "camelCase" (accessCount)
+	ResponseFieldStyle string `json:"response_field_style"`
+
+	// MinResidency spares items written less than this long ago from
+	// eviction unless every item is that young, so a burst of writes cannot
+	// evict its own earlier writes before they are read; 0 disables it
+	MinResidency time.Duration `json:"min_residency"`
+
+	// OperationTimeout bounds how long a write waits for the cache lock, e.g.
+	// behind a long cleanup sweep. Writes that time out fail with
+	// ErrOperationTimeout (503 over HTTP); 0 waits indefinitely.
+	OperationTimeout time.Duration `json:"operation_timeout"`
+
+	// ScanLockTimeout bounds how long a key listing holds the read lock, and
+	// so how long it can hold up writes; listings that run over return the
+	// keys found so far, marked truncated (default 250ms)
+	ScanLockTimeout time.Duration `json:"scan_lock_timeout"`
+
+	// TransactionTimeout is how long a transaction may stay open before its
+	// staged writes are discarded (default 30s)
+	TransactionTimeout time.Duration `json:"transaction_timeout"`
+
+	// LatencyWindow is the period covered by the get/set latency
+	// percentiles in /stats (default 1 minute), which drops its oldest
+	// LatencyRotation (default 10s) of samples at a time
+	LatencyWindow   time.Duration `json:"latency_window"`
+	LatencyRotation time.Duration `json:"latency_rotation"`
+
+	// TTLBuckets are the boundaries of the ttl_distribution buckets in
+	// /stats (default 1m and 5m, giving lt_1m, 1m_to_5m and gt_5m)
+	TTLBuckets []time.Duration `json:"ttl_buckets"`
+
+	// MaxTTL rejects sets asking for a longer TTL with 400; zero allows any
+	// TTL up to the largest a time.Duration can hold
+	MaxTTL time.Duration `json:"max_ttl"`
+
+	// AutoGCTuning raises the GC percent from 100 to 400 while the heap is
+	// above 80% of MaxMemoryBytes, deferring collections, and lowers it again
+	// once the heap is below half. The heap is sampled every GCTuningInterval
+	// (default 10s). The GC percent is process-wide, so enable it on one cache
+	// per process.
+	AutoGCTuning     bool          `json:"auto_gc_tuning"`
+	MaxMemoryBytes   int64         `json:"max_memory_bytes"`
+	GCTuningInterval time.Duration `json:"gc_tuning_interval"`
+
+	// StatsStreamInterval is how often /stats/stream pushes an event
+	// (default 1s)
+	StatsStreamInterval time.Duration `json:"stats_stream_interval"`
+
+	// CORS decides which browser origins may call the API
+	CORS CORSConfig `json:"cors"`
+
+	// StorageFormat is how the bolt, badger and mmap backends encode items:
+	// "gob" (the default) keeps the Go types of values, "json" is
+	// human-readable but reads numbers back as json.Number and nested values
+	// as maps and slices. Items in either format are read back regardless.
+	StorageFormat string `json:"storage_format"`
+
+	// ClusterStatsTimeout bounds how long /cluster/stats waits for each
+	// node's stats before reporting it unreachable (default 2s)
+	ClusterStatsTimeout time.Duration `json:"cluster_stats_timeout"`
+
+	// AdminAPIKey, when set, is the X-API-Key that requests to the /admin
+	// routes, the stats reset and node registration must send; other callers
+	// get 403. It is empty by default, which leaves those endpoints open to
+	// every caller.
+	AdminAPIKey string `json:"-"`
+
+	// LeaderElection makes the nodes elect a coordinator: every
+	// LeaderHeartbeatInterval each node tries to take a single-slot
+	// semaphore with a ttl of two intervals, on the cache server at
+	// LeaderElectionURL (all nodes must use the same one) or, when it is
+	// empty, on this cache. See IsLeader and RunOnLeader.
+	LeaderElection          bool          `json:"leader_election"`
+	LeaderElectionURL       string        `json:"leader_election_url"`
+	LeaderHeartbeatInterval time.Duration `json:"leader_heartbeat_interval"`
+
+	// Clock is the time source for expiry, item timestamps and windowed
+	// stats; nil uses SystemClock. Tests can pass a MockClock to expire items
+	// without sleeping.
+	Clock Clock `json:"-"`
+
+	// LogLevel is the minimum level of the structured JSON logs: "debug",
+	// "info" (default), "warn" or "error"
+	LogLevel string `json:"log_level"`
+}
+
+// CacheStats tracks cache performance metrics
+type CacheStats struct {
+	Hits          prometheus.Counter
+	Misses        prometheus.Counter
+	Sets          prometheus.Counter
+	Deletes       prometheus.Counter
+	Evictions     prometheus.Counter
+	TotalItems    prometheus.Gauge
+	MemoryUsage   prometheus.Gauge
+	AvgAccessTime prometheus.Histogram
+	CapacityUsed  prometheus.Gauge
+	LoadFactor    prometheus.Gauge
+	LockTimeouts  prometheus.Counter
+	LockWait      *prometheus.HistogramVec
+	EvictionRate  prometheus.GaugeFunc
+	WebhookFails  prometheus.Counter
+	OriginSaved   prometheus.Counter
+	BytesServed   prometheus.Counter
+	GCPause       prometheus.Histogram
+	evictions     *rateWindow
+
+	// LockWait children for the read and write lock
+	readLockWait  prometheus.Observer
+	writeLockWait prometheus.Observer
+
+	// Live latency quantiles of Get and Set for /stats
+	getLatency *latencyWindow
+	setLatency *latencyWindow
+
+	hitCount      atomic.Int64
+	missCount     atomic.Int64
+	setCount      atomic.Int64
+	deleteCount   atomic.Int64
+	evictionCount atomic.Int64
+	bytesServed   atomic.Int64
+
+	// Counters reported by GetStats start from epoch, the last reset
+	epoch     atomic.Pointer[statsEpoch]
+	startedAt time.Time
+	now       func() time.Time
+}
+
+// recordHit counts a cache hit serving a value of the given size
+func (cs *CacheStats) recordHit(size int64) {
+	cs.Hits.Inc()
+	cs.OriginSaved.Inc()
+	cs.BytesServed.Add(float64(size))
+	cs.hitCount.Add(1)
+	cs.bytesServed.Add(size)
+}
+
+// recordMiss counts a cache miss
+func (cs *CacheStats) recordMiss() {
+	cs.Misses.Inc()
+	cs.missCount.Add(1)
+}
+
+// recordSet counts a stored item
+func (cs *CacheStats) recordSet() {
+	cs.Sets.Inc()
+	cs.setCount.Add(1)
+}
+
+// recordDelete counts a deleted item
+func (cs *CacheStats) recordDelete() {
+	cs.Deletes.Inc()
+	cs.deleteCount.Add(1)
+}
+
+// recordEviction counts an evicted item
+func (cs *CacheStats) recordEviction() {
+	cs.Evictions.Inc()
+	cs.evictionCount.Add(1)
+	cs.evictions.Add(1)
+}
+
+// rateWindow counts events over a sliding window of one-second buckets
+type rateWindow struct {
+	mutex   sync.Mutex
+	buckets []int64
+	seconds []int64
+	now     func() time.Time
+}
+
+// newRateWindow creates a window covering the given number of seconds
+func newRateWindow(seconds int, now func() time.Time) *rateWindow {
+	return &rateWindow{
+		buckets: make([]int64, seconds),
+		seconds: make([]int64, seconds),
+		now:     now,
+	}
+}
+
+// Add records n events at the current time
+func (rw *rateWindow) Add(n int64) {
+	now := rw.now().Unix()
+	idx := int(now % int64(len(rw.buckets)))
+
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if rw.seconds[idx] != now {
+		rw.seconds[idx] = now
+		rw.buckets[idx] = 0
+	}
+	rw.buckets[idx] += n
+}
+
+// Reset forgets every event recorded so far
+func (rw *rateWindow) Reset() {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	clear(rw.buckets)
+	clear(rw.seconds)
+}
+
+// Rate returns the average number of events per second across the window
+func (rw *rateWindow) Rate() float64 {
+	now := rw.now().Unix()
+	window := int64(len(rw.buckets))
+
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	var total int64
+	for i, count := range rw.buckets {
+		if now-rw.seconds[i] < window {
+			total += count
+		}
+	}
+	return float64(total) / float64(window)
+}
+
+// NewDistroCache creates a new distributed cache instance
+func NewDistroCache(config *CacheConfig) *DistroCache {
+	if nodeID, err := resolveNodeID(config); err != nil {
+		log.Printf("node id strategy %q failed, using %q: %v", config.NodeIDStrategy, config.NodeID, err)
+	} else {
+		config.NodeID = nodeID
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	stats := &CacheStats{
+		Hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_hits_total",
+			Help: "Total number of cache hits",
+		}),
+		Misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_misses_total",
+			Help: "Total number of cache misses",
+		}),
+		Sets: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_sets_total",
+			Help: "Total number of cache sets",
+		}),
+		Deletes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_deletes_total",
+			Help: "Total number of cache deletes",
+		}),
+		Evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_evictions_total",
+			Help: "Total number of cache evictions",
+		}),
+		TotalItems: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "distrocache_items_total",
+			Help: "Total number of items in cache",
+		}),
+		MemoryUsage: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "distrocache_memory_bytes",
+			Help: "Memory usage in bytes",
+		}),
+		AvgAccessTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "distrocache_access_duration_seconds",
+			Help: "Cache access duration in seconds",
+		}),
+		CapacityUsed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "distrocache_capacity_used_ratio",
+			Help: "Ratio of stored items to the configured maximum size",
+		}),
+		LoadFactor: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "distrocache_load_factor",
+			Help: "Ratio of stored items to the configured maximum size, compared against the backpressure threshold",
+		}),
+		LockTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_lock_timeouts_total",
+			Help: "Total number of operations that gave up waiting for the cache lock",
+		}),
+		LockWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "distrocache_lock_wait_seconds",
+			Help: "Time spent waiting to acquire the cache lock, by read or write mode",
+			// 1µs to ~0.26s; the default buckets start at 5ms, above most waits
+			Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10),
+		}, []string{"mode"}),
+		WebhookFails: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_expiry_webhook_failures_total",
+			Help: "Total number of expiry webhook deliveries that failed or were dropped",
+		}),
+		OriginSaved: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_origin_calls_avoided_total",
+			Help: "Total number of origin calls avoided by serving from cache",
+		}),
+		BytesServed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "distrocache_bytes_served_total",
+			Help: "Total estimated bytes of values served from cache",
+		}),
+		GCPause: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "distrocache_gc_pause_seconds",
+			Help: "Garbage collection pause durations, sampled while AutoGCTuning is enabled",
+			// 10µs to ~2.6s
+			Buckets: prometheus.ExponentialBuckets(1e-5, 4, 10),
+		}),
+		evictions:  newRateWindow(60, clock.Now),
+		getLatency: newLatencyWindow(config.LatencyWindow, config.LatencyRotation, clock.Now),
+		setLatency: newLatencyWindow(config.LatencyWindow, config.LatencyRotation, clock.Now),
+		startedAt:  clock.Now(),
+		now:        clock.Now,
+	}
+	stats.readLockWait = stats.LockWait.WithLabelValues("read")
+	stats.writeLockWait = stats.LockWait.WithLabelValues("write")
+	stats.EvictionRate = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "distrocache_eviction_rate",
+		Help: "Evictions per second averaged over the last minute",
+	}, stats.evictions.Rate)
+
+	registerer := prometheus.DefaultRegisterer
+	if config.Registry != nil {
+		registerer = config.Registry
+	}
+
+	// Register metrics
+	registerer.MustRegister(stats.Hits, stats.Misses, stats.Sets, stats.Deletes,
+		stats.Evictions, stats.TotalItems, stats.MemoryUsage, stats.AvgAccessTime,
+		stats.CapacityUsed, stats.LoadFactor, stats.LockTimeouts, stats.EvictionRate, stats.WebhookFails, stats.OriginSaved,
+		stats.BytesServed, stats.LockWait, stats.GCPause)
+
+	compression := newCompressionStats()
+	registerer.MustRegister(compression.rawBytes, compression.compressedBytes)
+
+	cache := &DistroCache{
+		data:       NewInMemoryBackend(),
+		tagIndex:   make(map[string][]string),
+		tagAccess:  newTagAccessStats(),
+		access:     newAccessTracker(),
+		tombstones: make(map[string]tombstone),
+		stats:      stats,
+		config:     config,
+		startedAt:  clock.Now(),
+		replicas:   make([]string, 0),
+		ring:       NewHashRing(config.VirtualNodes),
+		webhooks:   newWebhookDispatcher(config.WebhookWorkers, config.WebhookRetries, stats.WebhookFails),
+
+		compression:  compression,
+		registerer:   registerer,
+		transactions: newTransactionRegistry(config.TransactionTimeout, clock.Now),
+		onEvict:      config.OnEvict,
+		clock:        clock,
+	}
+	cache.registerTagMetrics()
+	cache.ctx, cache.cancel = context.WithCancel(context.Background())
+	cache.streams, cache.closeStreams = context.WithCancel(cache.ctx)
+	for i := 0; i < cache.webhooks.workers; i++ {
+		cache.goBackground(cache.webhooks.run)
+	}
+
+	// Register this node on the hash ring
+	cache.ring.AddNode(ClusterNode{ID: config.NodeID, Weight: config.NodeWeight})
+
+	if config.CleanupStrategy == CleanupIncremental {
+		cache.keyPos = make(map[string]int)
+	}
+
+	if config.Backend != nil {
+		cache.data = config.Backend
+	} else if config.MMapBackend {
+		backend, err := newMMapBackend(config.MMapFile, config.MaxSize+1, config.MMapSlotSize)
+		if err != nil {
+			log.Printf("mmap backend unavailable, keeping items in memory: %v", err)
+		} else {
+			cache.data = backend
+		}
+	}
+
+	if config.ChunkSize > 0 {
+		if _, inMemory := cache.data.(*InMemoryBackend); inMemory {
+			cache.chunks = make(map[string][]byte)
+		} else {
+			log.Printf("ChunkSize only applies to the in-memory backend, storing values whole")
+		}
+	}
+
+	policy, err := newEvictionPolicy(config.EvictionPolicy, config.MaxSize, config.LRUK)
+	if err != nil {
+		log.Printf("%v, falling back to %s", err, EvictionLRU)
+	}
+	cache.eviction = policy
+
+	renderer, err := newItemRenderer(config.ResponseFieldStyle)
+	if err != nil {
+		log.Printf("%v, falling back to %s", err, ResponseFieldsSnakeCase)
+		renderer, _ = newItemRenderer(ResponseFieldsSnakeCase)
+	}
+	cache.renderItem = renderer
+
+	codec, err := codecFor(config.CompressionAlgo)
+	if err != nil {
+		log.Printf("%v, storing values uncompressed", err)
+	}
+	cache.codec = codec
+
+	if backend, ok := cache.data.(formattedBackend); ok {
+		format := config.StorageFormat
+		if err := validStorageFormat(format); err != nil {
+			log.Printf("%v, falling back to %s", err, StorageFormatGob)
+			format = StorageFormatGob
+		}
+		backend.setFormat(format)
+	}
+
+	if config.Backend != nil {
+		cache.indexExisting()
+	}
+
+	if config.WriteBehind && config.WriteBehindFn != nil {
+		cache.writeBehind = newWriteBehindQueue(config.WriteBehindFn, config.WriteBehindInterval)
+		cache.goBackground(cache.writeBehind.run)
+	}
+
+	if config.IdempotencyWindowSeconds > 0 {
+		cache.idempotency = newIdempotencyStore(time.Duration(config.IdempotencyWindowSeconds)*time.Second, config.IdempotencyMaxKeys, clock.Now)
+	}
+
+	if config.HitRateAlertThreshold > 0 && config.AlertWebhookURL != "" {
+		cache.goBackground(newHitRateMonitor(cache).run)
+	}
+
+	if config.AutoGCTuning {
+		if config.MaxMemoryBytes > 0 {
+			cache.gcTuner = newGCTuner(cache)
+			cache.goBackground(cache.gcTuner.run)
+		} else {
+			log.Printf("AutoGCTuning needs MaxMemoryBytes, leaving the GC untuned")
+		}
+	}
+
+	if config.LeaderElection {
+		cache.leader = newLeaderElector(cache)
+		cache.goBackground(cache.leader.run)
+	}
+
+	// Start cleanup goroutine; with no interval, expired items are only
+	// removed when read or by an explicit Cleanup
+	if config.CleanupInterval > 0 {
+		cache.cleanupInterval.Store(int64(config.CleanupInterval))
+		cache.goBackground(cache.startCleanup)
+	}
+
+	return cache
+}
+
+// hashKey creates a consistent hash for key distribution
+func (dc *DistroCache) hashKey(key string) string {
+	hasher := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hasher[:])
+}
+
+// shouldOwnKey determines if this node should own the given key
+func (dc *DistroCache) shouldOwnKey(key string) bool {
+	return dc.ResponsibleNode(key) == dc.config.NodeID
+}
+
+// ResponsibleNode returns the ID of the cluster node that owns the given key
+func (dc *DistroCache) ResponsibleNode(key string) string {
+	return dc.ring.ResponsibleNode(key)
+}
+
+// RegisterNode adds or updates a cluster node on the hash ring
+func (dc *DistroCache) RegisterNode(node ClusterNode) {
+	dc.ring.AddNode(node)
+}
+
+// Get retrieves an item from the cache. On a miss, a registered loader
+// matching the key is used to populate the cache.
+func (dc *DistroCache) Get(ctx context.Context, key string) (*CacheItem, bool) {
+	if item, found := dc.get(ctx, key); found {
+		slog.DebugContext(ctx, "cache get", "key", key, "hit", true)
+		return item, true
+	}
+
+	if loader, ok := dc.findLoader(key); ok {
+		item, found := dc.loadThrough(ctx, key, loader)
+		slog.DebugContext(ctx, "cache get", "key", key, "hit", false, "loaded", found)
+		return item, found
+	}
+
+	slog.DebugContext(ctx, "cache get", "key", key, "hit", false)
+	return nil, false
+}
+
+// get looks up an item without consulting loaders
+func (dc *DistroCache) get(ctx context.Context, key string) (*CacheItem, bool) {
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		dc.stats.AvgAccessTime.Observe(elapsed.Seconds())
+		dc.stats.getLatency.Observe(elapsed)
+	}()
+
+	dc.rlock()
+	defer dc.mutex.RUnlock()
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists {
+		dc.stats.recordMiss()
+		dc.tagAccess.recordMiss(key, nil)
+		return nil, false
+	}
+
+	if dc.isExpired(item) {
+		switch dc.config.ExpiredReadPolicy {
+		case ExpiredReadServeStale:
+			// Fall through to a hit; callers can detect staleness via ExpiredAt
+		case ExpiredReadKeep:
+			dc.stats.recordMiss()
+			dc.tagAccess.recordMiss(key, item.Tags)
+			return nil, false
+		default:
+			dc.stats.recordMiss()
+			dc.tagAccess.recordMiss(key, item.Tags)
+			// Clean up expired item
+			go dc.deleteExpired(context.WithoutCancel(ctx), key)
+			return nil, false
+		}
+	}
+
+	dc.access.record(key, item, dc.now())
+	if dc.eviction != nil {
+		dc.eviction.Access(key)
+	}
+
+	view, err := dc.inflateStored(item)
+	if err != nil {
+		slog.ErrorContext(ctx, "cache get failed", "key", key, "error", err)
+		dc.stats.recordMiss()
+		dc.tagAccess.recordMiss(key, item.Tags)
+		return nil, false
+	}
+	dc.stats.recordHit(item.Size)
+	dc.tagAccess.recordHit(item.Tags)
+	return dc.withAccessStats(key, view), true
+}
+
+// Peek returns an item without recording a hit or miss, updating its access
+// statistics or consulting loaders
+func (dc *DistroCache) Peek(ctx context.Context, key string) (*CacheItem, bool) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || dc.isExpired(item) {
+		return nil, false
+	}
+
+	view, err := dc.inflateStored(item)
+	if err != nil {
+		slog.ErrorContext(ctx, "cache peek failed", "key", key, "error", err)
+		return nil, false
+	}
+	return dc.withAccessStats(key, view), true
+}
+
+// peekStored returns an unexpired item as stored, possibly compressed,
+// without touching its statistics
+func (dc *DistroCache) peekStored(ctx context.Context, key string) (*CacheItem, bool) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || dc.isExpired(item) {
+		return nil, false
+	}
+	return item, true
+}
+
+// SetOptions holds optional attributes for a Set operation
+type SetOptions struct {
+	// Version is the version to store the item under. When zero the cache
+	// assigns a version newer than any existing version of the key.
+	// Writes with an explicit version older than the stored or deleted
+	// version are rejected with ErrStaleVersion.
+	Version int64
+	// OnExpireURL receives a POST with the key and tags when the item expires
+	OnExpireURL string
+	// ExternalETag is the ETag of the upstream resource the value was built from
+	ExternalETag string
+	// ContentEncoding marks a value holding an already gzip-encoded HTTP body
+	// in standard base64; see ContentEncodingGzip
+	ContentEncoding string
+	// Metadata is stored alongside the value, e.g. source system or content type
+	Metadata map[string]interface{}
+	// ACL restricts which API keys may read or write the item over HTTP. When
+	// nil, a live item being replaced keeps its ACL; an ACL with empty lists
+	// removes it.
+	ACL *KeyACL
+}
+
+// Set stores an item in the cache
+func (dc *DistroCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration, tags []string) error {
+	return dc.SetWithOptions(ctx, key, value, ttl, tags, SetOptions{})
+}
+
+// SetWithOptions stores an item in the cache using the given options.
+// In write-through mode the value is persisted with WriteThroughFn first,
+// and the Set is aborted if persisting fails; a Set the cache rejects, e.g.
+// for a stale version, is not persisted.
+func (dc *DistroCache) SetWithOptions(ctx context.Context, key string, value interface{}, ttl time.Duration, tags []string, opts SetOptions) error {
+	if err := dc.validateKey(key); err != nil {
+		return err
+	}
+	if err := dc.validateTags(tags); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err := dc.storeItem(ctx, key, value, ttl, tags, opts)
+	dc.stats.setLatency.Observe(time.Since(start))
+	if err != nil {
+		slog.DebugContext(ctx, "cache set rejected", "key", key, "error", err)
+		return err
+	}
+	slog.DebugContext(ctx, "cache set", "key", key, "ttl", ttl, "tags", tags)
+
+	dc.applyFanOut(ctx, key)
+	return nil
+}
+
+// storeItem stores a new item under the write lock
+func (dc *DistroCache) storeItem(ctx context.Context, key string, value interface{}, ttl time.Duration, tags []string, opts SetOptions) error {
+	compressed, err := dc.compressValue(value)
+	if err != nil {
+		return err
+	}
+
+	if err := dc.lock(ctx); err != nil {
+		return err
+	}
+	defer dc.unlock()
+
+	if err := dc.authorizeLocked(ctx, key); err != nil {
+		return err
+	}
+
+	if err := dc.checkVersion(ctx, key, opts.Version); err != nil {
+		return err
+	}
+	if err := dc.writeThrough(ctx, key, value); err != nil {
+		return err
+	}
+	if err := dc.storeLocked(ctx, key, value, compressed, ttl, tags, opts); err != nil {
+		return err
+	}
+	if dc.writeBehind != nil {
+		dc.writeBehind.MarkDirty(key, value)
+	}
+	return nil
+}
+
+// checkVersion returns ErrStaleVersion when version is set and not newer
+// than the version stored (or deleted) for key; callers must hold the lock
+func (dc *DistroCache) checkVersion(ctx context.Context, key string, version int64) error {
+	if version != 0 && version <= dc.currentVersion(ctx, key) {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// writeThrough persists value with WriteThroughFn in write-through mode.
+// Callers must hold the write lock and have made every check that could
+// still reject the write.
+func (dc *DistroCache) writeThrough(ctx context.Context, key string, value interface{}) error {
+	if !dc.config.WriteThrough || dc.config.WriteThroughFn == nil {
+		return nil
+	}
+	if err := dc.config.WriteThroughFn(key, value); err != nil {
+		slog.WarnContext(ctx, "write-through failed", "key", key, "error", err)
+		return fmt.Errorf("%w: %v", ErrWriteThrough, err)
+	}
+	return nil
+}
+
+// storeLocked stores an item whose value was already compressed by
+// compressValue, without writing it through; callers must hold the write
+// lock
+func (dc *DistroCache) storeLocked(ctx context.Context, key string, value interface{}, compressed []byte, ttl time.Duration, tags []string, opts SetOptions) error {
+	if err := dc.checkVersion(ctx, key, opts.Version); err != nil {
+		return err
+	}
+	version := opts.Version
+	if version == 0 {
+		version = dc.nextVersion(ctx, key)
+	}
+
+	// Check if we're at capacity and need to evict
+	oldItem, replacing := dc.data.Get(ctx, key)
+	if !replacing && dc.data.Len() >= dc.config.MaxSize {
+		dc.evict(ctx)
+	}
+
+	acl := normalizeACL(opts.ACL)
+	if opts.ACL == nil && replacing && !dc.isExpired(oldItem) {
+		acl = oldItem.ACL
+	}
+
+	now := dc.now()
+	item := &CacheItem{
+		Key:             key,
+		Value:           value,
+		Size:            estimateSize(value),
+		Version:         version,
+		TTL:             ttl,
+		CreatedAt:       now,
+		AccessedAt:      now,
+		AccessCount:     1,
+		Tags:            tags,
+		Metadata:        copyMetadata(opts.Metadata),
+		OnExpireURL:     opts.OnExpireURL,
+		ExternalETag:    opts.ExternalETag,
+		ACL:             acl,
+		ContentEncoding: opts.ContentEncoding,
+	}
+	if compressed != nil {
+		item.Value = nil
+		item.Compression = dc.codec.Name()
+		item.Compressed = compressed
+	}
+
+	if replacing {
+		dc.dropChunks(key)
+	}
+	if err := dc.chunkItem(key, item); err != nil {
+		return err
+	}
+
+	if err := dc.putItem(ctx, key, item); err != nil {
+		return err
+	}
+
+	// Remove old item from tag index if it exists
+	if replacing {
+		dc.removeFromTagIndex(key, oldItem.Tags)
+	}
+	delete(dc.tombstones, key)
+	dc.addToTagIndex(key, tags)
+	dc.tagAccess.learn(key, tags)
+	dc.stats.recordSet()
+	dc.updateSizeGauges()
+	return nil
+}
+
+// currentVersion returns the newest version known for a key, taking
+// tombstones into account; callers must hold the lock
+func (dc *DistroCache) currentVersion(ctx context.Context, key string) int64 {
+	var version int64
+	if item, exists := dc.data.Get(ctx, key); exists {
+		version = item.Version
+	}
+	if ts, exists := dc.tombstones[key]; exists && ts.Version > version {
+		version = ts.Version
+	}
+	return version
+}
+
+// validateKey checks a key against the configured limits
+func (dc *DistroCache) validateKey(key string) error {
+	if dc.config.MaxKeyLength > 0 && len(key) > dc.config.MaxKeyLength {
+		return &KeyTooLongError{Max: dc.config.MaxKeyLength, Actual: len(key)}
+	}
+	return nil
+}
+
+// validateTags checks the tags of an item against the configured limits
+func (dc *DistroCache) validateTags(tags []string) error {
+	if dc.config.MaxTagsPerKey > 0 && len(tags) > dc.config.MaxTagsPerKey {
+		return &TooManyTagsError{Max: dc.config.MaxTagsPerKey, Actual: len(tags)}
+	}
+	return nil
+}
+
+// nextVersion returns a version newer than any known version of key; callers must hold the lock
+func (dc *DistroCache) nextVersion(ctx context.Context, key string) int64 {
+	current := dc.currentVersion(ctx, key)
+	version := dc.now().UnixNano()
+	if version <= current {
+		version = current + 1
+	}
+	return version
+}
+
+// Delete removes an item from the cache
+func (dc *DistroCache) Delete(ctx context.Context, key string) bool {
+	deleted, _ := dc.DeleteWithVersion(ctx, key, 0)
+	return deleted
+}
+
+// DeleteWithVersion removes an item from the cache and leaves a tombstone
+// behind so that writes older than the delete cannot resurrect the key.
+// When version is zero the tombstone is newer than any known version.
+// It reports whether a live item was removed.
+func (dc *DistroCache) DeleteWithVersion(ctx context.Context, key string, version int64) (bool, error) {
+	if err := dc.validateKey(key); err != nil {
+		return false, err
+	}
+
+	deleted, err := dc.removeItem(ctx, key, version)
+	slog.DebugContext(ctx, "cache delete", "key", key, "deleted", deleted)
+	if deleted {
+		dc.applyFanOut(ctx, key)
+	}
+	return deleted, err
+}
+
+// removeItem deletes an item and records its tombstone under the write lock
+func (dc *DistroCache) removeItem(ctx context.Context, key string, version int64) (bool, error) {
+	if err := dc.lock(ctx); err != nil {
+		return false, err
+	}
+	defer dc.unlock()
+
+	if err := dc.authorizeLocked(ctx, key); err != nil {
+		return false, err
+	}
+
+	deleted, err := dc.removeLocked(ctx, key, version)
+	if deleted {
+		dc.updateSizeGauges()
+	}
+	return deleted, err
+}
+
+// removeLocked deletes an item and records its tombstone; callers must hold
+// the write lock and refresh the size gauges
+func (dc *DistroCache) removeLocked(ctx context.Context, key string, version int64) (bool, error) {
+	if version == 0 {
+		version = dc.nextVersion(ctx, key)
+	} else if version <= dc.currentVersion(ctx, key) {
+		return false, ErrStaleVersion
+	}
+
+	dc.tombstones[key] = tombstone{Version: version, DeletedAt: dc.now()}
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists {
+		return false, nil
+	}
+
+	dc.removeFromTagIndex(key, item.Tags)
+	dc.removed(item, EvictReasonDeleted)
+	dc.dropItem(ctx, key)
+	dc.stats.recordDelete()
+	return true, nil
+}
+
+// deleteExpired removes a key found expired on read, unless it has been
+// replaced in the meantime. If the lock is busy it is left for cleanup.
+func (dc *DistroCache) deleteExpired(ctx context.Context, key string) {
+	if dc.lock(ctx) != nil {
+		return
+	}
+	defer dc.unlock()
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || !dc.isExpired(item) {
+		return
+	}
+
+	dc.expireItem(ctx, key, item)
+	dc.updateSizeGauges()
+}
+
+// expireItem removes an expired item, wakes its expiry watches and fires its
+// expiry webhook; callers must hold the write lock
+func (dc *DistroCache) expireItem(ctx context.Context, key string, item *CacheItem) {
+	dc.removeFromTagIndex(key, item.Tags)
+	dc.removed(item, EvictReasonExpired)
+	dc.dropItem(ctx, key)
+	dc.notifyExpired(key)
+
+	if item.OnExpireURL != "" {
+		dc.webhooks.Notify(ctx, item.OnExpireURL, key, item.Tags)
+	}
+}
+
+// InvalidateByTag removes all items with a specific tag
+func (dc *DistroCache) InvalidateByTag(ctx context.Context, tag string) (int, error) {
+	keys, err := dc.InvalidateTagKeys(ctx, tag)
+	return len(keys), err
+}
+
+// InvalidateTagKeys removes all items with a specific tag and returns their keys
+func (dc *DistroCache) InvalidateTagKeys(ctx context.Context, tag string) ([]string, error) {
+	if err := dc.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer dc.unlock()
+
+	// Copy the tag's key set first: removeFromTagIndex shrinks the indexed
+	// slice in place, which would skip keys if we ranged over it directly
+	keys := append([]string(nil), dc.tagIndex[tag]...)
+
+	deleted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if item, exists := dc.data.Get(ctx, key); exists {
+			dc.removeFromTagIndex(key, item.Tags)
+			dc.removed(item, EvictReasonInvalidated)
+			dc.dropItem(ctx, key)
+			deleted = append(deleted, key)
+		}
+	}
+
+	dc.tagEntries -= len(dc.tagIndex[tag])
+	delete(dc.tagIndex, tag)
+	dc.updateSizeGauges()
+	return deleted, nil
+}
+
+// addToTagIndex adds a key to the tag index
+func (dc *DistroCache) addToTagIndex(key string, tags []string) {
+	for _, tag := range tags {
+		dc.tagIndex[tag] = append(dc.tagIndex[tag], key)
+	}
+	dc.tagEntries += len(tags)
+}
+
+// removeFromTagIndex removes a key from the tag index
+func (dc *DistroCache) removeFromTagIndex(key string, tags []string) {
+	for _, tag := range tags {
+		keys := dc.tagIndex[tag]
+		for i, k := range keys {
+			if k == key {
+				dc.tagIndex[tag] = append(keys[:i], keys[i+1:]...)
+				dc.tagEntries--
+				break
+			}
+		}
+		if len(dc.tagIndex[tag]) == 0 {
+			delete(dc.tagIndex, tag)
+		}
+	}
+}
+
+// lruVictim returns the least recently used key; callers must hold the lock
+func (dc *DistroCache) lruVictim(now time.Time) (string, bool) {
+	type candidate struct {
+		key        string
+		accessedAt time.Time
+	}
+	var oldest, oldestEvictable candidate
+
+	// Map iteration order is random, so ties on AccessedAt are broken by key
+	// to keep eviction reproducible
+	older := func(c candidate, key string, accessedAt time.Time) bool {
+		return c.key == "" || accessedAt.Before(c.accessedAt) ||
+			(accessedAt.Equal(c.accessedAt) && key < c.key)
+	}
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		accessedAt := dc.access.of(key, item).accessedAt
+		if older(oldest, key, accessedAt) {
+			oldest = candidate{key, accessedAt}
+		}
+		if !dc.isResident(item, now) && older(oldestEvictable, key, accessedAt) {
+			oldestEvictable = candidate{key, accessedAt}
+		}
+		return true
+	})
+
+	if oldestEvictable.key != "" {
+		return oldestEvictable.key, true
+	}
+	return oldest.key, oldest.key != ""
+}
+
+// putItem stores an item in the backend; callers must hold the write lock
+func (dc *DistroCache) putItem(ctx context.Context, key string, item *CacheItem) error {
+	_, exists := dc.data.Get(ctx, key)
+	if err := dc.data.Set(ctx, key, item); err != nil {
+		return err
+	}
+	dc.access.forget(key)
+
+	if !exists && dc.keyPos != nil {
+		dc.keyPos[key] = len(dc.keyOrder)
+		dc.keyOrder = append(dc.keyOrder, key)
+	}
+
+	if dc.eviction != nil {
+		dc.eviction.Insert(key)
+	}
+	return nil
+}
+
+// dropItem removes an item and its chunks from the backend; callers must
+// hold the write lock and pass the item to removed first, while its chunks
+// can still be read
+func (dc *DistroCache) dropItem(ctx context.Context, key string) {
+	dc.data.Delete(ctx, key)
+	dc.dropChunks(key)
+	dc.access.forget(key)
+	delete(dc.fencingTokens, key)
+
+	if dc.eviction != nil {
+		dc.eviction.Remove(key)
+	}
+
+	if dc.keyPos == nil {
+		return
+	}
+	pos, exists := dc.keyPos[key]
+	if !exists {
+		return
+	}
+
+	// Swap the last key into the freed slot to keep removal O(1)
+	last := len(dc.keyOrder) - 1
+	moved := dc.keyOrder[last]
+	dc.keyOrder[pos] = moved
+	dc.keyPos[moved] = pos
+	dc.keyOrder = dc.keyOrder[:last]
+	delete(dc.keyPos, key)
+}
+
+// capacityRatio returns the fraction of MaxSize currently in use; callers must hold the lock
+func (dc *DistroCache) capacityRatio() float64 {
+	if dc.config.MaxSize <= 0 {
+		return 0
+	}
+	return float64(dc.data.Len()) / float64(dc.config.MaxSize)
+}
+
+// updateSizeGauges refreshes the item count and capacity gauges; callers must hold the write lock
+func (dc *DistroCache) updateSizeGauges() {
+	dc.stats.TotalItems.Set(float64(dc.data.Len()))
+	ratio := dc.capacityRatio()
+	dc.stats.CapacityUsed.Set(ratio)
+	dc.stats.LoadFactor.Set(ratio)
+}
+
+// startCleanup runs background cleanup every CleanupInterval until ctx is done
+func (dc *DistroCache) startCleanup(ctx context.Context) {
+	interval := dc.config.CleanupInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			examined, expired := dc.cleanup()
+			if !dc.config.AdaptiveCleanup {
+				continue
+			}
+			if next := nextCleanupInterval(interval, dc.config.CleanupInterval, examined, expired); next != interval {
+				interval = next
+				dc.cleanupInterval.Store(int64(interval))
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// cleanup runs one background cleanup tick and returns how many items it
+// examined and how many of those had expired
+func (dc *DistroCache) cleanup() (examined, expired int) {
+	ctx := context.Background()
+
+	dc.mutex.Lock()
+	defer dc.unlock()
+
+	if dc.config.CleanupStrategy == CleanupIncremental {
+		examined, expired = dc.cleanupBatch(ctx)
+	} else {
+		examined = dc.data.Len()
+		expired = dc.sweepExpired(ctx)
+	}
+	dc.purgeHousekeeping()
+	return examined, expired
+}
+
+// Cleanup removes every expired item immediately, regardless of the cleanup
+// strategy, and returns the number removed
+func (dc *DistroCache) Cleanup(ctx context.Context) (int, error) {
+	if err := dc.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer dc.unlock()
+
+	removed := dc.sweepExpired(ctx)
+	dc.purgeHousekeeping()
+	return removed, nil
+}
+
+// sweepExpired removes all expired items; callers must hold the write lock
+func (dc *DistroCache) sweepExpired(ctx context.Context) int {
+	now := dc.now()
+	var expired []*CacheItem
+	dc.data.Scan(func(_ string, item *CacheItem) bool {
+		if item.ExpiredAt(now) {
+			expired = append(expired, item)
+		}
+		return true
+	})
+	for _, item := range expired {
+		dc.expireItem(ctx, item.Key, item)
+	}
+	return len(expired)
+}
+
+// purgeHousekeeping refreshes gauges and drops stale tombstones and
+// idempotency records; callers must hold the write lock
+func (dc *DistroCache) purgeHousekeeping() {
+	dc.updateSizeGauges()
+
+	// Drop tombstones once the grace period has passed
+	now := dc.now()
+	for key, ts := range dc.tombstones {
+		if now.Sub(ts.DeletedAt) > dc.config.TombstoneTTL {
+			delete(dc.tombstones, key)
+		}
+	}
+
+	if dc.idempotency != nil {
+		dc.idempotency.prune()
+	}
+	dc.transactions.prune()
+}
+
+// cleanupBatch examines at most CleanupBatchSize items, resuming from the
+// cursor left by the previous tick, and returns how many it examined and
+// removed; callers must hold the write lock
+func (dc *DistroCache) cleanupBatch(ctx context.Context) (examined, expired int) {
+	batch := dc.config.CleanupBatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+
+	now := dc.now()
+	for ; examined < batch && len(dc.keyOrder) > 0; examined++ {
+		if dc.cleanupCursor >= len(dc.keyOrder) {
+			dc.cleanupCursor = 0
+			return examined, expired
+		}
+
+		key := dc.keyOrder[dc.cleanupCursor]
+		item, exists := dc.data.Get(ctx, key)
+		if !exists {
+			// Gone from the backend or undecodable; forget the stale key
+			dc.dropItem(ctx, key)
+			continue
+		}
+		if item.ExpiredAt(now) {
+			// Removal swaps an unvisited key into the cursor position
+			dc.expireItem(ctx, key, item)
+			expired++
+			continue
+		}
+		dc.cleanupCursor++
+	}
+	return examined, expired
+}
+
+// GetStats returns cache statistics
+func (dc *DistroCache) GetStats() map[string]interface{} {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	counts := dc.stats.sinceEpoch()
+	hits := counts.hits
+	misses := counts.misses
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+
+	uptime := dc.now().Sub(dc.startedAt)
+	stats := map[string]interface{}{
+		"hits":                            hits,
+		"misses":                          misses,
+		"hit_rate":                        hitRate,
+		"origin_calls_avoided":            hits,
+		"bytes_served":                    counts.bytesServed,
+		"sets":                            counts.sets,
+		"deletes":                         counts.deletes,
+		"evictions":                       counts.evictions,
+		"stats_since":                     counts.startedAt,
+		"estimated_latency_saved_seconds": (time.Duration(hits) * dc.config.MissCost).Seconds(),
+		"total_items":                     dc.data.Len(),
+		"total_tags":                      len(dc.tagIndex),
+		"tombstones":                      len(dc.tombstones),
+		"max_size":                        dc.config.MaxSize,
+		"capacity_used_ratio":             dc.capacityRatio(),
+		"eviction_rate":                   dc.stats.evictions.Rate(),
+		"compression":                     dc.compression.snapshot(),
+		"node_id":                         dc.config.NodeID,
+		"started_at":                      dc.startedAt,
+		"uptime":                          uptime.String(),
+		"uptime_seconds":                  uptime.Seconds(),
+		"cleanup_interval":                time.Duration(dc.cleanupInterval.Load()).String(),
+	}
+	distribution, complete := dc.ttlDistribution()
+	stats["ttl_distribution"] = distribution
+	stats["ttl_distribution_truncated"] = !complete
+	if dc.gcTuner != nil {
+		stats["gc"] = dc.gcTuner.snapshot()
+	}
+	if dc.chunks != nil {
+		stats["chunks"] = len(dc.chunks)
+	}
+	latencyMillis("get", dc.stats.getLatency, stats)
+	latencyMillis("set", dc.stats.setLatency, stats)
+	return stats
+}
+
+// Len returns the number of unexpired items. It walks the backend under the
+// read lock without allocating, so it costs O(n); total_items in GetStats is
+// the cheaper count that includes expired items not yet cleaned up.
+func (dc *DistroCache) Len() int {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	now := dc.now()
+	count := 0
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		if !item.ExpiredAt(now) {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// Keys returns the keys of all unexpired items, in no particular order. It
+// is a point-in-time snapshot: items set, deleted or expiring afterwards are
+// not reflected.
+func (dc *DistroCache) Keys() []string {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	now := dc.now()
+	keys := make([]string, 0, dc.data.Len())
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		if !item.ExpiredAt(now) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// scanLockTimeout returns how long a scan may hold the read lock
+func (dc *DistroCache) scanLockTimeout() time.Duration {
+	if dc.config.ScanLockTimeout <= 0 {
+		return 250 * time.Millisecond
+	}
+	return dc.config.ScanLockTimeout
+}
+
+// KeysMatching returns the keys of unexpired items matching re, in no
+// particular order. The scan stops once it has held the read lock for
+// ScanLockTimeout or ctx is done, and complete reports whether every key was
+// examined.
+func (dc *DistroCache) KeysMatching(ctx context.Context, re *regexp.Regexp) ([]string, bool) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	deadline := time.Now().Add(dc.scanLockTimeout())
+	now := dc.now()
+	keys := []string{}
+	complete := true
+	scanned := 0
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		// Checking the clock on every key would dominate a fast scan
+		scanned++
+		if scanned%1024 == 0 && (time.Now().After(deadline) || ctx.Err() != nil) {
+			complete = false
+			return false
+		}
+		if !item.ExpiredAt(now) && re.MatchString(key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys, complete
+}
+
+// HTTP Handlers
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeStoreError responds with the status matching an error from the storage backend
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrStaleFencingToken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrItemTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrStoreFull):
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case errors.Is(err, ErrWriteThrough):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	case errors.Is(err, ErrOperationTimeout):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeKeyError responds with 400 if err is a key or tag validation error
+// and reports whether it did
+func writeKeyError(w http.ResponseWriter, err error) bool {
+	var tooLong *KeyTooLongError
+	var tooManyTags *TooManyTagsError
+	switch {
+	case errors.As(err, &tooLong):
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "key too long",
+			"max":    tooLong.Max,
+			"actual": tooLong.Actual,
+		})
+	case errors.As(err, &tooManyTags):
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "too many tags",
+			"max":    tooManyTags.Max,
+			"actual": tooManyTags.Actual,
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+func (dc *DistroCache) handleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	peek := r.URL.Query().Get("peek") == "true"
+	etag := r.Header.Get("X-Upstream-ETag")
+	format := requestValueFormat(r)
+	stream := r.URL.Query().Get("stream") == "true"
+	if dc.config.CoalesceGets && etag == "" && !peek && !stream && format == formatItem {
+		dc.serveCoalescedGet(w, r, key)
+		return
+	}
+
+	// Checked before the read so denied requests do not count as hits or
+	// touch the item's access stats. Coalesced GETs share one read and check
+	// each waiter against its result instead.
+	if err := dc.authorizeKey(ctx, key, false); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	var item *CacheItem
+	var found bool
+	if peek {
+		item, found = dc.Peek(ctx, key)
+	} else {
+		item, found = dc.Get(ctx, key)
+	}
+	if !found {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	// The item may have changed, or be a stale one authorizeKey skipped
+	if err := checkACL(ctx, item, false); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	// A changed upstream ETag means the cached value is stale
+	if etag != "" && etag != item.ExternalETag {
+		dc.Delete(ctx, key)
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	if dc.isExpired(item) {
+		w.Header().Set("X-Cache-Stale", "true")
+	}
+	if dc.writeFreshness(w, r, item.CreatedAt, item.TTL) {
+		return
+	}
+	if item.ContentEncoding != "" {
+		writeEncodedBody(w, r, item)
+		return
+	}
+	if stream {
+		dc.streamValue(w, item)
+		return
+	}
+	if format != formatItem {
+		writeValue(w, item, format)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dc.renderItem(presentItem(r, item)))
+}
+
+// serveCoalescedGet answers a GET from a single shared Get and encode per key.
+// Requests for the same key that arrive while one is in flight wait for it
+// and receive the same response bytes.
+func (dc *DistroCache) serveCoalescedGet(w http.ResponseWriter, r *http.Request, key string) {
+	ctx := r.Context()
+	type response struct {
+		body      []byte
+		stale     bool
+		createdAt time.Time
+		ttl       time.Duration
+		acl       *KeyACL
+		// encoded is set instead of body for a pre-encoded HTTP body, whose
+		// response depends on each request's Accept-Encoding
+		encoded *CacheItem
+	}
+
+	result, err, _ := dc.getGroup.Do(key, func() (interface{}, error) {
+		item, found := dc.Get(ctx, key)
+		if !found {
+			return nil, nil
+		}
+		if item.ContentEncoding != "" {
+			return &response{
+				stale:     dc.isExpired(item),
+				createdAt: item.CreatedAt,
+				ttl:       item.TTL,
+				acl:       item.ACL,
+				encoded:   item,
+			}, nil
+		}
+		body, err := json.Marshal(dc.renderItem(presentItem(r, item)))
+		if err != nil {
+			return nil, err
+		}
+		return &response{
+			body:      append(body, '\n'),
+			stale:     dc.isExpired(item),
+			createdAt: item.CreatedAt,
+			ttl:       item.TTL,
+			acl:       item.ACL,
+		}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, _ := result.(*response)
+	if resp == nil {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	// The response is shared, so each waiter is checked against the ACL
+	if !resp.acl.permits(requestAPIKey(ctx), false) {
+		writeStoreError(w, ErrForbidden)
+		return
+	}
+
+	if resp.stale {
+		w.Header().Set("X-Cache-Stale", "true")
+	}
+	if dc.writeFreshness(w, r, resp.createdAt, resp.ttl) {
+		return
+	}
+	if resp.encoded != nil {
+		writeEncodedBody(w, r, resp.encoded)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp.body)
+}
+
+func (dc *DistroCache) handleSet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	var req struct {
+		Value        interface{}            `json:"value"`
+		TTL          int                    `json:"ttl,omitempty"`
+		NoExpire     bool                   `json:"no_expire,omitempty"`
+		Tags         []string               `json:"tags,omitempty"`
+		Version      int64                  `json:"version,omitempty"`
+		OnExpireURL  string                 `json:"on_expire_url,omitempty"`
+		ExternalETag string                 `json:"external_etag,omitempty"`
+		Metadata     map[string]interface{} `json:"metadata,omitempty"`
+		ACL          *KeyACL                `json:"acl,omitempty"`
+		// ContentEncoding "gzip" stores value, standard base64 of a gzip
+		// body, to be served with Content-Encoding: gzip
+		ContentEncoding string `json:"content_encoding,omitempty"`
+	}
+
+	if query := r.URL.Query(); query.Has("value") {
+		// Quick sets from the query string, e.g. ?value=hello&ttl=60&tags=a,b,
+		// always store the value as a string
+		req.Value = query.Get("value")
+		if raw := query.Get("ttl"); raw != "" {
+			ttl, err := strconv.Atoi(raw)
+			if err != nil {
+				http.Error(w, "Invalid ttl", http.StatusBadRequest)
+				return
+			}
+			req.TTL = ttl
+		}
+		req.NoExpire = query.Get("no_expire") == "true"
+		if raw := query.Get("tags"); raw != "" {
+			req.Tags = uniqueStrings(strings.Split(raw, ","))
+		}
+	} else {
+		if dc.config.MaxRequestBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, dc.config.MaxRequestBodyBytes)
+		}
+
+		// Decode numbers as json.Number so integer counters keep full precision
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Proxies can pass a TTL through as X-Cache-TTL; a ttl in the request
+	// itself wins
+	if raw := r.Header.Get("X-Cache-TTL"); raw != "" && req.TTL == 0 && !req.NoExpire {
+		ttl, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid X-Cache-TTL", http.StatusBadRequest)
+			return
+		}
+		req.TTL = ttl
+	}
+
+	ttl, err := dc.requestTTL(req.TTL, req.NoExpire)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, tag := range req.Tags {
+		if err := checkKeyNamespace(r, tag); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := validateContentEncoding(req.ContentEncoding, req.Value); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.OnExpireURL != "" {
+		u, err := url.ParseRequestURI(req.OnExpireURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			http.Error(w, "Invalid on_expire_url", http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts := SetOptions{
+		Version:         req.Version,
+		OnExpireURL:     req.OnExpireURL,
+		ExternalETag:    req.ExternalETag,
+		Metadata:        req.Metadata,
+		ACL:             req.ACL,
+		ContentEncoding: req.ContentEncoding,
+	}
+	if err := dc.SetWithOptions(ctx, key, req.Value, ttl, scopeTags(r, req.Tags), opts); err != nil {
+		if writeKeyError(w, err) {
+			return
+		}
+		if errors.Is(err, ErrStaleVersion) {
+			http.Error(w, "Stale version", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, ErrWriteThrough) {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func (dc *DistroCache) handleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	var version int64
+	if v := r.URL.Query().Get("version"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		version = parsed
+	}
+
+	deleted, err := dc.DeleteWithVersion(ctx, key, version)
+	if errors.Is(err, ErrStaleVersion) {
+		http.Error(w, "Stale version", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func (dc *DistroCache) handleInvalidateTag(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tag := requestTag(r)
+
+	keys, err := dc.InvalidateTagKeys(ctx, tag)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"status":  "success",
+		"deleted": len(keys),
+	}
+	if r.URL.Query().Get("keys") == "true" {
+		resp["keys"] = presentKeys(r, keys)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (dc *DistroCache) handleCleanup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	removed, err := dc.Cleanup(ctx)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"removed": removed,
+	})
+}
+
+func (dc *DistroCache) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	re, err := regexp.Compile(r.URL.Query().Get("regex"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid regex: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	matched, complete := dc.KeysMatching(r.Context(), re)
+	keys := matched[:0]
+	for _, key := range matched {
+		if !hiddenFromRequest(r, key) {
+			keys = append(keys, key)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys":      keys,
+		"count":     len(keys),
+		"truncated": !complete,
+	})
+}
+
+func (dc *DistroCache) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := dc.GetStats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (dc *DistroCache) handleListNodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"nodes": dc.ring.Nodes(),
+	})
+}
+
+// handleRegisterNode adds a node to the ring. /cluster/stats fetches from
+// the addresses of registered nodes, so registering one requires
+// AdminAPIKey when it is configured.
+func (dc *DistroCache) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
+	if !dc.isAdmin(r) {
+		http.Error(w, "Registering a node requires the admin API key", http.StatusForbidden)
+		return
+	}
+
+	var node ClusterNode
+	if err := json.NewDecoder(r.Body).Decode(&node); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if node.ID == "" {
+		http.Error(w, "Node id is required", http.StatusBadRequest)
+		return
+	}
+	if node.Weight < 0 {
+		http.Error(w, "Node weight must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	dc.RegisterNode(node)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func (dc *DistroCache) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "healthy",
+		"version": "1.0.0",
+		"node_id": dc.config.NodeID,
+	})
+}
+
+// Router returns the HTTP API of the cache, for serving it directly or
+// mounting it into another server
+func (dc *DistroCache) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	// Match preflight requests on any path so corsMiddleware answers them;
+	// middleware only runs for matched routes
+	r.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	// API routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	// Registered first so they are not taken for keys named "keys" or "batch"
+	api.HandleFunc("/cache/keys", dc.handleListKeys).Methods("GET")
+	api.HandleFunc("/cache/batch", dc.handleDeleteMany).Methods("DELETE")
+	api.HandleFunc("/cache/{key}", dc.handleGet).Methods("GET")
+	api.HandleFunc("/cache/{key}", dc.idempotent(dc.handleSet)).Methods("POST", "PUT")
+	api.HandleFunc("/cache/{key}", dc.handleDelete).Methods("DELETE")
+	api.HandleFunc("/cache/{key}", dc.handleMergePatch).Methods("PATCH")
+	api.HandleFunc("/cache", dc.queryOrBodyKey(dc.handleGet)).Methods("GET")
+	api.HandleFunc("/cache", dc.idempotent(dc.queryOrBodyKey(dc.handleSet))).Methods("POST", "PUT")
+	api.HandleFunc("/cache", dc.queryOrBodyKey(dc.handleDelete)).Methods("DELETE")
+	api.HandleFunc("/cache-b64/{encoded}", dc.encodedKey(dc.handleGet)).Methods("GET")
+	api.HandleFunc("/cache-b64/{encoded}", dc.idempotent(dc.encodedKey(dc.handleSet))).Methods("POST", "PUT")
+	api.HandleFunc("/cache-b64/{encoded}", dc.encodedKey(dc.handleDelete)).Methods("DELETE")
+	api.HandleFunc("/cache/{key}/incr", dc.handleIncrement).Methods("POST")
+	api.HandleFunc("/cache/{key}/meta", dc.handleGetMetadata).Methods("GET")
+	api.HandleFunc("/cache/{key}/meta", dc.handlePatchMetadata).Methods("PATCH")
+	api.HandleFunc("/cache/{key}/metadata", dc.handleUpdateEntry).Methods("PATCH")
+	api.HandleFunc("/cache/{key}/expiry-watch", dc.handleExpiryWatch).Methods("GET")
+	api.HandleFunc("/invalidate/tag/{tag}", dc.handleInvalidateTag).Methods("POST")
+	api.HandleFunc("/invalidate/tag/{tag}/preview", dc.handlePreviewInvalidateTag).Methods("GET")
+	api.HandleFunc("/invalidate/tag-prefix/{prefix}", dc.handleInvalidateTagPrefix).Methods("POST")
+	api.HandleFunc("/invalidate/tags", dc.handleInvalidateTags).Methods("POST")
+	api.HandleFunc("/invalidate/tags/preview", dc.handlePreviewInvalidateTags).Methods("GET")
+	api.HandleFunc("/cleanup", dc.handleCleanup).Methods("POST")
+	api.HandleFunc("/semaphores/{name}/acquire", dc.handleAcquireSemaphore).Methods("POST")
+	api.HandleFunc("/semaphores/{name}/release", dc.handleReleaseSemaphore).Methods("POST")
+	api.HandleFunc("/transactions/begin", dc.handleBeginTransaction).Methods("POST")
+	api.HandleFunc("/transactions/{txn_id}/set", dc.handleStageSet).Methods("POST")
+	api.HandleFunc("/transactions/{txn_id}/commit", dc.handleCommitTransaction).Methods("POST")
+	api.HandleFunc("/transactions/{txn_id}/rollback", dc.handleRollbackTransaction).Methods("POST")
+	api.HandleFunc("/tags/{tag}/items", dc.handleTagItems).Methods("GET")
+	api.HandleFunc("/stats", dc.handleStats).Methods("GET")
+	api.HandleFunc("/stats/tags", dc.handleTagStats).Methods("GET")
+	api.HandleFunc("/stats/access-histogram", dc.handleAccessHistogram).Methods("GET")
+	api.HandleFunc("/stats/stream", dc.handleStatsStream).Methods("GET")
+	api.HandleFunc("/tags", dc.handleListTags).Methods("GET")
+	api.HandleFunc("/health", dc.handleHealth).Methods("GET")
+	api.HandleFunc("/cluster/nodes", dc.handleListNodes).Methods("GET")
+	api.HandleFunc("/cluster/nodes", dc.handleRegisterNode).Methods("POST")
+	api.HandleFunc("/cluster/stats", dc.handleClusterStats).Methods("GET")
+	api.HandleFunc("/owner", dc.handleOwner).Methods("GET")
+	api.HandleFunc("/cluster/leader", dc.handleLeader).Methods("GET")
+	api.HandleFunc("/stats/reset", dc.handleResetStats).Methods("POST")
+
+	// Admin routes require AdminAPIKey when one is configured
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(dc.adminOnly)
+	admin.HandleFunc("/items/{key}", dc.handleAdminItem).Methods("GET")
+	admin.HandleFunc("/stats/reset", dc.handleAdminResetStats).Methods("POST")
+	admin.HandleFunc("/config", dc.handleConfig).Methods("GET")
+	admin.HandleFunc("/write-behind/pending", dc.handleWriteBehindPending).Methods("GET")
+	admin.HandleFunc("/fan-out-rules", dc.handleListFanOutRules).Methods("GET")
+	admin.HandleFunc("/fan-out-rules", dc.handleAddFanOutRule).Methods("POST")
+	admin.HandleFunc("/tag-patterns", dc.handleListTagPatterns).Methods("GET")
+	admin.HandleFunc("/tag-patterns", dc.handleAddTagPattern).Methods("POST")
+	admin.HandleFunc("/loaders", dc.handleRegisterLoader).Methods("POST")
+
+	// Tenant-scoped routes; keys and tags are namespaced per tenant
+	tenant := api.PathPrefix("/t/{tenant:[A-Za-z0-9_-]+}").Subrouter()
+	tenant.Use(tenantMiddleware)
+	tenant.HandleFunc("/cache/batch", dc.handleDeleteMany).Methods("DELETE")
+	tenant.HandleFunc("/cache/{key}", dc.handleGet).Methods("GET")
+	tenant.HandleFunc("/cache/{key}", dc.idempotent(dc.handleSet)).Methods("POST", "PUT")
+	tenant.HandleFunc("/cache/{key}", dc.handleDelete).Methods("DELETE")
+	tenant.HandleFunc("/cache/{key}", dc.handleMergePatch).Methods("PATCH")
+	tenant.HandleFunc("/cache", dc.queryOrBodyKey(dc.handleGet)).Methods("GET")
+	tenant.HandleFunc("/cache", dc.idempotent(dc.queryOrBodyKey(dc.handleSet))).Methods("POST", "PUT")
+	tenant.HandleFunc("/cache", dc.queryOrBodyKey(dc.handleDelete)).Methods("DELETE")
+	tenant.HandleFunc("/cache-b64/{encoded}", dc.encodedKey(dc.handleGet)).Methods("GET")
+	tenant.HandleFunc("/cache-b64/{encoded}", dc.idempotent(dc.encodedKey(dc.handleSet))).Methods("POST", "PUT")
+	tenant.HandleFunc("/cache-b64/{encoded}", dc.encodedKey(dc.handleDelete)).Methods("DELETE")
+	tenant.HandleFunc("/cache/{key}/incr", dc.handleIncrement).Methods("POST")
+	tenant.HandleFunc("/cache/{key}/meta", dc.handleGetMetadata).Methods("GET")
+	tenant.HandleFunc("/cache/{key}/meta", dc.handlePatchMetadata).Methods("PATCH")
+	tenant.HandleFunc("/cache/{key}/metadata", dc.handleUpdateEntry).Methods("PATCH")
+	tenant.HandleFunc("/cache/{key}/expiry-watch", dc.handleExpiryWatch).Methods("GET")
+	tenant.HandleFunc("/invalidate/tag/{tag}", dc.handleInvalidateTag).Methods("POST")
+	tenant.HandleFunc("/invalidate/tag-prefix/{prefix}", dc.handleInvalidateTagPrefix).Methods("POST")
+	tenant.HandleFunc("/tags", dc.handleListTags).Methods("GET")
+	tenant.HandleFunc("/stats", dc.handleTenantStats).Methods("GET")
+	tenant.HandleFunc("/flush", dc.handleTenantFlush).Methods("POST")
+
+	// Metrics endpoint
+	if dc.config.Registry != nil {
+		r.Handle("/metrics", promhttp.HandlerFor(dc.config.Registry, promhttp.HandlerOpts{}))
+	} else {
+		r.Handle("/metrics", promhttp.Handler())
+	}
+
+	// Attach request ID, API key and trace ID for log correlation
+	r.Use(requestContextMiddleware)
+
+	// Reject writes made under a lock that has since changed hands
+	r.Use(fencingMiddleware)
+
+	// Warn clients when the cache is nearly full
+	r.Use(dc.backpressureMiddleware)
+
+	// Allow the origins in config.CORS to call the API from a browser
+	r.Use(dc.corsMiddleware)
+
+	return r
+}
+
+// NewHTTPServer builds the HTTP server with timeouts from config so slow
+// clients cannot hold connections open indefinitely
+func NewHTTPServer(config *CacheConfig, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              net.JoinHostPort(config.BindAddress, strconv.Itoa(config.Port)),
+		Handler:           handler,
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+
+	if server.ReadTimeout == 0 {
+		server.ReadTimeout = 15 * time.Second
+	}
+	if server.ReadHeaderTimeout == 0 {
+		server.ReadHeaderTimeout = 5 * time.Second
+	}
+	if server.WriteTimeout == 0 {
+		server.WriteTimeout = 30 * time.Second
+	}
+	if server.IdleTimeout == 0 {
+		server.IdleTimeout = 120 * time.Second
+	}
+	if server.MaxHeaderBytes == 0 {
+		server.MaxHeaderBytes = 1 << 20
+	}
+
+	if config.EnableH2C {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if config.MaxConcurrentStreams > 0 {
+		server.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: config.MaxConcurrentStreams}
+	}
+	if config.DisableKeepAlives {
+		server.SetKeepAlivesEnabled(false)
+	}
+
+	return server
+}
+
+// DefaultConfig returns the configuration cache-server runs with
+func DefaultConfig() *CacheConfig {
+	return &CacheConfig{
+		MaxSize:           10000,
+		DefaultTTL:        5 * time.Minute,
+		CleanupInterval:   1 * time.Minute,
+		Port:              8080,
+		NodeID:            "node-1",
+		NodeIDStrategy:    NodeIDStatic,
+		ReplicationFactor: 2,
+		VirtualNodes:      100,
+		NodeWeight:        1,
+		TombstoneTTL:      1 * time.Minute,
+		CleanupStrategy:   CleanupFull,
+		ExpiredReadPolicy: ExpiredReadDelete,
+		EvictionPolicy:    EvictionLRU,
+		LRUK:              2,
+		MMapFile:          "distrocache.mmap",
+		MMapSlotSize:      4096,
+		CleanupBatchSize:  100,
+		WebhookWorkers:    4,
+		WebhookRetries:    3,
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+
+		MissCost:                 50 * time.Millisecond,
+		MaxKeyLength:             512,
+		MaxRequestBodyBytes:      10 << 20,
+		IdempotencyWindowSeconds: 300,
+		IdempotencyMaxKeys:       10000,
+		ResponseFieldStyle:       ResponseFieldsSnakeCase,
+		CompressionAlgo:          CompressionNone,
+		CompressionMinBytes:      256,
+		StreamChunkSize:          64 << 10,
+		TransactionTimeout:       30 * time.Second,
+		ScanLockTimeout:          250 * time.Millisecond,
+		LatencyWindow:            1 * time.Minute,
+		LatencyRotation:          10 * time.Second,
+		TTLBuckets:               []time.Duration{time.Minute, 5 * time.Minute},
+		StatsStreamInterval:      1 * time.Second,
+		CORS:                     DefaultCORSConfig(),
+		StorageFormat:            StorageFormatGob,
+		ClusterStatsTimeout:      2 * time.Second,
+		LeaderHeartbeatInterval:  5 * time.Second,
+		LogLevel:                 "info",
+	}
+}
@@ -0,0 +1,540 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testBackend selects the storage backend newTestCache uses for caches that
+// do not set their own, so the whole suite can be run against any of them,
+// e.g. go test ./pkg/cache -backend=bolt
+var testBackend = flag.String("backend", "memory", "storage backend for the tests: memory, bolt, badger or mmap")
+
+// useTestBackend configures the named storage backend, in a directory that
+// is removed when the test ends
+func useTestBackend(t testing.TB, config *CacheConfig, name string) {
+	t.Helper()
+
+	var err error
+	switch name {
+	case "memory":
+		config.Backend = NewInMemoryBackend()
+	case "bolt":
+		config.Backend, err = NewBoltBackend(filepath.Join(t.TempDir(), "cache.db"))
+	case "badger":
+		config.Backend, err = NewBadgerBackend(t.TempDir())
+	case "mmap":
+		config.MMapBackend = true
+		config.MMapFile = filepath.Join(t.TempDir(), "cache.mmap")
+	default:
+		t.Fatalf("unknown storage backend %q", name)
+	}
+	if err != nil {
+		t.Fatalf("opening %s backend: %v", name, err)
+	}
+}
+
+// newTestCache returns a cache built from DefaultConfig after configure, if
+// given, has adjusted it. Each cache gets its own metrics registry so tests
+// can build as many as they like, and is closed when the test ends.
+func newTestCache(t testing.TB, configure func(*CacheConfig)) *DistroCache {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Registry = prometheus.NewRegistry()
+	if configure != nil {
+		configure(config)
+	}
+	if config.Backend == nil && !config.MMapBackend {
+		useTestBackend(t, config, *testBackend)
+	}
+
+	dc := NewDistroCache(config)
+	t.Cleanup(func() {
+		if err := dc.Close(); err != nil {
+			t.Errorf("closing cache: %v", err)
+		}
+	})
+	return dc
+}
+
+// serve sends a request through the cache's router and returns the response
+// status and body. A non-nil body is encoded as JSON.
+func serve(t testing.TB, dc *DistroCache, method, target string, body interface{}, headers ...string) (int, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	dc.Router().ServeHTTP(rec, req)
+	return rec.Code, rec.Body.Bytes()
+}
+
+// mustServe is serve that fails the test unless the response has status want
+func mustServe(t testing.TB, dc *DistroCache, want int, method, target string, body interface{}, headers ...string) []byte {
+	t.Helper()
+
+	code, data := serve(t, dc, method, target, body, headers...)
+	if code != want {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, target, code, want, data)
+	}
+	return data
+}
+
+func TestRouterHealth(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "GET", "/api/v1/health", nil)
+}
+
+func TestChangedUpstreamETagEvicts(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/page",
+		map[string]interface{}{"value": "<html>", "ttl": 60, "external_etag": `"v1"`})
+
+	mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/page", nil, "X-Upstream-ETag", `"v1"`)
+	mustServe(t, dc, http.StatusNotFound, "GET", "/api/v1/cache/page", nil, "X-Upstream-ETag", `"v2"`)
+
+	// The stale entry is gone, not just hidden from that one request
+	if _, found := dc.Peek(context.Background(), "page"); found {
+		t.Error("stale entry still cached after an ETag mismatch")
+	}
+}
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestSetBodyOverLimit(t *testing.T) {
+	const limit = 1 << 10
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxRequestBodyBytes = limit
+	})
+
+	// A 10MB value, which the server must stop reading near the limit
+	payload := io.MultiReader(
+		strings.NewReader(`{"value":"`),
+		io.LimitReader(repeatReader('x'), 10<<20),
+		strings.NewReader(`"}`),
+	)
+	body := &countingReader{r: payload}
+	req := httptest.NewRequest("POST", "/api/v1/cache/big", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	dc.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want 413", rec.Code)
+	}
+	if body.read > 64<<10 {
+		t.Errorf("server read %d bytes of an oversized body, want it to stop near %d", body.read, limit)
+	}
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/small", map[string]interface{}{"value": "ok"})
+}
+
+// repeatReader yields the same byte forever
+type repeatReader byte
+
+func (b repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestKeyTooLong(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxKeyLength = 512
+	})
+	ctx := context.Background()
+	key := strings.Repeat("k", 600)
+
+	var tooLong *KeyTooLongError
+	if err := dc.Set(ctx, key, "value", time.Minute, nil); !errors.As(err, &tooLong) {
+		t.Fatalf("Set error = %v, want KeyTooLongError", err)
+	}
+	if tooLong.Max != 512 || tooLong.Actual != 600 {
+		t.Errorf("KeyTooLongError = %+v, want max 512 and actual 600", tooLong)
+	}
+	if _, err := dc.DeleteWithVersion(ctx, key, 0); !errors.As(err, &tooLong) {
+		t.Errorf("DeleteWithVersion error = %v, want KeyTooLongError", err)
+	}
+
+	for _, method := range []string{"POST", "GET", "DELETE"} {
+		var body interface{}
+		if method == "POST" {
+			body = map[string]interface{}{"value": "value"}
+		}
+		data := mustServe(t, dc, http.StatusBadRequest, method, "/api/v1/cache/"+key, body)
+
+		var resp struct {
+			Error  string `json:"error"`
+			Max    int    `json:"max"`
+			Actual int    `json:"actual"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if resp.Error != "key too long" || resp.Max != 512 || resp.Actual != 600 {
+			t.Errorf("%s response = %+v, want key too long, max 512, actual 600", method, resp)
+		}
+	}
+}
+
+func TestLRUTiesEvictDeterministically(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		// A stopped clock gives every item the same access time
+		dc := newTestCache(t, func(config *CacheConfig) {
+			config.Clock = NewMockClock(time.Now())
+			config.MaxSize = 3
+		})
+		ctx := context.Background()
+
+		for _, key := range []string{"c", "a", "b", "d"} {
+			if err := dc.Set(ctx, key, key, time.Minute, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, found := dc.Peek(ctx, "a"); found {
+			t.Fatalf("run %d: evicted something other than a, the lowest of the tied keys", run)
+		}
+	}
+}
+
+// blockingBackend is an in-memory backend whose reads wait for release to
+// be closed, and which counts them
+type blockingBackend struct {
+	*InMemoryBackend
+	release chan struct{}
+	gets    atomic.Int64
+}
+
+func (b *blockingBackend) Get(ctx context.Context, key string) (*CacheItem, bool) {
+	b.gets.Add(1)
+	<-b.release
+	return b.InMemoryBackend.Get(ctx, key)
+}
+
+func TestCoalescedGets(t *testing.T) {
+	backend := &blockingBackend{InMemoryBackend: NewInMemoryBackend(), release: make(chan struct{})}
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.Backend = backend
+		config.CoalesceGets = true
+	})
+	backend.InMemoryBackend.Set(context.Background(), "hot-key", &CacheItem{Key: "hot-key", Value: "value", CreatedAt: time.Now()})
+	router := dc.Router()
+	hits := testutil.ToFloat64(dc.stats.Hits)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/cache/hot-key", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("status %d: %s", rec.Code, rec.Body)
+			}
+		}()
+	}
+	// Give every request time to join the in-flight Get before it returns
+	time.Sleep(100 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	if got := testutil.ToFloat64(dc.stats.Hits) - hits; got != 1 {
+		t.Errorf("100 concurrent GETs ran %v cache Gets, want 1", got)
+	}
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("100 concurrent GETs read the backend %d times, want 1", got)
+	}
+}
+
+func TestBindIPv6Loopback(t *testing.T) {
+	probe, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	port := probe.Addr().(*net.TCPAddr).Port
+	probe.Close()
+
+	dc := newTestCache(t, nil)
+	config := DefaultConfig()
+	config.BindAddress = "::1"
+	config.Port = port
+	server := NewHTTPServer(config, dc.Router())
+	go server.ListenAndServe()
+	t.Cleanup(func() { server.Close() })
+
+	ipv6 := net.JoinHostPort("::1", strconv.Itoa(port))
+	var resp *http.Response
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = http.Get("http://" + ipv6 + "/api/v1/health"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", ipv6, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health over IPv6: status %d, want 200", resp.StatusCode)
+	}
+
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), time.Second); err == nil {
+		conn.Close()
+		t.Error("connected over IPv4 to a server bound to ::1")
+	}
+}
+
+func TestTwoCachesInOneProcess(t *testing.T) {
+	first := newTestCache(t, nil)
+	second := newTestCache(t, nil)
+	ctx := context.Background()
+
+	if err := first.Set(ctx, "key", "first", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := second.Get(ctx, "key"); found {
+		t.Error("a key set on one cache was found on the other")
+	}
+
+	// Each cache's metrics are its own
+	if got := testutil.ToFloat64(first.stats.Sets); got != 1 {
+		t.Errorf("first cache sets = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(second.stats.Sets); got != 0 {
+		t.Errorf("second cache sets = %v, want 0", got)
+	}
+	body := mustServe(t, second, http.StatusOK, "GET", "/metrics", nil)
+	if !bytes.Contains(body, []byte("distrocache_misses_total 1")) {
+		t.Errorf("second cache's /metrics does not show its one miss:\n%s", body)
+	}
+}
+
+func TestListKeysByRegex(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) { config.MaxSize = 200000 })
+	ctx := context.Background()
+
+	for _, key := range []string{"order:1:pending", "order:7:pending", "order:3:shipped", "user:1"} {
+		if err := dc.Set(ctx, key, "v", time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var listing struct {
+		Keys      []string `json:"keys"`
+		Count     int      `json:"count"`
+		Truncated bool     `json:"truncated"`
+	}
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/keys?regex=order:.*:pending", nil)
+	if err := json.Unmarshal(body, &listing); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(listing.Keys)
+	if listing.Count != 2 || !slices.Equal(listing.Keys, []string{"order:1:pending", "order:7:pending"}) {
+		t.Errorf("listing = %+v, want the two pending orders", listing)
+	}
+
+	mustServe(t, dc, http.StatusBadRequest, "GET", "/api/v1/cache/keys?regex=order:(", nil)
+
+	for i := 0; i < 100000; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("bulk:%d", i), i, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := dc.Router()
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/cache/keys?regex=^bulk:9999[0-9]$", nil))
+	elapsed := time.Since(start)
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	if listing.Count != 10 || listing.Truncated {
+		t.Errorf("listing over 100,000 items = %+v, want bulk:99990 to bulk:99999", listing)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("listing 100,000 items took %v, want under 500ms", elapsed)
+	}
+}
+
+// startHTTPServer serves the cache through NewHTTPServer built from config
+func startHTTPServer(t testing.TB, dc *DistroCache, config *CacheConfig) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = NewHTTPServer(config, dc.Router())
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newH2CClient returns a client speaking cleartext HTTP/2 with prior knowledge
+func newH2CClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Protocols = new(http.Protocols)
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: transport}
+}
+
+func TestServerTransportSettings(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	config := DefaultConfig()
+	config.EnableH2C = true
+	config.MaxConcurrentStreams = 50
+	h2c := startHTTPServer(t, dc, config)
+	if got := h2c.Config.HTTP2; got == nil || got.MaxConcurrentStreams != 50 {
+		t.Errorf("HTTP/2 config = %+v, want 50 concurrent streams", got)
+	}
+
+	for name, client := range map[string]*http.Client{"HTTP/1.1": http.DefaultClient, "HTTP/2.0": newH2CClient()} {
+		resp, err := client.Get(h2c.URL + "/api/v1/health")
+		if err != nil {
+			t.Fatalf("%s request to an h2c server: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.Proto != name || resp.StatusCode != http.StatusOK {
+			t.Errorf("%s request answered over %s with status %d", name, resp.Proto, resp.StatusCode)
+		}
+	}
+
+	plain := startHTTPServer(t, dc, DefaultConfig())
+	if resp, err := newH2CClient().Get(plain.URL + "/api/v1/health"); err == nil {
+		resp.Body.Close()
+		t.Errorf("HTTP/2 request to a server without h2c answered over %s", resp.Proto)
+	}
+
+	config = DefaultConfig()
+	config.DisableKeepAlives = true
+	closing := startHTTPServer(t, dc, config)
+	resp, err := http.Get(closing.URL + "/api/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !resp.Close {
+		t.Error("connection kept alive with DisableKeepAlives set")
+	}
+}
+
+// BenchmarkServerProtocols compares the throughput of concurrent reads over
+// HTTP/1.1 and cleartext HTTP/2, e.g. go test -bench ServerProtocols -cpu 32
+func BenchmarkServerProtocols(b *testing.B) {
+	dc := newTestCache(b, nil)
+	if err := dc.Set(context.Background(), "key", "value", time.Hour, nil); err != nil {
+		b.Fatal(err)
+	}
+	config := DefaultConfig()
+	config.EnableH2C = true
+	server := startHTTPServer(b, dc, config)
+
+	http1 := http.DefaultTransport.(*http.Transport).Clone()
+	http1.MaxIdleConnsPerHost = 256
+	for name, client := range map[string]*http.Client{
+		"http1": {Transport: http1},
+		"h2c":   newH2CClient(),
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := client.Get(server.URL + "/api/v1/cache/key")
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			})
+		})
+	}
+}
+
+func TestUptime(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+	dc := newTestCache(t, func(config *CacheConfig) { config.Clock = clock })
+
+	clock.Advance(5 * time.Minute)
+	stats := dc.GetStats()
+	if got, _ := stats["uptime_seconds"].(float64); got < 299.9 || got > 300.1 {
+		t.Errorf("uptime_seconds = %v, want about 300", stats["uptime_seconds"])
+	}
+	if got := stats["uptime"]; got != "5m0s" {
+		t.Errorf("uptime = %v, want 5m0s", got)
+	}
+
+	// Resetting the stats leaves uptime alone
+	if err := dc.ResetStats(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var overHTTP struct {
+		StartedAt     string  `json:"started_at"`
+		UptimeSeconds float64 `json:"uptime_seconds"`
+	}
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/stats", nil), &overHTTP); err != nil {
+		t.Fatal(err)
+	}
+	if overHTTP.StartedAt != "2024-03-01T12:00:00Z" || overHTTP.UptimeSeconds != 300 {
+		t.Errorf("stats after a reset = %+v, want started 2024-03-01T12:00:00Z and 300s up", overHTTP)
+	}
+}
+
+func TestRegisterNodeRequiresAdmin(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) { config.AdminAPIKey = "admin" })
+	node := map[string]interface{}{"id": "node-2", "address": "http://10.0.0.2:8080", "weight": 1}
+
+	mustServe(t, dc, http.StatusForbidden, "POST", "/api/v1/cluster/nodes", node)
+	mustServe(t, dc, http.StatusForbidden, "POST", "/api/v1/cluster/nodes", node, "X-API-Key", "guess")
+	if body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cluster/nodes", nil); bytes.Contains(body, []byte("node-2")) {
+		t.Errorf("refused node registered anyway: %s", body)
+	}
+	mustServe(t, dc, http.StatusCreated, "POST", "/api/v1/cluster/nodes", node, "X-API-Key", "admin")
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// chunkKey names the i-th chunk of the value stored at key
+func chunkKey(key string, i int) string {
+	return key + ":__chunk" + strconv.Itoa(i)
+}
+
+// chunkItem splits the encoded value of item into ChunkSize pieces stored as
+// key:__chunk0, key:__chunk1, ... when it is larger than ChunkSize, leaving
+// item with only the chunk count and total size. Values stored compressed
+// are chunked as compressed; others as JSON. Callers must hold the write
+// lock and drop any chunks of the item being replaced first.
+func (dc *DistroCache) chunkItem(key string, item *CacheItem) error {
+	size := dc.config.ChunkSize
+	if dc.chunks == nil || size <= 0 {
+		return nil
+	}
+
+	encoded := item.Compressed
+	if encoded == nil {
+		if item.Size <= int64(size) {
+			return nil
+		}
+		raw, err := json.Marshal(item.Value)
+		if err != nil {
+			return err
+		}
+		encoded = raw
+	}
+	if len(encoded) <= size {
+		return nil
+	}
+
+	chunks := 0
+	for start := 0; start < len(encoded); start += size {
+		end := min(start+size, len(encoded))
+		// Copy so each chunk is its own allocation rather than a window on
+		// one large array
+		dc.chunks[chunkKey(key, chunks)] = append([]byte(nil), encoded[start:end]...)
+		chunks++
+	}
+
+	item.Value = nil
+	item.Compressed = nil
+	item.Chunks = chunks
+	item.ChunkedBytes = int64(len(encoded))
+	return nil
+}
+
+// dropChunks deletes the chunks stored for key, if any; callers must hold
+// the write lock
+func (dc *DistroCache) dropChunks(key string) {
+	if len(dc.chunks) == 0 {
+		return
+	}
+	for i := 0; ; i++ {
+		ck := chunkKey(key, i)
+		if _, exists := dc.chunks[ck]; !exists {
+			return
+		}
+		delete(dc.chunks, ck)
+	}
+}
+
+// assemble returns a copy of a chunked item with its chunks joined back into
+// Compressed, as if it had been stored whole; other items are returned as
+// they are. Callers must hold the lock.
+func (dc *DistroCache) assemble(item *CacheItem) (*CacheItem, error) {
+	if item.Chunks == 0 {
+		return item, nil
+	}
+
+	encoded := make([]byte, 0, item.ChunkedBytes)
+	for i := 0; i < item.Chunks; i++ {
+		chunk, exists := dc.chunks[chunkKey(item.Key, i)]
+		if !exists {
+			return nil, fmt.Errorf("chunk %d of %d missing", i, item.Chunks)
+		}
+		encoded = append(encoded, chunk...)
+	}
+
+	view := *item
+	view.Compressed = encoded
+	view.Chunks = 0
+	view.ChunkedBytes = 0
+	return &view, nil
+}
+
+// inflateStored returns item with its value reassembled and decompressed,
+// like inflate; callers must hold the lock
+func (dc *DistroCache) inflateStored(item *CacheItem) (*CacheItem, error) {
+	assembled, err := dc.assemble(item)
+	if err != nil {
+		return nil, err
+	}
+	return inflate(assembled)
+}
+
+// storedValue returns the decoded value of item, like decompressValue;
+// callers must hold the lock
+func (dc *DistroCache) storedValue(item *CacheItem) (interface{}, error) {
+	assembled, err := dc.assemble(item)
+	if err != nil {
+		return nil, err
+	}
+	return decompressValue(assembled)
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkedValues(t *testing.T) {
+	const chunkSize = 1 << 20
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.ChunkSize = chunkSize
+		config.Backend = NewInMemoryBackend()
+	})
+	ctx := context.Background()
+
+	// Quoted as JSON the value is exactly 10MB, so ten full chunks
+	value := strings.Repeat("x", 10*chunkSize-2)
+	if err := dc.Set(ctx, "blob", value, time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dc.mutex.RLock()
+	stored, _ := dc.data.Get(ctx, "blob")
+	if stored.Chunks != 10 || stored.ChunkedBytes != 10*chunkSize || stored.Value != nil {
+		t.Errorf("primary entry = %d chunks of %d bytes, value %T; want 10 chunks of 10MB and no value",
+			stored.Chunks, stored.ChunkedBytes, stored.Value)
+	}
+	for i := 0; i < 10; i++ {
+		if chunk, exists := dc.chunks[chunkKey("blob", i)]; !exists || len(chunk) != chunkSize {
+			t.Errorf("chunk %d: exists %v with %d bytes, want %d", i, exists, len(chunk), chunkSize)
+		}
+	}
+	dc.mutex.RUnlock()
+
+	item, found := dc.Get(ctx, "blob")
+	if !found || item.Value != value {
+		t.Fatal("Get did not return the original value")
+	}
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/blob?raw=true", nil)
+	if want := `"` + value + `"`; strings.TrimSpace(string(body)) != want {
+		t.Errorf("raw GET returned %d bytes, want the %d-byte value", len(body), len(want))
+	}
+
+	// Values within ChunkSize are stored whole
+	if err := dc.Set(ctx, "small", "value", time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := dc.Peek(ctx, "small"); stored.Value != "value" {
+		t.Errorf("small value = %v, want it stored whole", stored.Value)
+	}
+
+	if !dc.Delete(ctx, "blob") {
+		t.Fatal("Delete of the chunked key reported nothing removed")
+	}
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	if len(dc.chunks) != 0 {
+		t.Errorf("%d chunks left after Delete", len(dc.chunks))
+	}
+}
+
+func TestOverwriteDropsOldChunks(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.ChunkSize = 1024
+		config.Backend = NewInMemoryBackend()
+	})
+	ctx := context.Background()
+
+	if err := dc.Set(ctx, "blob", strings.Repeat("x", 5000), time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Set(ctx, "blob", strings.Repeat("y", 2000), time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	if len(dc.chunks) != 2 {
+		t.Errorf("%d chunks stored after overwriting with a 2-chunk value, want 2", len(dc.chunks))
+	}
+}
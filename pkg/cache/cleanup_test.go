@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIncrementalCleanupTickIsShort(t *testing.T) {
+	const items = 100000
+	clock := NewMockClock(time.Now())
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.Clock = clock
+		config.MaxSize = 2 * items
+		config.CleanupInterval = time.Hour
+		config.CleanupStrategy = CleanupIncremental
+		config.CleanupBatchSize = 100
+		// The bound is for items in memory, not expiries written to disk
+		config.Backend = NewInMemoryBackend()
+	})
+	ctx := context.Background()
+
+	// Every other item expires
+	for i := 0; i < items; i++ {
+		ttl := time.Minute
+		if i%2 == 0 {
+			ttl = time.Hour
+		}
+		if err := dc.Set(ctx, fmt.Sprintf("key:%d", i), i, ttl, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(2 * time.Minute)
+
+	start := time.Now()
+	examined, expired := dc.cleanup()
+	held := time.Since(start)
+	if examined != 100 {
+		t.Errorf("tick examined %d items, want the batch of 100", examined)
+	}
+	if expired == 0 {
+		t.Error("tick removed no expired items")
+	}
+	if held >= time.Millisecond {
+		t.Errorf("cleanup tick held the lock for %v, want under 1ms", held)
+	}
+
+	// Enough ticks to cover every key once remove every expired item
+	for dc.data.Len() > items/2 && examined > 0 {
+		examined, _ = dc.cleanup()
+	}
+	if got := dc.data.Len(); got != items/2 {
+		t.Errorf("%d items left after a full pass, want %d", got, items/2)
+	}
+}
+
+func TestFullCleanupSweepsEverything(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.Clock = clock
+		config.CleanupInterval = time.Hour
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 500; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("key:%d", i), i, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(2 * time.Minute)
+
+	if examined, expired := dc.cleanup(); examined != 500 || expired != 500 {
+		t.Errorf("full tick examined %d and expired %d, want 500 of each", examined, expired)
+	}
+}
+
+func TestIncrementalCleanupSkipsKeysMissingFromBackend(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.CleanupInterval = time.Hour
+		config.CleanupStrategy = CleanupIncremental
+		config.Backend = NewInMemoryBackend()
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("key:%d", i), i, time.Hour, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// As after a concurrent delete or a value the backend cannot decode
+	dc.data.Delete(ctx, "key:1")
+
+	examined, expired := dc.cleanup()
+	if examined != 3 || expired != 0 {
+		t.Errorf("cleanup examined %d and expired %d, want 3 and 0", examined, expired)
+	}
+	if len(dc.keyOrder) != 2 {
+		t.Errorf("%d keys tracked after cleanup, want the 2 still stored", len(dc.keyOrder))
+	}
+}
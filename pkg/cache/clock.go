@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the cache the time. Expiry, item timestamps, tombstones,
+// versions and windowed statistics all read it, so a MockClock makes TTL
+// behaviour deterministic. Lock timeouts, latency measurements and
+// write-behind retries are paced by real timers and always use the real time.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real clock, used when CacheConfig.Clock is nil
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// MockClock is a Clock that only moves when told to
+type MockClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewMockClock creates a mock clock stopped at now
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{now: now}
+}
+
+// Now returns the mock time
+func (mc *MockClock) Now() time.Time {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	return mc.now
+}
+
+// Advance moves the mock time forward by d
+func (mc *MockClock) Advance(d time.Duration) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.now = mc.now.Add(d)
+}
+
+// Set moves the mock time to now
+func (mc *MockClock) Set(now time.Time) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.now = now
+}
+
+// ExpiredAt reports whether the item's TTL has passed at the given time
+func (ci *CacheItem) ExpiredAt(now time.Time) bool {
+	if ci.TTL == 0 {
+		return false // Never expires
+	}
+	return now.Sub(ci.CreatedAt) > ci.TTL
+}
+
+// now returns the current time on the cache's clock
+func (dc *DistroCache) now() time.Time {
+	return dc.clock.Now()
+}
+
+// isExpired reports whether item has expired on the cache's clock
+func (dc *DistroCache) isExpired(item *CacheItem) bool {
+	return item.ExpiredAt(dc.now())
+}
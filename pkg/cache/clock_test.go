@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMockClockExpiresItems(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+	dc := newTestCache(t, func(config *CacheConfig) { config.Clock = clock })
+	ctx := context.Background()
+
+	if err := dc.Set(ctx, "session", "abc", 10*time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Set(ctx, "forever", "x", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(9 * time.Second)
+	item, found := dc.Get(ctx, "session")
+	if !found {
+		t.Fatal("item expired before its TTL")
+	}
+	if !item.CreatedAt.Equal(start) {
+		t.Errorf("CreatedAt = %v, want the mock time %v", item.CreatedAt, start)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, found := dc.Get(ctx, "session"); found {
+		t.Error("item served after its TTL")
+	}
+	if removed, err := dc.Cleanup(ctx); err != nil || removed != 1 {
+		t.Errorf("Cleanup = %d, %v; want the expired item removed", removed, err)
+	}
+
+	clock.Advance(365 * 24 * time.Hour)
+	if _, found := dc.Get(ctx, "forever"); !found {
+		t.Error("item without a TTL expired")
+	}
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeStats is one node's entry in the cluster stats
+type NodeStats struct {
+	ID        string                 `json:"id"`
+	Address   string                 `json:"address,omitempty"`
+	Reachable bool                   `json:"reachable"`
+	Error     string                 `json:"error,omitempty"`
+	Stats     map[string]interface{} `json:"stats,omitempty"`
+}
+
+// ClusterStats aggregates the stats of every node on the hash ring
+type ClusterStats struct {
+	TotalItems  int64       `json:"total_items"`
+	Nodes       []NodeStats `json:"nodes"`
+	Unreachable []string    `json:"unreachable"`
+}
+
+// ClusterStats collects /api/v1/stats from every registered node
+// concurrently, answering for this node directly. Nodes that do not answer
+// within ClusterStatsTimeout are reported as unreachable rather than holding
+// up the rest.
+func (dc *DistroCache) ClusterStats(ctx context.Context) ClusterStats {
+	timeout := dc.config.ClusterStatsTimeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	nodes := dc.ring.Nodes()
+	results := make([]NodeStats, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		results[i] = NodeStats{ID: node.ID, Address: node.Address}
+		if node.ID == dc.config.NodeID {
+			results[i].Reachable = true
+			results[i].Stats = dc.GetStats()
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *NodeStats) {
+			defer wg.Done()
+
+			nodeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			stats, err := fetchNodeStats(nodeCtx, result.Address)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.Reachable = true
+			result.Stats = stats
+		}(&results[i])
+	}
+	wg.Wait()
+
+	cluster := ClusterStats{Nodes: results, Unreachable: []string{}}
+	for _, node := range results {
+		if !node.Reachable {
+			cluster.Unreachable = append(cluster.Unreachable, node.ID)
+			continue
+		}
+		cluster.TotalItems += statInt(node.Stats["total_items"])
+	}
+	return cluster
+}
+
+// fetchNodeStats reads the stats of the node at address
+func fetchNodeStats(ctx context.Context, address string) (map[string]interface{}, error) {
+	if address == "" {
+		return nil, fmt.Errorf("node has no address")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(address, "/")+"/api/v1/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stats returned status %d", resp.StatusCode)
+	}
+
+	var stats map[string]interface{}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// statInt reads an integer stat reported locally or decoded from a node
+func statInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	}
+	return 0
+}
+
+func (dc *DistroCache) handleClusterStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, dc.ClusterStats(r.Context()))
+}
+
+// Owner returns the cluster node that owns key on the hash ring, so
+// clients can send requests for it straight to that node
+func (dc *DistroCache) Owner(key string) (ClusterNode, bool) {
+	return dc.ring.Owner(key)
+}
+
+func (dc *DistroCache) handleOwner(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	node, found := dc.Owner(key)
+	if !found {
+		http.Error(w, "No nodes on the hash ring", http.StatusServiceUnavailable)
+		return
+	}
+	response := map[string]interface{}{
+		"key":     key,
+		"node_id": node.ID,
+		"local":   node.ID == dc.config.NodeID,
+	}
+	if node.Address != "" {
+		response["address"] = node.Address
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Compression algorithms for stored values
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Codec compresses and decompresses stored values. Implementations must be
+// safe for concurrent use.
+type Codec interface {
+	// Name is recorded on each item so it can be decompressed after the
+	// configured algorithm changes
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return CompressionGzip }
+
+func (gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// zstdCodec shares one encoder and decoder; their EncodeAll and DecodeAll
+// methods are safe for concurrent use
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// sharedZstd builds the zstd codec on first use
+var sharedZstd = sync.OnceValues(func() (Codec, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &zstdCodec{encoder: encoder, decoder: decoder}, nil
+})
+
+func (c *zstdCodec) Name() string { return CompressionZstd }
+
+func (c *zstdCodec) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) Decompress(data []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(data, nil)
+}
+
+// codecFor returns the codec for an algorithm name; CompressionNone and ""
+// return a nil codec
+func codecFor(name string) (Codec, error) {
+	switch name {
+	case "", CompressionNone:
+		return nil, nil
+	case CompressionGzip:
+		return gzipCodec{}, nil
+	case CompressionZstd:
+		return sharedZstd()
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", name)
+	}
+}
+
+// codecStats counts the values stored with one algorithm
+type codecStats struct {
+	values          atomic.Int64
+	rawBytes        atomic.Int64
+	compressedBytes atomic.Int64
+}
+
+// compressionStats tracks compression per algorithm for /stats and Prometheus
+type compressionStats struct {
+	mutex      sync.Mutex
+	algorithms map[string]*codecStats
+
+	rawBytes        *prometheus.CounterVec
+	compressedBytes *prometheus.CounterVec
+}
+
+func newCompressionStats() *compressionStats {
+	return &compressionStats{
+		algorithms: make(map[string]*codecStats),
+		rawBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "distrocache_compression_raw_bytes_total",
+			Help: "Total bytes of values before compression, by algorithm",
+		}, []string{"algorithm"}),
+		compressedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "distrocache_compression_compressed_bytes_total",
+			Help: "Total bytes of values after compression, by algorithm",
+		}, []string{"algorithm"}),
+	}
+}
+
+// record counts a value of raw bytes stored as compressed bytes
+func (cs *compressionStats) record(algorithm string, raw, compressed int) {
+	cs.mutex.Lock()
+	stats, exists := cs.algorithms[algorithm]
+	if !exists {
+		stats = &codecStats{}
+		cs.algorithms[algorithm] = stats
+	}
+	cs.mutex.Unlock()
+
+	stats.values.Add(1)
+	stats.rawBytes.Add(int64(raw))
+	stats.compressedBytes.Add(int64(compressed))
+	cs.rawBytes.WithLabelValues(algorithm).Add(float64(raw))
+	cs.compressedBytes.WithLabelValues(algorithm).Add(float64(compressed))
+}
+
+// snapshot reports the values, byte counts and ratio of each algorithm
+func (cs *compressionStats) snapshot() map[string]interface{} {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	result := make(map[string]interface{}, len(cs.algorithms))
+	for algorithm, stats := range cs.algorithms {
+		raw := stats.rawBytes.Load()
+		compressed := stats.compressedBytes.Load()
+		ratio := 0.0
+		if raw > 0 {
+			ratio = float64(compressed) / float64(raw)
+		}
+		result[algorithm] = map[string]interface{}{
+			"values":           stats.values.Load(),
+			"raw_bytes":        raw,
+			"compressed_bytes": compressed,
+			"ratio":            ratio,
+		}
+	}
+	return result
+}
+
+// compressValue encodes value as JSON and compresses it with the configured
+// codec. It returns a nil slice when the value should be stored as is: no
+// codec is configured, the value is below CompressionMinBytes or
+// compressing does not make it smaller.
+func (dc *DistroCache) compressValue(value interface{}) ([]byte, error) {
+	if dc.codec == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < dc.config.CompressionMinBytes {
+		return nil, nil
+	}
+
+	compressed, err := dc.codec.Compress(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(raw) {
+		return nil, nil
+	}
+
+	dc.compression.record(dc.codec.Name(), len(raw), len(compressed))
+	return compressed, nil
+}
+
+// decompressValue decodes the value of an item stored compressed, using the
+// algorithm recorded on the item rather than the configured one. Items
+// reassembled from chunks may hold uncompressed JSON in Compressed.
+func decompressValue(item *CacheItem) (interface{}, error) {
+	codec, err := codecFor(item.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if codec == nil && item.Compressed == nil {
+		return item.Value, nil
+	}
+
+	raw := item.Compressed
+	if codec != nil {
+		raw, err = codec.Decompress(item.Compressed)
+		if err != nil {
+			return nil, fmt.Errorf("decompress %s value: %w", item.Compression, err)
+		}
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// inflate returns item with its value decompressed. The stored item is left
+// compressed; a copy is returned when decompression was needed.
+func inflate(item *CacheItem) (*CacheItem, error) {
+	if item.Compressed == nil {
+		return item, nil
+	}
+
+	value, err := decompressValue(item)
+	if err != nil {
+		return nil, err
+	}
+	view := *item
+	view.Value = value
+	view.Compressed = nil
+	return &view, nil
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentEncodingGzip is the only content_encoding a set accepts: the value
+// is standard base64 of a gzip-encoded HTTP body, e.g. a response captured
+// by a reverse proxy. Unlike CompressionAlgo, which compresses values inside
+// the cache, the encoding belongs to the value and is passed through on GET.
+const ContentEncodingGzip = "gzip"
+
+// validateContentEncoding checks that a set declaring encoding carries a
+// value in that encoding
+func validateContentEncoding(encoding string, value interface{}) error {
+	switch encoding {
+	case "":
+		return nil
+	case ContentEncodingGzip:
+	default:
+		return errors.New("content_encoding must be gzip")
+	}
+
+	encoded, ok := value.(string)
+	if !ok {
+		return errors.New("a gzip value must be a base64 string")
+	}
+	body, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return errors.New("a gzip value must be a base64 string")
+	}
+	if _, err := gzip.NewReader(bytes.NewReader(body)); err != nil {
+		return errors.New("value is not gzip-encoded")
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the Accept-Encoding of r allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(accepted, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// writeEncodedBody serves the body stored in a content-encoded item: the
+// gzip bytes as they are to requests that accept gzip, decompressed for
+// everyone else. The Content-Type comes from the item's "content_type"
+// metadata, defaulting to application/octet-stream.
+func writeEncodedBody(w http.ResponseWriter, r *http.Request, item *CacheItem) {
+	encoded, _ := item.Value.(string)
+	body, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		http.Error(w, "Stored body is not valid base64", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/octet-stream"
+	if stored, ok := item.Metadata["content_type"].(string); ok && stored != "" {
+		contentType = stored
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", ContentEncodingGzip)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+		return
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "Stored body is not gzip-encoded", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+	io.Copy(w, reader)
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com". "*"
+	// allows any origin, and "*.example.com" or "https://*.example.com"
+	// allows every subdomain. Empty allows no cross-origin requests.
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	// AllowCredentials lets browsers send cookies and auth headers; the
+	// request's origin is then echoed even when "*" is allowed
+	AllowCredentials bool `json:"allow_credentials"`
+	// MaxAge is how long, in seconds, browsers may cache a preflight; 0
+	// leaves it to the browser
+	MaxAge int `json:"max_age"`
+}
+
+// DefaultCORSConfig allows any origin to use the whole API, as cache-server
+// always has; restrict AllowedOrigins in production
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-ID"},
+	}
+}
+
+// allowsOrigin reports whether origin matches an entry of AllowedOrigins
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		scheme, pattern, found := strings.Cut(allowed, "://")
+		if !found {
+			scheme, pattern = "", allowed
+		}
+		if scheme != "" && !strings.EqualFold(scheme, u.Scheme) {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware adds CORS headers for allowed origins and answers
+// preflight requests, refusing those from other origins with 403. Requests
+// without an Origin header are not cross-origin and pass through untouched.
+func (dc *DistroCache) corsMiddleware(next http.Handler) http.Handler {
+	cors := dc.config.CORS
+	methods := strings.Join(cors.AllowedMethods, ", ")
+	headers := strings.Join(cors.AllowedHeaders, ", ")
+	wildcard := slices.Contains(cors.AllowedOrigins, "*") && !cors.AllowCredentials
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+			if !cors.allowsOrigin(origin) {
+				if preflight {
+					http.Error(w, "Origin not allowed", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cors.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if r.Method == "OPTIONS" {
+			if methods != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+			}
+			if headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			if cors.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// preflight sends a CORS preflight for a GET of /api/v1/cache/key from origin
+func preflight(dc *DistroCache, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", "/api/v1/cache/key", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "GET")
+
+	rec := httptest.NewRecorder()
+	dc.Router().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.CORS = CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowedHeaders:   []string{"Content-Type"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		}
+	})
+
+	for _, origin := range []string{"https://evil.example.net", "http://app.example.com", "https://example.org"} {
+		if rec := preflight(dc, origin); rec.Code != http.StatusForbidden {
+			t.Errorf("preflight from %s: status %d, want 403", origin, rec.Code)
+		} else if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("preflight from %s allowed origin %q", origin, got)
+		}
+	}
+
+	for _, origin := range []string{"https://app.example.com", "https://eu.api.example.org"} {
+		rec := preflight(dc, origin)
+		if rec.Code != http.StatusOK {
+			t.Errorf("preflight from %s: status %d, want 200", origin, rec.Code)
+			continue
+		}
+		for header, want := range map[string]string{
+			"Access-Control-Allow-Origin":      origin,
+			"Access-Control-Allow-Methods":     "GET, POST",
+			"Access-Control-Allow-Headers":     "Content-Type",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Max-Age":           "600",
+			"Vary":                             "Origin",
+		} {
+			if got := rec.Header().Get(header); got != want {
+				t.Errorf("preflight from %s: %s = %q, want %q", origin, header, got, want)
+			}
+		}
+	}
+}
+
+func TestCORSDefaultAllowsAnyOrigin(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	rec := preflight(dc, "https://anywhere.example.com")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("preflight with the default config: status %d, origin %q; want 200 and *",
+			rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+)
+
+// ErrNotInteger is returned when incrementing a value that is not an integer
+var ErrNotInteger = errors.New("value is not an integer")
+
+// ErrOverflow is returned when an increment would overflow int64
+var ErrOverflow = errors.New("increment would overflow")
+
+// toInt64 converts a stored value to an int64 counter without losing precision.
+// Values decoded with UseNumber arrive as json.Number; float64 is only accepted
+// when it holds an exactly representable integer.
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		return n, nil
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+			return 0, ErrNotInteger
+		}
+		return int64(v), nil
+	default:
+		return 0, ErrNotInteger
+	}
+}
+
+// Increment atomically adds delta to the integer stored at key and returns
+// the new value. A missing or expired key is treated as zero and created
+// with the default TTL. Counters are stored as int64 so they keep full
+// precision beyond 2^53.
+func (dc *DistroCache) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	if err := dc.validateKey(key); err != nil {
+		return 0, err
+	}
+
+	if err := dc.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer dc.unlock()
+
+	if err := dc.authorizeLocked(ctx, key); err != nil {
+		return 0, err
+	}
+
+	item, exists := dc.data.Get(ctx, key)
+	if exists && dc.isExpired(item) {
+		dc.expireItem(ctx, key, item)
+		exists = false
+	}
+
+	var current int64
+	if exists {
+		value, err := dc.storedValue(item)
+		if err != nil {
+			return 0, err
+		}
+		n, err := toInt64(value)
+		if err != nil {
+			return 0, err
+		}
+		current = n
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	result := current + delta
+
+	if err := dc.writeThrough(ctx, key, result); err != nil {
+		return 0, err
+	}
+	if dc.writeBehind != nil {
+		dc.writeBehind.MarkDirty(key, result)
+	}
+
+	if exists {
+		// Store a copy so readers holding the old item never see it change
+		updated := *item
+		updated.Value = result
+		updated.Compression = ""
+		updated.Compressed = nil
+		updated.Chunks, updated.ChunkedBytes = 0, 0
+		updated.Size = estimateSize(result)
+		updated.Version = dc.nextVersion(ctx, key)
+		dc.dropChunks(key)
+		if err := dc.data.Set(ctx, key, &updated); err != nil {
+			return 0, err
+		}
+		return result, nil
+	}
+
+	if dc.data.Len() >= dc.config.MaxSize {
+		dc.evict(ctx)
+	}
+
+	now := dc.now()
+	err := dc.putItem(ctx, key, &CacheItem{
+		Key:         key,
+		Value:       result,
+		Size:        estimateSize(result),
+		Version:     dc.nextVersion(ctx, key),
+		TTL:         dc.config.DefaultTTL,
+		CreatedAt:   now,
+		AccessedAt:  now,
+		AccessCount: 1,
+		Metadata:    make(map[string]interface{}),
+	})
+	if err != nil {
+		return 0, err
+	}
+	delete(dc.tombstones, key)
+	dc.stats.recordSet()
+	dc.updateSizeGauges()
+	return result, nil
+}
+
+func (dc *DistroCache) handleIncrement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	var req struct {
+		Delta json.Number `json:"delta"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// An empty body increments by one
+	delta := int64(1)
+	if req.Delta != "" {
+		n, err := req.Delta.Int64()
+		if err != nil {
+			http.Error(w, "Delta must be an integer", http.StatusBadRequest)
+			return
+		}
+		delta = n
+	}
+
+	value, err := dc.Increment(ctx, key, delta)
+	if errors.Is(err, ErrNotInteger) || errors.Is(err, ErrOverflow) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":   key,
+		"value": value,
+	})
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestIncrement(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := dc.Increment(ctx, "hits", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Increment = %d, want %d", got, want)
+		}
+	}
+
+	if err := dc.Set(ctx, "big", int64(math.MaxInt64-1), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dc.Increment(ctx, "big", 2); err != ErrOverflow {
+		t.Errorf("overflowing Increment error = %v, want ErrOverflow", err)
+	}
+
+	if err := dc.Set(ctx, "name", "alice", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dc.Increment(ctx, "name", 1); err != ErrNotInteger {
+		t.Errorf("Increment of a string error = %v, want ErrNotInteger", err)
+	}
+}
+
+// TestIncrementKeepsPrecision checks a counter past 2^53 survives a set and
+// an increment over HTTP, where a float64 would round it
+func TestIncrementKeepsPrecision(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/big",
+		map[string]interface{}{"value": json.Number("9007199254740993"), "ttl": 60})
+
+	var incremented struct {
+		Value json.Number `json:"value"`
+	}
+	body := mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/big/incr", map[string]int{"delta": 1})
+	if err := json.Unmarshal(body, &incremented); err != nil {
+		t.Fatal(err)
+	}
+	if incremented.Value != "9007199254740994" {
+		t.Errorf("incremented value = %s, want 9007199254740994", incremented.Value)
+	}
+
+	var item struct {
+		Value json.Number `json:"value"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/big", nil)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Value != "9007199254740994" {
+		t.Errorf("stored value = %s, want 9007199254740994", item.Value)
+	}
+}
+
+// TestIncrementDoesNotMutateReadItems checks, under -race, that an item a
+// reader got back is never changed by a later Increment
+func TestIncrementDoesNotMutateReadItems(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	if _, err := dc.Increment(ctx, "counter", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			if _, err := dc.Increment(ctx, "counter", 1); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			item, found := dc.Get(ctx, "counter")
+			if !found {
+				t.Error("counter not found")
+				return
+			}
+			before, _ := json.Marshal(item.Value)
+			after, _ := json.Marshal(item.Value)
+			if string(before) != string(after) {
+				t.Errorf("value changed under a reader: %s then %s", before, after)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
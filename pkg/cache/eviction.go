@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Eviction policies
+const (
+	EvictionLRU  = "lru"
+	EvictionSLRU = "slru"
+	EvictionARC  = "arc"
+	EvictionLRUK = "lru-k"
+)
+
+// EvictionPolicy tracks how keys are used and chooses which to evict when the
+// cache is full. Insert, Remove and Victim are called with the cache write
+// lock held, but Access is called under the read lock, so implementations
+// must do their own locking.
+type EvictionPolicy interface {
+	// Insert records that key was stored, either new or overwritten
+	Insert(key string)
+	// Access records a cache hit on key
+	Access(key string)
+	// Remove forgets a key that left the cache for any reason
+	Remove(key string)
+	// Victim returns the key that should be evicted next, passing over keys
+	// for which skip returns true. skip may be nil.
+	Victim(skip func(key string) bool) (string, bool)
+}
+
+// newEvictionPolicy returns the policy with the given name; k is the K of
+// LRU-K. The default LRU policy is served by scanning item access times and
+// returns nil.
+func newEvictionPolicy(name string, capacity, k int) (EvictionPolicy, error) {
+	switch name {
+	case "", EvictionLRU:
+		return nil, nil
+	case EvictionSLRU:
+		return newSLRUPolicy(capacity), nil
+	case EvictionARC:
+		return newARCPolicy(capacity), nil
+	case EvictionLRUK:
+		return newLRUKPolicy(k), nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q", name)
+	}
+}
+
+// isResident reports whether an item was written less than MinResidency ago
+// and should be spared from eviction
+func (dc *DistroCache) isResident(item *CacheItem, now time.Time) bool {
+	return dc.config.MinResidency > 0 && now.Sub(item.CreatedAt) < dc.config.MinResidency
+}
+
+// evict removes one item chosen by the eviction policy. Items inside their
+// MinResidency are only evicted when nothing else is evictable. Callers must
+// hold the write lock.
+func (dc *DistroCache) evict(ctx context.Context) {
+	now := dc.now()
+
+	var key string
+	var found bool
+	if dc.eviction == nil {
+		key, found = dc.lruVictim(now)
+	} else if dc.config.MinResidency > 0 {
+		key, found = dc.eviction.Victim(func(key string) bool {
+			item, exists := dc.data.Get(ctx, key)
+			return exists && dc.isResident(item, now)
+		})
+		if !found {
+			key, found = dc.eviction.Victim(nil)
+		}
+	} else {
+		key, found = dc.eviction.Victim(nil)
+	}
+	if !found {
+		return
+	}
+
+	dc.undo.record(ctx, dc, key)
+	if item, exists := dc.data.Get(ctx, key); exists {
+		dc.removeFromTagIndex(key, item.Tags)
+		dc.removed(item, EvictReasonCapacity)
+	}
+	dc.dropItem(ctx, key)
+	dc.stats.recordEviction()
+}
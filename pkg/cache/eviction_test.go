@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMinResidencyDuringWarmup(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.Clock = clock
+		config.MaxSize = 10
+		config.MinResidency = 5 * time.Second
+	})
+	ctx := context.Background()
+	set := func(key string) {
+		t.Helper()
+		if err := dc.Set(ctx, key, key, time.Hour, nil); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	for i := 0; i < 5; i++ {
+		set(fmt.Sprintf("old:%d", i))
+	}
+	clock.Advance(time.Minute)
+
+	// The warmup's first writes are the least recently used, but still young
+	for i := 0; i < 5; i++ {
+		set(fmt.Sprintf("warm:%d", i))
+	}
+	for i := 0; i < 5; i++ {
+		dc.Get(ctx, fmt.Sprintf("old:%d", i))
+	}
+	for i := 5; i < 8; i++ {
+		set(fmt.Sprintf("warm:%d", i))
+	}
+	for i := 0; i < 8; i++ {
+		if _, found := dc.Peek(ctx, fmt.Sprintf("warm:%d", i)); !found {
+			t.Errorf("warm:%d evicted while old items were evictable", i)
+		}
+	}
+
+	// Once every item is young, eviction falls back to plain LRU rather than
+	// refusing writes
+	for i := 8; i < 30; i++ {
+		set(fmt.Sprintf("warm:%d", i))
+	}
+	if got := dc.Len(); got != 10 {
+		t.Errorf("Len = %d, want MaxSize", got)
+	}
+	for i := 20; i < 30; i++ {
+		if _, found := dc.Peek(ctx, fmt.Sprintf("warm:%d", i)); !found {
+			t.Errorf("warm:%d, one of the latest writes, evicted", i)
+		}
+	}
+}
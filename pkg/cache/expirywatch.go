@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Bounds of the ?timeout= of an expiry watch
+const (
+	defaultExpiryWatchTimeout = 30 * time.Second
+	maxExpiryWatchTimeout     = 5 * time.Minute
+)
+
+// watchExpiry registers a channel that is closed when key expires, and
+// returns it with the time the item is due to expire (zero if it never
+// does). A nil channel means the item has already expired.
+func (dc *DistroCache) watchExpiry(ctx context.Context, key string) (chan struct{}, time.Time, error) {
+	if err := dc.lock(ctx); err != nil {
+		return nil, time.Time{}, err
+	}
+	defer dc.unlock()
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists {
+		return nil, time.Time{}, ErrNotFound
+	}
+	if dc.isExpired(item) {
+		return nil, time.Time{}, nil
+	}
+
+	var expiresAt time.Time
+	if item.TTL > 0 {
+		expiresAt = item.CreatedAt.Add(item.TTL)
+	}
+
+	ch := make(chan struct{})
+	if dc.expiryWatchers == nil {
+		dc.expiryWatchers = make(map[string][]chan struct{})
+	}
+	dc.expiryWatchers[key] = append(dc.expiryWatchers[key], ch)
+	return ch, expiresAt, nil
+}
+
+// unwatchExpiry drops a watch that gave up before its key expired
+func (dc *DistroCache) unwatchExpiry(key string, ch chan struct{}) {
+	dc.mutex.Lock()
+	defer dc.unlock()
+
+	watchers := slices.DeleteFunc(dc.expiryWatchers[key], func(c chan struct{}) bool {
+		return c == ch
+	})
+	if len(watchers) == 0 {
+		delete(dc.expiryWatchers, key)
+	} else {
+		dc.expiryWatchers[key] = watchers
+	}
+}
+
+// notifyExpired wakes every watch on key; callers must hold the write lock
+func (dc *DistroCache) notifyExpired(key string) {
+	for _, ch := range dc.expiryWatchers[key] {
+		close(ch)
+	}
+	delete(dc.expiryWatchers, key)
+}
+
+// WaitForExpiry blocks until key expires or ctx is done. The key is removed
+// as soon as its TTL passes rather than on the next cleanup tick, so waiters
+// are woken promptly. It fails with ErrNotFound when the key does not exist,
+// or is deleted before it expires.
+func (dc *DistroCache) WaitForExpiry(ctx context.Context, key string) error {
+	if err := dc.validateKey(key); err != nil {
+		return err
+	}
+
+	for {
+		ch, expiresAt, err := dc.watchExpiry(ctx, key)
+		if err != nil {
+			return err
+		}
+		if ch == nil {
+			dc.deleteExpired(ctx, key)
+			return nil
+		}
+
+		var due <-chan time.Time
+		stop := func() {}
+		if !expiresAt.IsZero() {
+			timer := time.NewTimer(expiresAt.Sub(dc.now()))
+			due, stop = timer.C, func() { timer.Stop() }
+		}
+
+		select {
+		case <-ch:
+			stop()
+			return nil
+		case <-ctx.Done():
+			stop()
+			dc.unwatchExpiry(key, ch)
+			return ctx.Err()
+		case <-due:
+			dc.deleteExpired(ctx, key)
+		}
+
+		// The key was replaced with a later expiry, or the lock was busy
+		select {
+		case <-ch:
+			return nil
+		default:
+			dc.unwatchExpiry(key, ch)
+		}
+	}
+}
+
+func (dc *DistroCache) handleExpiryWatch(w http.ResponseWriter, r *http.Request) {
+	timeout := defaultExpiryWatchTimeout
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxExpiryWatchTimeout {
+			http.Error(w, "timeout must be a duration up to "+maxExpiryWatchTimeout.String(), http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	// Outlive the server's WriteTimeout for the length of the watch
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 5*time.Second))
+
+	key := requestKey(r)
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+	if err := dc.authorizeKey(r.Context(), key, false); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	stop := context.AfterFunc(dc.streams, cancel)
+	defer stop()
+
+	event := "expired"
+	err := dc.WaitForExpiry(ctx, key)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		// Canceled when the server is shutting down
+		event = "timeout"
+	case err != nil:
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"event": event,
+		"key":   mux.Vars(r)["key"],
+	})
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExpiryWatchFiresPromptly(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	const ttl = 200 * time.Millisecond
+	if err := dc.Set(ctx, "user:1", "alice", ttl, nil); err != nil {
+		t.Fatal(err)
+	}
+	set := time.Now()
+
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/user:1/expiry-watch?timeout=5s", nil)
+	waited := time.Since(set)
+
+	var event struct {
+		Event string `json:"event"`
+		Key   string `json:"key"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != "expired" || event.Key != "user:1" {
+		t.Errorf("event = %+v, want expired for user:1", event)
+	}
+	// Not held until the cleanup tick, let alone the watch timeout
+	if waited < ttl || waited > ttl+200*time.Millisecond {
+		t.Errorf("watch returned %v after the set, want shortly after %v", waited, ttl)
+	}
+	if _, found := dc.Peek(ctx, "user:1"); found {
+		t.Error("expired key still present after the watch fired")
+	}
+}
+
+func TestExpiryWatchTimesOut(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	if err := dc.Set(context.Background(), "config", "v1", time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/config/expiry-watch?timeout=50ms", nil)
+	var event struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != "timeout" {
+		t.Errorf("event = %q, want timeout", event.Event)
+	}
+
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	if watchers := len(dc.expiryWatchers["config"]); watchers != 0 {
+		t.Errorf("%d watchers left registered after the timeout", watchers)
+	}
+}
+
+func TestExpiryWatchMissingKey(t *testing.T) {
+	dc := newTestCache(t, nil)
+	mustServe(t, dc, http.StatusNotFound, "GET", "/api/v1/cache/missing/expiry-watch", nil)
+	mustServe(t, dc, http.StatusBadRequest, "GET", "/api/v1/cache/missing/expiry-watch?timeout=1h", nil)
+}
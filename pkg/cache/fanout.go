@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+)
+
+// FanOutRule invalidates a set of tags whenever a key matching
+// TriggerPattern is set or deleted
+type FanOutRule struct {
+	TriggerPattern string   `json:"trigger_pattern"`
+	InvalidateTags []string `json:"invalidate_tags"`
+}
+
+// AddFanOutRule registers a fan-out invalidation rule
+func (dc *DistroCache) AddFanOutRule(rule FanOutRule) error {
+	if _, err := path.Match(rule.TriggerPattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", rule.TriggerPattern, err)
+	}
+
+	dc.rulesMu.Lock()
+	defer dc.rulesMu.Unlock()
+
+	dc.fanOut = append(dc.fanOut, rule)
+	return nil
+}
+
+// FanOutRules returns a snapshot of the registered fan-out rules
+func (dc *DistroCache) FanOutRules() []FanOutRule {
+	dc.rulesMu.RLock()
+	defer dc.rulesMu.RUnlock()
+
+	rules := make([]FanOutRule, len(dc.fanOut))
+	copy(rules, dc.fanOut)
+	return rules
+}
+
+// applyFanOut invalidates the tags of every rule matching key. It must be
+// called without holding the cache lock.
+func (dc *DistroCache) applyFanOut(ctx context.Context, key string) {
+	var tags []string
+
+	dc.rulesMu.RLock()
+	for _, rule := range dc.fanOut {
+		if matchKeyPattern(rule.TriggerPattern, key) {
+			tags = append(tags, rule.InvalidateTags...)
+		}
+	}
+	dc.rulesMu.RUnlock()
+
+	for _, tag := range uniqueStrings(tags) {
+		if _, err := dc.InvalidateByTag(ctx, tag); err != nil {
+			slog.WarnContext(ctx, "fan-out invalidation failed", "key", key, "tag", tag, "error", err)
+		}
+	}
+}
+
+func (dc *DistroCache) handleListFanOutRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": dc.FanOutRules(),
+	})
+}
+
+func (dc *DistroCache) handleAddFanOutRule(w http.ResponseWriter, r *http.Request) {
+	var rule FanOutRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	rule.InvalidateTags = uniqueStrings(rule.InvalidateTags)
+	if rule.TriggerPattern == "" || len(rule.InvalidateTags) == 0 {
+		http.Error(w, "trigger_pattern and invalidate_tags are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := dc.AddFanOutRule(rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
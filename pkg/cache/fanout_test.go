@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFanOutOnDelete(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	for key, tags := range map[string][]string{
+		"product:42":           {"products"},
+		"category:electronics": {"categories"},
+		"search:laptop":        {"search"},
+		"homepage:featured":    {"homepage"},
+	} {
+		if err := dc.Set(ctx, key, "value", time.Minute, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustServe(t, dc, http.StatusCreated, "POST", "/api/v1/admin/fan-out-rules",
+		map[string]interface{}{"trigger_pattern": "product:*", "invalidate_tags": []string{"categories", "search"}})
+	mustServe(t, dc, http.StatusBadRequest, "POST", "/api/v1/admin/fan-out-rules",
+		map[string]interface{}{"trigger_pattern": "product:*"})
+
+	mustServe(t, dc, http.StatusOK, "DELETE", "/api/v1/cache/product:42", nil)
+
+	for _, key := range []string{"category:electronics", "search:laptop"} {
+		if _, found := dc.Peek(ctx, key); found {
+			t.Errorf("%s survived the fan-out from deleting product:42", key)
+		}
+	}
+	if _, found := dc.Peek(ctx, "homepage:featured"); !found {
+		t.Error("homepage:featured was invalidated without a rule naming its tag")
+	}
+}
+
+func TestFanOutOnSet(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	if err := dc.AddFanOutRule(FanOutRule{TriggerPattern: "product:*", InvalidateTags: []string{"categories"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Set(ctx, "category:books", "value", time.Minute, []string{"categories"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dc.Set(ctx, "order:1", "value", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := dc.Peek(ctx, "category:books"); !found {
+		t.Fatal("setting a key no rule matches invalidated category:books")
+	}
+
+	if err := dc.Set(ctx, "product:7", "value", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := dc.Peek(ctx, "category:books"); found {
+		t.Error("category:books survived the fan-out from setting product:7")
+	}
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// ErrStaleFencingToken is returned when a fenced operation presents a token
+// other than the latest one issued for its lock
+var ErrStaleFencingToken = errors.New("fencing token is stale")
+
+// fenceKey is the context key for fence
+type fenceKey struct{}
+
+// fence names the lock an operation runs under and the token its holder
+// was given
+type fence struct {
+	Lock  string
+	Token uint64
+}
+
+// WithFencingToken returns a context whose writes are only applied while
+// token is the latest fencing token issued for lockKey. A holder that
+// stalls past its lock's ttl, and resumes after someone else acquired the
+// lock, then fails with ErrStaleFencingToken instead of overwriting the new
+// holder's work.
+func WithFencingToken(ctx context.Context, lockKey string, token uint64) context.Context {
+	return context.WithValue(ctx, fenceKey{}, fence{Lock: lockKey, Token: token})
+}
+
+// issueFencingToken returns the next token for lockKey; callers must hold
+// the write lock. Tokens come from one sequence for all locks, so a lock's
+// entry can be dropped with its key (see dropItem) and its next holder
+// still gets a higher token than any it had before.
+func (dc *DistroCache) issueFencingToken(lockKey string) uint64 {
+	if dc.fencingTokens == nil {
+		dc.fencingTokens = make(map[string]uint64)
+	}
+	dc.lastFencingToken++
+	dc.fencingTokens[lockKey] = dc.lastFencingToken
+	return dc.lastFencingToken
+}
+
+// grantFencingToken returns the token for an acquisition of lockKey: the
+// current one when its holder is renewing, and a new one otherwise; callers
+// must hold the write lock
+func (dc *DistroCache) grantFencingToken(lockKey string, renewing bool) uint64 {
+	if latest, issued := dc.fencingTokens[lockKey]; renewing && issued {
+		return latest
+	}
+	return dc.issueFencingToken(lockKey)
+}
+
+// CheckFencingToken reports whether token is the latest fencing token
+// issued for lockKey. Lower tokens are stale, higher ones were never issued,
+// and a lock that was never acquired, or has been released, accepts none.
+func (dc *DistroCache) CheckFencingToken(lockKey string, token uint64) bool {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	return dc.fencingTokenValid(lockKey, token)
+}
+
+// fencingTokenValid is CheckFencingToken for callers holding the lock
+func (dc *DistroCache) fencingTokenValid(lockKey string, token uint64) bool {
+	latest, issued := dc.fencingTokens[lockKey]
+	return issued && token == latest
+}
+
+// checkFence fails with ErrStaleFencingToken when ctx carries a fence whose
+// token is no longer current; callers must hold the write lock
+func (dc *DistroCache) checkFence(ctx context.Context) error {
+	f, fenced := ctx.Value(fenceKey{}).(fence)
+	if !fenced || dc.fencingTokenValid(f.Lock, f.Token) {
+		return nil
+	}
+	return ErrStaleFencingToken
+}
+
+// fencingMiddleware fences requests sending X-Fencing-Lock and
+// X-Fencing-Token, so the writes they make are rejected with 409 Conflict
+// once another caller has acquired the lock
+func fencingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lockKey := r.Header.Get("X-Fencing-Lock")
+		raw := r.Header.Get("X-Fencing-Token")
+		if lockKey == "" && raw == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := strconv.ParseUint(raw, 10, 64)
+		if lockKey == "" || err != nil {
+			http.Error(w, "X-Fencing-Lock and a numeric X-Fencing-Token are required together", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithFencingToken(r.Context(), lockKey, token)))
+	})
+}
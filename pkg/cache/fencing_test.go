@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFencingTokenRejectsPausedHolder(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	first, acquired, err := dc.AcquireLock(ctx, "job", 50*time.Millisecond, "first")
+	if err != nil || !acquired {
+		t.Fatalf("AcquireLock: acquired=%v err=%v", acquired, err)
+	}
+
+	// The first holder pauses past its TTL and the lock passes to another
+	time.Sleep(100 * time.Millisecond)
+	second, acquired, err := dc.AcquireLock(ctx, "job", time.Minute, "second")
+	if err != nil || !acquired {
+		t.Fatalf("AcquireLock after expiry: acquired=%v err=%v", acquired, err)
+	}
+	if second <= first {
+		t.Fatalf("new holder got token %d, want more than %d", second, first)
+	}
+
+	if err := dc.Set(WithFencingToken(ctx, "job", second), "doc", "second", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	err = dc.Set(WithFencingToken(ctx, "job", first), "doc", "first", time.Minute, nil)
+	if !errors.Is(err, ErrStaleFencingToken) {
+		t.Fatalf("Set with the paused holder's token error = %v, want ErrStaleFencingToken", err)
+	}
+	if item, _ := dc.Peek(ctx, "doc"); item.Value != "second" {
+		t.Errorf("doc = %v, want the current holder's write", item.Value)
+	}
+
+	code, _ := serve(t, dc, "POST", "/api/v1/cache/doc", map[string]interface{}{"value": "first"},
+		"X-Fencing-Lock", "job", "X-Fencing-Token", toString(first))
+	if code != 409 {
+		t.Errorf("HTTP set with a stale token: status %d, want 409", code)
+	}
+}
+
+func TestFencingTokenSurvivesRenewal(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	token, _, err := dc.AcquireLock(ctx, "job", time.Minute, "holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed, acquired, err := dc.AcquireLock(ctx, "job", time.Minute, "holder")
+	if err != nil || !acquired {
+		t.Fatalf("renewal: acquired=%v err=%v", acquired, err)
+	}
+	if renewed != token {
+		t.Errorf("renewal returned token %d, want the held token %d", renewed, token)
+	}
+	if err := dc.Set(WithFencingToken(ctx, "job", token), "doc", "value", time.Minute, nil); err != nil {
+		t.Errorf("Set with the token from before the renewal: %v", err)
+	}
+}
+
+func TestFencingTokensAreDroppedWithTheirLock(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	var last uint64
+	for i := 0; i < 100; i++ {
+		token, acquired, err := dc.AcquireLock(ctx, "job:"+toString(i%10), time.Minute, "holder")
+		if err != nil || !acquired {
+			t.Fatalf("AcquireLock: acquired=%v err=%v", acquired, err)
+		}
+		if token <= last {
+			t.Fatalf("token %d after %d, want tokens to keep increasing", token, last)
+		}
+		last = token
+		if err := dc.ReleaseSemaphore("job:"+toString(i%10), "holder"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(dc.fencingTokens) != 0 {
+		t.Errorf("%d fencing tokens kept after every lock was released", len(dc.fencingTokens))
+	}
+	if dc.CheckFencingToken("job:9", last) {
+		t.Error("the token of a released lock still accepted")
+	}
+
+	// A deleted lock key forgets its token too
+	if _, _, err := dc.AcquireLock(ctx, "job:0", time.Minute, "holder"); err != nil {
+		t.Fatal(err)
+	}
+	dc.Delete(ctx, "job:0")
+	if len(dc.fencingTokens) != 0 {
+		t.Errorf("%d fencing tokens kept after the lock key was deleted", len(dc.fencingTokens))
+	}
+}
@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// writeFreshness sets the Last-Modified and Cache-Control headers CDNs use to
+// cache a GET of an item created at createdAt with the given TTL. When the
+// request's If-Modified-Since shows the client already has this version it
+// answers 304 and returns true.
+func (dc *DistroCache) writeFreshness(w http.ResponseWriter, r *http.Request, createdAt time.Time, ttl time.Duration) bool {
+	w.Header().Set("Last-Modified", createdAt.UTC().Format(http.TimeFormat))
+	if ttl > 0 {
+		remaining := max(ttl-dc.now().Sub(createdAt), 0)
+		w.Header().Set("Cache-Control", "max-age="+strconv.FormatInt(int64(remaining/time.Second), 10))
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	// Last-Modified has one-second resolution, so compare at that resolution
+	if err != nil || createdAt.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFreshnessHeaders(t *testing.T) {
+	clock := NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	dc := newTestCache(t, func(config *CacheConfig) { config.Clock = clock })
+	router := dc.Router()
+	get := func(headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/cache/page", nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if err := dc.Set(context.Background(), "page", "<html>", 100*time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := get()
+	lastModified := rec.Header().Get("Last-Modified")
+	if lastModified != "Mon, 01 Jan 2024 12:00:00 GMT" {
+		t.Errorf("Last-Modified = %q, want the creation time", lastModified)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "max-age=100" {
+		t.Errorf("Cache-Control = %q, want max-age=100", got)
+	}
+
+	clock.Advance(40 * time.Second)
+	if got := get().Header().Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("Cache-Control 40s later = %q, want max-age=60", got)
+	}
+
+	if rec := get("If-Modified-Since", lastModified); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("If-Modified-Since for an unchanged entry: status %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+
+	// A rewrite makes the client's copy stale
+	clock.Advance(5 * time.Second)
+	if err := dc.Set(context.Background(), "page", "<html>v2", 100*time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("If-Modified-Since", lastModified); rec.Code != http.StatusOK {
+		t.Errorf("If-Modified-Since for a rewritten entry: status %d, want 200", rec.Code)
+	}
+}
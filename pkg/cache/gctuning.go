@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// GC percents and heap watermarks, as fractions of MaxMemoryBytes, used by
+// AutoGCTuning
+const (
+	gcPercentNormal   = 100
+	gcPercentDeferred = 400
+	gcLowWatermark    = 0.5
+	gcHighWatermark   = 0.8
+)
+
+// gcPercentFor picks the GC percent for a heap of heapAlloc bytes: 100 under
+// half of limit, 400 over 80% of it, and current in between so the setting
+// does not flap around a single threshold
+func gcPercentFor(heapAlloc uint64, limit int64, current int) int {
+	usage := float64(heapAlloc) / float64(limit)
+	switch {
+	case usage < gcLowWatermark:
+		return gcPercentNormal
+	case usage > gcHighWatermark:
+		return gcPercentDeferred
+	default:
+		return current
+	}
+}
+
+// GCStats summarises garbage collection as seen by the GC tuner
+type GCStats struct {
+	GCPercent      int     `json:"gc_percent"`
+	HeapAllocBytes uint64  `json:"heap_alloc_bytes"`
+	NumGC          uint32  `json:"num_gc"`
+	PauseTotalMs   float64 `json:"pause_total_ms"`
+	LastPauseMs    float64 `json:"last_pause_ms"`
+}
+
+// gcTuner samples the heap every GCTuningInterval and moves the GC percent
+// between 100 and 400 as the heap nears MaxMemoryBytes, recording GC pauses
+type gcTuner struct {
+	cache     *DistroCache
+	percent   int
+	lastNumGC uint32
+	latest    atomic.Pointer[GCStats]
+}
+
+// newGCTuner creates a tuner for the cache's configured memory budget
+func newGCTuner(cache *DistroCache) *gcTuner {
+	return &gcTuner{cache: cache}
+}
+
+// run tunes the GC until ctx is done, then restores the GC percent the
+// process started with
+func (t *gcTuner) run(ctx context.Context) {
+	interval := t.cache.config.GCTuningInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	original := debug.SetGCPercent(gcPercentNormal)
+	debug.SetGCPercent(original)
+	t.percent = original
+	defer debug.SetGCPercent(original)
+
+	t.check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.check()
+		}
+	}
+}
+
+// check takes one heap sample, records pauses of collections since the
+// previous sample and adjusts the GC percent
+func (t *gcTuner) check() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	// PauseNs is a ring of the last 256 pauses; collection n is at (n+255)%256
+	first := t.lastNumGC + 1
+	if ms.NumGC > 256 && first < ms.NumGC-255 {
+		first = ms.NumGC - 255
+	}
+	for n := first; n <= ms.NumGC; n++ {
+		t.cache.stats.GCPause.Observe(float64(ms.PauseNs[(n+255)%256]) / 1e9)
+	}
+	t.lastNumGC = ms.NumGC
+
+	if next := gcPercentFor(ms.HeapAlloc, t.cache.config.MaxMemoryBytes, t.percent); next != t.percent {
+		debug.SetGCPercent(next)
+		slog.Info("gc percent adjusted", "from", t.percent, "to", next, "heap_alloc_bytes", ms.HeapAlloc)
+		t.percent = next
+	}
+
+	stats := GCStats{
+		GCPercent:      t.percent,
+		HeapAllocBytes: ms.HeapAlloc,
+		NumGC:          ms.NumGC,
+		PauseTotalMs:   float64(ms.PauseTotalNs) / 1e6,
+	}
+	if ms.NumGC > 0 {
+		stats.LastPauseMs = float64(ms.PauseNs[(ms.NumGC+255)%256]) / 1e6
+	}
+	t.latest.Store(&stats)
+}
+
+// snapshot returns the GC stats of the latest sample
+func (t *gcTuner) snapshot() GCStats {
+	if stats := t.latest.Load(); stats != nil {
+		return *stats
+	}
+	return GCStats{}
+}
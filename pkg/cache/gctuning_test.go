@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestGCPercentFor(t *testing.T) {
+	const limit = 1000
+	for _, tc := range []struct {
+		heap    uint64
+		current int
+		want    int
+	}{
+		{100, gcPercentDeferred, gcPercentNormal},
+		{499, gcPercentDeferred, gcPercentNormal},
+		{801, gcPercentNormal, gcPercentDeferred},
+		{2000, gcPercentNormal, gcPercentDeferred},
+		// Between the watermarks the setting is left alone
+		{600, gcPercentNormal, gcPercentNormal},
+		{600, gcPercentDeferred, gcPercentDeferred},
+	} {
+		if got := gcPercentFor(tc.heap, limit, tc.current); got != tc.want {
+			t.Errorf("gcPercentFor(%d, %d, %d) = %d, want %d", tc.heap, limit, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestGCTunerAdjustsPercent(t *testing.T) {
+	original := debug.SetGCPercent(gcPercentNormal)
+	t.Cleanup(func() { debug.SetGCPercent(original) })
+
+	dc := newTestCache(t, nil)
+	tuner := newGCTuner(dc)
+	tuner.percent = gcPercentNormal
+
+	// Any live heap is far above 80% of a one-byte budget
+	dc.config.MaxMemoryBytes = 1
+	tuner.check()
+	if got := debug.SetGCPercent(gcPercentDeferred); got != gcPercentDeferred {
+		t.Errorf("GC percent with the heap over budget = %d, want %d", got, gcPercentDeferred)
+	}
+	if got := tuner.snapshot().GCPercent; got != gcPercentDeferred {
+		t.Errorf("reported GC percent = %d, want %d", got, gcPercentDeferred)
+	}
+
+	dc.config.MaxMemoryBytes = 1 << 50
+	tuner.check()
+	if got := debug.SetGCPercent(gcPercentNormal); got != gcPercentNormal {
+		t.Errorf("GC percent with the heap well under budget = %d, want %d", got, gcPercentNormal)
+	}
+	if stats := tuner.snapshot(); stats.GCPercent != gcPercentNormal || stats.HeapAllocBytes == 0 {
+		t.Errorf("GC stats = %+v, want percent %d and the sampled heap", stats, gcPercentNormal)
+	}
+}
+
+func TestGCStatsReported(t *testing.T) {
+	original := debug.SetGCPercent(gcPercentNormal)
+	t.Cleanup(func() { debug.SetGCPercent(original) })
+
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.AutoGCTuning = true
+		config.MaxMemoryBytes = 1 << 50
+	})
+	if _, ok := dc.GetStats()["gc"]; !ok {
+		t.Error("stats have no gc section with AutoGCTuning enabled")
+	}
+
+	untuned := newTestCache(t, nil)
+	if _, ok := untuned.GetStats()["gc"]; ok {
+		t.Error("stats have a gc section with AutoGCTuning disabled")
+	}
+}
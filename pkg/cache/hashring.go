@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ClusterNode represents a member of the cache cluster
+type ClusterNode struct {
+	ID      string `json:"id"`
+	Address string `json:"address,omitempty"`
+	Weight  int    `json:"weight"`
+}
+
+// HashRing implements weighted consistent hashing over cluster nodes.
+// Each node is placed on the ring Weight * virtualNodes times, so nodes
+// with a higher weight are responsible for proportionally more keys.
+type HashRing struct {
+	mutex        sync.RWMutex
+	virtualNodes int
+	nodes        map[string]*ClusterNode
+	points       []uint64
+	owners       map[uint64]string
+}
+
+// NewHashRing creates an empty hash ring
+func NewHashRing(virtualNodes int) *HashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = 100
+	}
+	return &HashRing{
+		virtualNodes: virtualNodes,
+		nodes:        make(map[string]*ClusterNode),
+		owners:       make(map[uint64]string),
+	}
+}
+
+// ringHash maps a string to a position on the ring
+func ringHash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// AddNode registers a node, replacing any existing node with the same ID
+func (hr *HashRing) AddNode(node ClusterNode) {
+	if node.Weight <= 0 {
+		node.Weight = 1
+	}
+
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	hr.nodes[node.ID] = &node
+	hr.rebuild()
+}
+
+// RemoveNode removes a node from the ring
+func (hr *HashRing) RemoveNode(id string) bool {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	if _, exists := hr.nodes[id]; !exists {
+		return false
+	}
+	delete(hr.nodes, id)
+	hr.rebuild()
+	return true
+}
+
+// Nodes returns a snapshot of the registered nodes sorted by ID
+func (hr *HashRing) Nodes() []ClusterNode {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	nodes := make([]ClusterNode, 0, len(hr.nodes))
+	for _, node := range hr.nodes {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// ResponsibleNode returns the ID of the node that owns the given key,
+// or an empty string if the ring has no nodes
+func (hr *HashRing) ResponsibleNode(key string) string {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	return hr.responsible(key)
+}
+
+// Owner returns the node that owns the given key, or false if the ring has
+// no nodes
+func (hr *HashRing) Owner(key string) (ClusterNode, bool) {
+	hr.mutex.RLock()
+	defer hr.mutex.RUnlock()
+
+	node, exists := hr.nodes[hr.responsible(key)]
+	if !exists {
+		return ClusterNode{}, false
+	}
+	return *node, true
+}
+
+// responsible looks up the owner of key; callers must hold the read lock
+func (hr *HashRing) responsible(key string) string {
+	if len(hr.points) == 0 {
+		return ""
+	}
+
+	hash := ringHash(key)
+	idx := sort.Search(len(hr.points), func(i int) bool { return hr.points[i] >= hash })
+	if idx == len(hr.points) {
+		idx = 0
+	}
+	return hr.owners[hr.points[idx]]
+}
+
+// rebuild recomputes the ring positions; callers must hold the write lock
+func (hr *HashRing) rebuild() {
+	hr.points = hr.points[:0]
+	hr.owners = make(map[uint64]string)
+
+	for id, node := range hr.nodes {
+		for i := 0; i < node.Weight*hr.virtualNodes; i++ {
+			point := ringHash(id + "#" + strconv.Itoa(i))
+			if _, taken := hr.owners[point]; taken {
+				continue
+			}
+			hr.owners[point] = id
+			hr.points = append(hr.points, point)
+		}
+	}
+	sort.Slice(hr.points, func(i, j int) bool { return hr.points[i] < hr.points[j] })
+}
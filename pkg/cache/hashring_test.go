@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHashRingWeights(t *testing.T) {
+	ring := NewHashRing(1000)
+	ring.AddNode(ClusterNode{ID: "node-1", Weight: 1})
+	ring.AddNode(ClusterNode{ID: "node-2", Weight: 2})
+	ring.AddNode(ClusterNode{ID: "node-4", Weight: 4})
+
+	const keys = 70000
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		counts[ring.ResponsibleNode("key:"+strconv.Itoa(i))]++
+	}
+
+	for id, weight := range map[string]int{"node-1": 1, "node-2": 2, "node-4": 4} {
+		want := float64(keys) * float64(weight) / 7
+		if got := float64(counts[id]); math.Abs(got-want)/want > 0.05 {
+			t.Errorf("%s owns %.0f keys, want about %.0f", id, got, want)
+		}
+	}
+}
+
+func TestHashRingRemoveNode(t *testing.T) {
+	ring := NewHashRing(0)
+	if node := ring.ResponsibleNode("key"); node != "" {
+		t.Errorf("empty ring assigned key to %q", node)
+	}
+
+	ring.AddNode(ClusterNode{ID: "a"})
+	ring.AddNode(ClusterNode{ID: "b"})
+	if !ring.RemoveNode("a") || ring.RemoveNode("a") {
+		t.Fatal("RemoveNode should succeed once")
+	}
+	for i := 0; i < 100; i++ {
+		if node := ring.ResponsibleNode(strconv.Itoa(i)); node != "b" {
+			t.Fatalf("key %d assigned to %q after removing a", i, node)
+		}
+	}
+}
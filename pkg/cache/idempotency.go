@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// idempotentResponse is a recorded response replayed for duplicate requests
+type idempotentResponse struct {
+	// fingerprint identifies the request that produced the response
+	fingerprint [sha256.Size]byte
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyStore remembers responses by idempotency token for a window.
+// At most maxRecords are kept; beyond that the oldest records are dropped
+// first, so a flood of unique tokens cannot grow memory without bound.
+type idempotencyStore struct {
+	mutex      sync.Mutex
+	records    map[string]*idempotentResponse
+	order      []idempotencyEntry
+	inFlight   singleflight.Group
+	window     time.Duration
+	maxRecords int
+	now        func() time.Time
+}
+
+// idempotencyEntry is a token in insertion (and therefore expiry) order
+type idempotencyEntry struct {
+	token  string
+	record *idempotentResponse
+}
+
+// newIdempotencyStore creates a store that remembers up to maxRecords
+// responses for window
+func newIdempotencyStore(window time.Duration, maxRecords int, now func() time.Time) *idempotencyStore {
+	if maxRecords <= 0 {
+		maxRecords = 10000
+	}
+	return &idempotencyStore{
+		records:    make(map[string]*idempotentResponse),
+		window:     window,
+		maxRecords: maxRecords,
+		now:        now,
+	}
+}
+
+// lookup returns the unexpired response recorded for token
+func (s *idempotencyStore) lookup(token string) (*idempotentResponse, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.records[token]
+	if !exists || s.now().After(record.expiresAt) {
+		return nil, false
+	}
+	return record, true
+}
+
+// store records the response for token, dropping the oldest records when
+// the store is full
+func (s *idempotencyStore) store(token string, record *idempotentResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record.expiresAt = s.now().Add(s.window)
+	s.records[token] = record
+	s.order = append(s.order, idempotencyEntry{token: token, record: record})
+
+	for len(s.records) > s.maxRecords {
+		s.dropOldest()
+	}
+}
+
+// prune drops expired records
+func (s *idempotencyStore) prune() {
+	now := s.now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.order) > 0 && now.After(s.order[0].record.expiresAt) {
+		s.dropOldest()
+	}
+}
+
+// dropOldest removes the oldest entry; callers must hold the lock
+func (s *idempotencyStore) dropOldest() {
+	oldest := s.order[0]
+	s.order[0] = idempotencyEntry{}
+	s.order = s.order[1:]
+
+	// The token may have been recorded again since this entry was added
+	if s.records[oldest.token] == oldest.record {
+		delete(s.records, oldest.token)
+	}
+}
+
+// responseCapture records a handler's response instead of sending it
+type responseCapture struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rc *responseCapture) Header() http.Header {
+	return rc.header
+}
+
+func (rc *responseCapture) Write(b []byte) (int, error) {
+	if rc.status == 0 {
+		rc.status = http.StatusOK
+	}
+	return rc.body.Write(b)
+}
+
+func (rc *responseCapture) WriteHeader(status int) {
+	if rc.status == 0 {
+		rc.status = status
+	}
+}
+
+// replay writes a recorded response
+func (record *idempotentResponse) replay(w http.ResponseWriter, replayed bool) {
+	for name, values := range record.header {
+		w.Header()[name] = values
+	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.WriteHeader(record.status)
+	w.Write(record.body)
+}
+
+// replayFor replays a recorded response to a duplicate request, or refuses
+// it with 422 when the token was first used for a different request
+func (record *idempotentResponse) replayFor(w http.ResponseWriter, fingerprint [sha256.Size]byte) {
+	if record.fingerprint != fingerprint {
+		http.Error(w, "Idempotency key was already used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	record.replay(w, true)
+}
+
+// idempotencyToken returns the request's Idempotency-Key, falling back to
+// the older X-Idempotency-Key header
+func idempotencyToken(r *http.Request) string {
+	if token := r.Header.Get("Idempotency-Key"); token != "" {
+		return token
+	}
+	return r.Header.Get("X-Idempotency-Key")
+}
+
+// requestFingerprint hashes the method, target and body of r, restoring the
+// body for the handler. At most limit bytes of the body are hashed when
+// limit is positive; the handler rejects larger bodies itself.
+func requestFingerprint(r *http.Request, limit int64) ([sha256.Size]byte, error) {
+	var fingerprint [sha256.Size]byte
+
+	body := io.Reader(r.Body)
+	if limit > 0 {
+		body = io.LimitReader(r.Body, limit+1)
+	}
+	read, err := io.ReadAll(body)
+	if err != nil {
+		return fingerprint, err
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(read), r.Body), r.Body}
+
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(read)
+	copy(fingerprint[:], hash.Sum(nil))
+	return fingerprint, nil
+}
+
+// idempotent wraps a handler so that requests carrying an idempotency key
+// are executed at most once per IdempotencyWindowSeconds. Duplicates receive
+// the original response; concurrent duplicates wait for the first request
+// to finish. Server errors are not recorded so they can be retried. A token
+// reused for a different method, target or body gets 422.
+func (dc *DistroCache) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := idempotencyToken(r)
+		if token == "" || dc.idempotency == nil {
+			next(w, r)
+			return
+		}
+		token = scopeName(r, token)
+
+		fingerprint, err := requestFingerprint(r, dc.config.MaxRequestBodyBytes)
+		if err != nil {
+			http.Error(w, "Reading the request body failed", http.StatusBadRequest)
+			return
+		}
+
+		if record, found := dc.idempotency.lookup(token); found {
+			record.replayFor(w, fingerprint)
+			return
+		}
+
+		result, _, shared := dc.idempotency.inFlight.Do(token, func() (interface{}, error) {
+			capture := &responseCapture{header: make(http.Header)}
+			next(capture, r)
+			if capture.status == 0 {
+				capture.status = http.StatusOK
+			}
+
+			record := &idempotentResponse{
+				fingerprint: fingerprint,
+				status:      capture.status,
+				header:      capture.header,
+				body:        capture.body.Bytes(),
+			}
+			if record.status < http.StatusInternalServerError {
+				dc.idempotency.store(token, record)
+			}
+			return record, nil
+		})
+
+		record := result.(*idempotentResponse)
+		if shared {
+			record.replayFor(w, fingerprint)
+		} else {
+			record.replay(w, false)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestIdempotentSetRunsOnce(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	var first []byte
+	for i := 0; i < 3; i++ {
+		body := mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/order:1",
+			map[string]interface{}{"value": 0}, "X-Idempotency-Key", "retry-1")
+		if i == 0 {
+			first = body
+		} else if !bytes.Equal(body, first) {
+			t.Errorf("retry %d response = %s, want the first response %s", i, body, first)
+		}
+	}
+
+	if got := testutil.ToFloat64(dc.stats.Sets); got != 1 {
+		t.Errorf("three requests with one idempotency key ran %v sets, want 1", got)
+	}
+	if item, _ := dc.Peek(context.Background(), "order:1"); fmt.Sprint(item.Value) != "0" {
+		t.Errorf("order:1 = %#v, want the first request's value", item.Value)
+	}
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/order:1",
+		map[string]interface{}{"value": 3}, "X-Idempotency-Key", "retry-2")
+	if got := testutil.ToFloat64(dc.stats.Sets); got != 2 {
+		t.Errorf("a new idempotency key ran %v sets in total, want 2", got)
+	}
+}
+
+func TestIdempotencyKeyReusedForADifferentRequest(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/order:1",
+		map[string]interface{}{"value": "paid"}, "Idempotency-Key", "k1")
+
+	for _, retry := range []struct {
+		method, target string
+		body           interface{}
+	}{
+		{"POST", "/api/v1/cache/order:1", map[string]interface{}{"value": "refunded"}},
+		{"POST", "/api/v1/cache/order:2", map[string]interface{}{"value": "paid"}},
+		{"PUT", "/api/v1/cache/order:1", map[string]interface{}{"value": "paid"}},
+	} {
+		mustServe(t, dc, http.StatusUnprocessableEntity, retry.method, retry.target, retry.body, "Idempotency-Key", "k1")
+	}
+
+	if got := testutil.ToFloat64(dc.stats.Sets); got != 1 {
+		t.Errorf("mismatched retries ran %v sets in total, want 1", got)
+	}
+	if _, found := dc.Peek(context.Background(), "order:2"); found {
+		t.Error("order:2 stored under a reused idempotency key")
+	}
+	if item, _ := dc.Peek(context.Background(), "order:1"); item.Value != "paid" {
+		t.Errorf("order:1 = %v, want the first request's value", item.Value)
+	}
+}
@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultPreviewSample is the number of keys returned by invalidation previews
+const defaultPreviewSample = 10
+
+// keysForTags returns the keys tagged with any (union) or all (intersection)
+// of the given tags; callers must hold the lock
+func (dc *DistroCache) keysForTags(tags []string, matchAll bool) []string {
+	if matchAll {
+		return dc.keysForAllTags(tags)
+	}
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, tag := range uniqueStrings(tags) {
+		for _, key := range dc.tagIndex[tag] {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keysForAllTags intersects the key sets of tags. It starts from the least
+// populated tag and keeps only those of its keys found in each larger tag in
+// turn, so the work is bounded by the smallest tag rather than by all the
+// keys involved, and stops as soon as nothing is left. Callers must hold the
+// lock.
+func (dc *DistroCache) keysForAllTags(tags []string) []string {
+	tags = uniqueStrings(tags)
+	if len(tags) == 0 {
+		return []string{}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return len(dc.tagIndex[tags[i]]) < len(dc.tagIndex[tags[j]])
+	})
+
+	// candidates maps each surviving key to whether the tag being scanned
+	// holds it
+	candidates := make(map[string]bool, len(dc.tagIndex[tags[0]]))
+	for _, key := range dc.tagIndex[tags[0]] {
+		candidates[key] = false
+	}
+
+	for _, tag := range tags[1:] {
+		if len(candidates) == 0 {
+			break
+		}
+		found := 0
+		for _, key := range dc.tagIndex[tag] {
+			if held, ok := candidates[key]; ok && !held {
+				candidates[key] = true
+				if found++; found == len(candidates) {
+					break
+				}
+			}
+		}
+		for key, held := range candidates {
+			if held {
+				candidates[key] = false
+			} else {
+				delete(candidates, key)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// uniqueStrings returns the distinct non-empty values of a slice in order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// PreviewInvalidation returns the keys an invalidation of the given tags
+// would delete, without deleting anything
+func (dc *DistroCache) PreviewInvalidation(tags []string, matchAll bool) []string {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	return dc.keysForTags(tags, matchAll)
+}
+
+// InvalidateByTags removes every item tagged with any (or, when matchAll is
+// set, all) of the given tags
+func (dc *DistroCache) InvalidateByTags(ctx context.Context, tags []string, matchAll bool) (int, error) {
+	if err := dc.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer dc.unlock()
+
+	return dc.invalidateKeys(ctx, dc.keysForTags(tags, matchAll)), nil
+}
+
+// InvalidateTagPrefix removes every item carrying a tag that starts with
+// prefix, e.g. "region:us-east-1:" for a whole tag hierarchy, and returns the
+// number of items deleted and tags matched
+func (dc *DistroCache) InvalidateTagPrefix(ctx context.Context, prefix string) (deleted int, tags int, err error) {
+	return dc.invalidateTagPrefix(ctx, prefix, false)
+}
+
+// invalidateTagPrefix is InvalidateTagPrefix that, with unscoped set, leaves
+// tags in a tenant's namespace alone, even for a prefix that starts with
+// the tenant marker
+func (dc *DistroCache) invalidateTagPrefix(ctx context.Context, prefix string, unscoped bool) (deleted int, tags int, err error) {
+	if err := dc.lock(ctx); err != nil {
+		return 0, 0, err
+	}
+	defer dc.unlock()
+
+	var matched []string
+	for tag := range dc.tagIndex {
+		if unscoped && inTenantNamespace(tag) {
+			continue
+		}
+		if strings.HasPrefix(tag, prefix) {
+			matched = append(matched, tag)
+		}
+	}
+	return dc.invalidateKeys(ctx, dc.keysForTags(matched, false)), len(matched), nil
+}
+
+// invalidateKeys removes the given items and returns how many existed;
+// callers must hold the write lock
+func (dc *DistroCache) invalidateKeys(ctx context.Context, keys []string) int {
+	deleted := 0
+	for _, key := range keys {
+		if item, exists := dc.data.Get(ctx, key); exists {
+			dc.removeFromTagIndex(key, item.Tags)
+			dc.removed(item, EvictReasonInvalidated)
+			dc.dropItem(ctx, key)
+			deleted++
+		}
+	}
+
+	dc.updateSizeGauges()
+	return deleted
+}
+
+// parseTagMode reads the multi-tag match mode: "any" (default) or "all"
+func parseTagMode(mode string) (matchAll bool, ok bool) {
+	switch mode {
+	case "", "any":
+		return false, true
+	case "all":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// writeInvalidationPreview responds with the count and a sample of keys
+func writeInvalidationPreview(w http.ResponseWriter, r *http.Request, keys []string) {
+	sample := defaultPreviewSample
+	if v := r.URL.Query().Get("sample"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid sample size", http.StatusBadRequest)
+			return
+		}
+		sample = n
+	}
+	if sample > len(keys) {
+		sample = len(keys)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":       len(keys),
+		"sample_keys": keys[:sample],
+	})
+}
+
+func (dc *DistroCache) handlePreviewInvalidateTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tag := vars["tag"]
+
+	writeInvalidationPreview(w, r, dc.PreviewInvalidation([]string{tag}, false))
+}
+
+func (dc *DistroCache) handlePreviewInvalidateTags(w http.ResponseWriter, r *http.Request) {
+	tags := uniqueStrings(strings.Split(r.URL.Query().Get("tags"), ","))
+	if len(tags) == 0 {
+		http.Error(w, "At least one tag is required", http.StatusBadRequest)
+		return
+	}
+
+	matchAll, ok := parseTagMode(r.URL.Query().Get("mode"))
+	if !ok {
+		http.Error(w, "Mode must be any or all", http.StatusBadRequest)
+		return
+	}
+
+	writeInvalidationPreview(w, r, dc.PreviewInvalidation(tags, matchAll))
+}
+
+func (dc *DistroCache) handleInvalidateTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req struct {
+		Tags []string `json:"tags"`
+		Mode string   `json:"mode"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	tags := uniqueStrings(req.Tags)
+	if len(tags) == 0 {
+		http.Error(w, "At least one tag is required", http.StatusBadRequest)
+		return
+	}
+
+	matchAll, ok := parseTagMode(req.Mode)
+	if !ok {
+		http.Error(w, "Mode must be any or all", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := dc.InvalidateByTags(ctx, tags, matchAll)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"deleted": deleted,
+	})
+}
+
+func (dc *DistroCache) handleInvalidateTagPrefix(w http.ResponseWriter, r *http.Request) {
+	prefix := mux.Vars(r)["prefix"]
+	if prefix == "" {
+		http.Error(w, "Prefix is required", http.StatusBadRequest)
+		return
+	}
+
+	deleted, tags, err := dc.invalidateTagPrefix(r.Context(), scopeName(r, prefix), requestTenant(r) == "")
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "success",
+		"deleted":      deleted,
+		"tags_matched": tags,
+	})
+}
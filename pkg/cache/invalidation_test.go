@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTagPrefixInvalidationSkipsTenantTags(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/t/acme/cache/secret",
+		map[string]interface{}{"value": "s3cret", "tags": []string{"users"}})
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/mine",
+		map[string]interface{}{"value": 1, "tags": []string{"acme-users"}})
+
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	body := mustServe(t, dc, http.StatusOK, "POST", "/api/v1/invalidate/tag-prefix/acme", nil)
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Deleted != 1 {
+		t.Errorf("un-scoped invalidation deleted %d items, want 1", resp.Deleted)
+	}
+	mustServe(t, dc, http.StatusOK, "GET", "/api/v1/t/acme/cache/secret", nil)
+	mustServe(t, dc, http.StatusNotFound, "GET", "/api/v1/cache/mine", nil)
+
+	body = mustServe(t, dc, http.StatusOK, "POST", "/api/v1/t/acme/invalidate/tag-prefix/us", nil)
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Deleted != 1 {
+		t.Errorf("tenant invalidation deleted %d items, want 1", resp.Deleted)
+	}
+	mustServe(t, dc, http.StatusNotFound, "GET", "/api/v1/t/acme/cache/secret", nil)
+}
+
+func TestInvalidateTagIntersection(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) { config.MaxSize = 1000 })
+	ctx := context.Background()
+
+	want := map[string]bool{}
+	for i := 0; i < 300; i++ {
+		tags := []string{fmt.Sprintf("user:%d", i%7), fmt.Sprintf("type:%d", i%3), fmt.Sprintf("lang:%d", i%2)}
+		key := fmt.Sprintf("item:%d", i)
+		if err := dc.Set(ctx, key, i, time.Minute, tags); err != nil {
+			t.Fatal(err)
+		}
+		if i%7 == 4 && i%3 == 1 {
+			want[key] = true
+		}
+	}
+
+	for _, tags := range [][]string{
+		{"user:4", "type:1"},
+		{"type:1", "user:4"},
+		{"type:1", "user:4", "type:1"},
+	} {
+		keys := dc.PreviewInvalidation(tags, true)
+		if len(keys) != len(want) {
+			t.Errorf("intersection of %v has %d keys, want %d", tags, len(keys), len(want))
+		}
+		for _, key := range keys {
+			if !want[key] {
+				t.Errorf("intersection of %v includes %s", tags, key)
+			}
+		}
+	}
+	if keys := dc.PreviewInvalidation([]string{"user:4", "missing"}, true); len(keys) != 0 {
+		t.Errorf("intersection with an unused tag = %v, want none", keys)
+	}
+	if keys := dc.PreviewInvalidation([]string{"user:4", "user:5"}, true); len(keys) != 0 {
+		t.Errorf("intersection of disjoint tags = %v, want none", keys)
+	}
+
+	deleted, err := dc.InvalidateByTags(ctx, []string{"user:4", "type:1", "lang:0"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// i ≡ 4 mod 7, 1 mod 3 and 0 mod 2 is i ≡ 4 mod 42
+	if deleted != 8 {
+		t.Errorf("InvalidateByTags deleted %d items, want 8", deleted)
+	}
+	if got := dc.Len(); got != 292 {
+		t.Errorf("%d items left, want 292", got)
+	}
+}
+
+// BenchmarkTagIntersection intersects two large, heavily overlapping tags
+// with a small one, which starting from the smallest tag keeps cheap
+func BenchmarkTagIntersection(b *testing.B) {
+	const items = 100000
+	dc := newTestCache(b, func(config *CacheConfig) {
+		config.MaxSize = items
+		config.Backend = NewInMemoryBackend()
+	})
+	ctx := context.Background()
+
+	for i := 0; i < items; i++ {
+		tags := []string{"type:profile"}
+		if i%2 == 0 {
+			tags = append(tags, "lang:en")
+		}
+		if i%1000 == 0 {
+			tags = append(tags, "user:42")
+		}
+		if err := dc.Set(ctx, fmt.Sprintf("item:%d", i), i, time.Hour, tags); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for name, tags := range map[string][]string{
+		"large":           {"type:profile", "lang:en"},
+		"large-and-small": {"type:profile", "lang:en", "user:42"},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dc.PreviewInvalidation(tags, true)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"net/http"
+	"time"
+)
+
+// itemView describes a cached item for diagnosing hot or oversized keys
+type itemView struct {
+	Key                 string    `json:"key"`
+	Version             int64     `json:"version"`
+	SizeBytes           int64     `json:"size_bytes"`
+	StoredBytes         int64     `json:"stored_bytes"`
+	Compression         string    `json:"compression,omitempty"`
+	Chunks              int       `json:"chunks,omitempty"`
+	AccessCount         int64     `json:"access_count"`
+	AccessRate          float64   `json:"access_rate"`
+	AgeSeconds          float64   `json:"age_seconds"`
+	IdleSeconds         float64   `json:"idle_seconds"`
+	TTLRemainingSeconds *float64  `json:"ttl_remaining_seconds"`
+	CreatedAt           time.Time `json:"created_at"`
+	AccessedAt          time.Time `json:"accessed_at"`
+	Tags                []string  `json:"tags,omitempty"`
+}
+
+// newItemView summarises item, with the read statistics in access, as of
+// now
+func newItemView(key string, item *CacheItem, access accessStats, now time.Time) itemView {
+	view := itemView{
+		Key:         key,
+		Version:     item.Version,
+		SizeBytes:   item.Size,
+		StoredBytes: item.Size,
+		Compression: item.Compression,
+		Chunks:      item.Chunks,
+		AccessCount: access.count,
+		AccessRate:  access.rateAt(now),
+		AgeSeconds:  now.Sub(item.CreatedAt).Seconds(),
+		IdleSeconds: now.Sub(access.accessedAt).Seconds(),
+		CreatedAt:   item.CreatedAt,
+		AccessedAt:  access.accessedAt,
+		Tags:        item.Tags,
+	}
+	if item.Compressed != nil {
+		view.StoredBytes = int64(len(item.Compressed))
+	} else if item.Chunks > 0 {
+		view.StoredBytes = item.ChunkedBytes
+	}
+	if item.TTL > 0 {
+		remaining := item.TTL.Seconds() - view.AgeSeconds
+		view.TTLRemainingSeconds = &remaining
+	}
+	return view
+}
+
+// handleAdminItem reports an item's size and access statistics without
+// counting as a read
+func (dc *DistroCache) handleAdminItem(w http.ResponseWriter, r *http.Request) {
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	item, found := dc.peekStored(r.Context(), key)
+	if !found {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newItemView(key, item, dc.access.of(key, item), dc.now()))
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Keys containing a slash cannot be addressed as /cache/{key}. They are
+// accepted in three other forms, which all resolve to the same item:
+//
+//	/cache-b64/{encoded}   base64url-encoded key in the path
+//	/cache?key={key}       URL-encoded key in the query string
+//	POST /cache            "key" field in the JSON body of a set
+
+// errTenantNamespace rejects un-scoped names that could reach tenant data
+var errTenantNamespace = errors.New("keys and tags must not start with a NUL byte, which is reserved for tenant namespaces")
+
+// checkKeyNamespace keeps un-scoped keys and tags out of the tenant
+// namespace. Tenant-scoped names are prefixed by the tenant, so any name is
+// safe there.
+func checkKeyNamespace(r *http.Request, name string) error {
+	if requestTenant(r) == "" && inTenantNamespace(name) {
+		return errTenantNamespace
+	}
+	return nil
+}
+
+// decodeKey decodes a base64url key, with or without padding
+func decodeKey(encoded string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return "", errors.New("key is not valid base64url")
+	}
+	return string(decoded), nil
+}
+
+// withKey passes a request on with the "key" route variable set to key, so
+// handlers read it through requestKey as for /cache/{key}
+func withKey(w http.ResponseWriter, r *http.Request, key string, next http.HandlerFunc) {
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	if err := checkKeyNamespace(r, key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	merged := make(map[string]string, len(vars)+1)
+	for name, value := range vars {
+		merged[name] = value
+	}
+	merged["key"] = key
+	next(w, mux.SetURLVars(r, merged))
+}
+
+// encodedKey serves /cache-b64/{encoded} with the decoded key
+func (dc *DistroCache) encodedKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := decodeKey(mux.Vars(r)["encoded"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		withKey(w, r, key, next)
+	}
+}
+
+// queryOrBodyKey serves /cache with the key from the "key" query parameter
+// or, for sets, the "key" field of the JSON body
+func (dc *DistroCache) queryOrBodyKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.URL.Query().Get("key"); key != "" || (r.Method != "POST" && r.Method != "PUT") {
+			withKey(w, r, key, next)
+			return
+		}
+
+		// The body is buffered so the set handler can decode it again
+		if dc.config.MaxRequestBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, dc.config.MaxRequestBodyBytes)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		withKey(w, r, req.Key, next)
+	}
+}
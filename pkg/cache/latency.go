@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// Latencies are bucketed log-linearly: values below 2^(latencySubBits+1)
+// nanoseconds get a bucket each, and every larger power of two is split into
+// 2^latencySubBits buckets. A bucket spans at most 1/64 of its lower bound,
+// so reporting its midpoint is within 0.8% of any value in it.
+const (
+	latencySubBits = 6
+	latencySub     = 1 << latencySubBits
+	// latencyMaxExp caps recorded latencies at 2^latencyMaxExp ns (~18 minutes)
+	latencyMaxExp  = 40
+	latencyBuckets = 2*latencySub + (latencyMaxExp-latencySubBits-1)*latencySub
+)
+
+// latencyBucket returns the bucket index of a latency in nanoseconds
+func latencyBucket(ns uint64) int {
+	if ns < 2*latencySub {
+		return int(ns)
+	}
+	if ns >= 1<<latencyMaxExp {
+		return latencyBuckets - 1
+	}
+	shift := bits.Len64(ns) - latencySubBits - 1
+	return 2*latencySub + (shift-1)*latencySub + int(ns>>shift) - latencySub
+}
+
+// latencyBucketMid returns the midpoint in nanoseconds of a bucket
+func latencyBucketMid(index int) float64 {
+	if index < 2*latencySub {
+		return float64(index)
+	}
+	shift := (index-2*latencySub)/latencySub + 1
+	top := uint64((index-2*latencySub)%latencySub + latencySub)
+	return float64(top<<shift) + float64(uint64(1)<<shift)/2
+}
+
+// latencySlot is the histogram of one rotation period
+type latencySlot struct {
+	period int64 // rotation period the counts belong to
+	total  uint64
+	counts [latencyBuckets]uint32
+}
+
+// latencyWindow estimates latency quantiles over a sliding window. The window
+// is split into slots of one rotation period each; the oldest slot is
+// cleared and reused as the window moves, so memory stays constant.
+type latencyWindow struct {
+	mutex    sync.Mutex
+	rotation time.Duration
+	slots    []latencySlot
+	now      func() time.Time
+}
+
+// newLatencyWindow creates a window of the given length that rotates every
+// rotation, defaulting to one minute in 10 second slots
+func newLatencyWindow(window, rotation time.Duration, now func() time.Time) *latencyWindow {
+	if rotation <= 0 {
+		rotation = 10 * time.Second
+	}
+	if window < rotation {
+		window = 6 * rotation
+	}
+	return &latencyWindow{
+		rotation: rotation,
+		slots:    make([]latencySlot, int(window/rotation)),
+		now:      now,
+	}
+}
+
+// period returns the index of the rotation period containing t
+func (lw *latencyWindow) period(t time.Time) int64 {
+	return t.UnixNano() / int64(lw.rotation)
+}
+
+// Observe records a latency
+func (lw *latencyWindow) Observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	period := lw.period(lw.now())
+	bucket := latencyBucket(uint64(d))
+
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	slot := &lw.slots[period%int64(len(lw.slots))]
+	if slot.period != period {
+		*slot = latencySlot{period: period}
+	}
+	slot.counts[bucket]++
+	slot.total++
+}
+
+// Quantiles returns the latency at each quantile q (0 < q <= 1) across the
+// window, or zeros when nothing was recorded
+func (lw *latencyWindow) Quantiles(qs ...float64) []time.Duration {
+	current := lw.period(lw.now())
+	oldest := current - int64(len(lw.slots)) + 1
+
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	var merged [latencyBuckets]uint64
+	var total uint64
+	for i := range lw.slots {
+		slot := &lw.slots[i]
+		if slot.total == 0 || slot.period < oldest || slot.period > current {
+			continue
+		}
+		for bucket, count := range slot.counts {
+			merged[bucket] += uint64(count)
+		}
+		total += slot.total
+	}
+
+	result := make([]time.Duration, len(qs))
+	if total == 0 {
+		return result
+	}
+	for i, q := range qs {
+		rank := uint64(math.Ceil(q * float64(total)))
+		rank = max(rank, 1)
+
+		var seen uint64
+		for bucket, count := range merged {
+			seen += count
+			if seen >= rank {
+				result[i] = time.Duration(latencyBucketMid(bucket))
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Reset discards everything recorded so far
+func (lw *latencyWindow) Reset() {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	for i := range lw.slots {
+		lw.slots[i] = latencySlot{}
+	}
+}
+
+// latencyMillis reports the P50, P95 and P99 of lw in milliseconds under
+// keys prefixed with op, e.g. "get_p99_ms"
+func latencyMillis(op string, lw *latencyWindow, into map[string]interface{}) {
+	quantiles := lw.Quantiles(0.50, 0.95, 0.99)
+	for i, name := range []string{"p50", "p95", "p99"} {
+		into[op+"_"+name+"_ms"] = float64(quantiles[i]) / float64(time.Millisecond)
+	}
+}
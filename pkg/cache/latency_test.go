@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestLatencyQuantiles(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	lw := newLatencyWindow(time.Minute, 10*time.Second, clock.Now)
+
+	// 1µs to 10ms in 1µs steps, recorded in a shuffled order across slots
+	const n = 10000
+	values := rand.New(rand.NewSource(1)).Perm(n)
+	for i, v := range values {
+		if i%(n/5) == 0 {
+			clock.Advance(10 * time.Second)
+		}
+		lw.Observe(time.Duration(v+1) * time.Microsecond)
+	}
+
+	quantiles := []float64{0.5, 0.95, 0.99}
+	for i, got := range lw.Quantiles(quantiles...) {
+		want := time.Duration(quantiles[i]*n) * time.Microsecond
+		if diff := math.Abs(float64(got-want)) / float64(want); diff > 0.01 {
+			t.Errorf("p%v = %v, want within 1%% of %v", quantiles[i]*100, got, want)
+		}
+	}
+
+	clock.Advance(time.Minute)
+	if got := lw.Quantiles(0.5)[0]; got != 0 {
+		t.Errorf("p50 after the window passed = %v, want 0", got)
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// leaderElectionKey is the semaphore nodes campaign for
+const leaderElectionKey = "distrocache:leader"
+
+// LeaderStatus is this node's view of the coordinator election
+type LeaderStatus struct {
+	Enabled bool   `json:"enabled"`
+	NodeID  string `json:"node_id"`
+	Leader  bool   `json:"leader"`
+	// Epoch is the fencing token of the election this node won; it grows
+	// with every change of leader
+	Epoch uint64 `json:"epoch,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// leaderElector campaigns for leadership every LeaderHeartbeatInterval by
+// taking the single-slot semaphore leaderElectionKey with a ttl of two
+// intervals. The leader renews it on every heartbeat; when the leader stops,
+// the semaphore expires at most two intervals after its last renewal, and
+// the next node to campaign, within one more interval, takes over.
+type leaderElector struct {
+	cache  *DistroCache
+	client *http.Client
+	status atomic.Pointer[LeaderStatus]
+}
+
+// newLeaderElector creates an elector for the cache's configured election
+func newLeaderElector(cache *DistroCache) *leaderElector {
+	e := &leaderElector{cache: cache, client: &http.Client{}}
+	e.status.Store(&LeaderStatus{Enabled: true, NodeID: cache.config.NodeID})
+	return e
+}
+
+// interval returns the heartbeat period
+func (e *leaderElector) interval() time.Duration {
+	if e.cache.config.LeaderHeartbeatInterval > 0 {
+		return e.cache.config.LeaderHeartbeatInterval
+	}
+	return 5 * time.Second
+}
+
+// run campaigns until ctx is done, then gives up leadership so another node
+// can take over without waiting for the semaphore to expire
+func (e *leaderElector) run(ctx context.Context) {
+	e.campaign(ctx)
+	ticker := time.NewTicker(e.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if e.snapshot().Leader {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), e.interval())
+				defer cancel()
+				if err := e.release(releaseCtx); err != nil {
+					slog.Warn("leadership release failed", "error", err)
+				}
+			}
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// campaign takes or renews the leader semaphore and records the outcome. A
+// leader that cannot renew steps down at once rather than risk running
+// alongside a successor.
+func (e *leaderElector) campaign(ctx context.Context) {
+	previous := e.snapshot()
+	status := LeaderStatus{Enabled: true, NodeID: previous.NodeID}
+
+	reqCtx, cancel := context.WithTimeout(ctx, e.interval())
+	token, acquired, err := e.acquire(reqCtx)
+	cancel()
+
+	switch {
+	case err != nil:
+		status.Error = err.Error()
+	case acquired:
+		status.Leader, status.Epoch = true, token
+	}
+	e.status.Store(&status)
+
+	if status.Leader && !previous.Leader {
+		slog.Info("leadership acquired", "node_id", status.NodeID, "epoch", status.Epoch)
+	} else if previous.Leader && !status.Leader {
+		slog.Warn("leadership lost", "node_id", status.NodeID, "epoch", previous.Epoch, "error", status.Error)
+	}
+}
+
+// acquire takes the leader semaphore on LeaderElectionURL, or on this cache
+// when no URL is set
+func (e *leaderElector) acquire(ctx context.Context) (uint64, bool, error) {
+	config := e.cache.config
+	ttl := 2 * e.interval()
+	if config.LeaderElectionURL == "" {
+		return e.cache.AcquireLock(ctx, leaderElectionKey, ttl, config.NodeID)
+	}
+
+	var result struct {
+		Acquired     bool   `json:"acquired"`
+		FencingToken uint64 `json:"fencing_token"`
+	}
+	err := e.post(ctx, "acquire", map[string]interface{}{
+		"slots":    1,
+		"ttl":      int(math.Ceil(ttl.Seconds())),
+		"owner_id": config.NodeID,
+	}, &result)
+	return result.FencingToken, result.Acquired, err
+}
+
+// release gives up the leader semaphore
+func (e *leaderElector) release(ctx context.Context) error {
+	config := e.cache.config
+	if config.LeaderElectionURL == "" {
+		return e.cache.ReleaseSemaphore(leaderElectionKey, config.NodeID)
+	}
+	return e.post(ctx, "release", map[string]string{"owner_id": config.NodeID}, nil)
+}
+
+// post sends body to the leader semaphore's action endpoint on the election
+// server and decodes the response into result when it is not nil
+func (e *leaderElector) post(ctx context.Context, action string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(e.cache.config.LeaderElectionURL, "/") +
+		"/api/v1/semaphores/" + url.PathEscape(leaderElectionKey) + "/" + action
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("election server returned status %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// snapshot returns the outcome of the latest campaign
+func (e *leaderElector) snapshot() LeaderStatus {
+	return *e.status.Load()
+}
+
+// LeaderStatus reports whether this node is the cluster coordinator
+func (dc *DistroCache) LeaderStatus() LeaderStatus {
+	if dc.leader == nil {
+		return LeaderStatus{NodeID: dc.config.NodeID}
+	}
+	return dc.leader.snapshot()
+}
+
+// IsLeader reports whether this node won the latest coordinator election.
+// It is always false unless LeaderElection is enabled. Tasks that must run
+// on one node only should check it each time they run, since leadership can
+// move at any heartbeat.
+func (dc *DistroCache) IsLeader() bool {
+	return dc.LeaderStatus().Leader
+}
+
+// RunOnLeader runs task every interval until the cache shuts down, but only
+// while this node is the elected coordinator, so jobs such as scheduled
+// invalidation run once per cluster. With LeaderElection off it never runs.
+func (dc *DistroCache) RunOnLeader(interval time.Duration, task func(ctx context.Context)) {
+	dc.goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if dc.IsLeader() {
+					task(ctx)
+				}
+			}
+		}
+	})
+}
+
+func (dc *DistroCache) handleLeader(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, dc.LeaderStatus())
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// leaderOf returns the index of the node that is leader, or -1 unless
+// exactly one is
+func leaderOf(nodes []*DistroCache) int {
+	leader := -1
+	for i, node := range nodes {
+		if node.IsLeader() {
+			if leader >= 0 {
+				return -1
+			}
+			leader = i
+		}
+	}
+	return leader
+}
+
+func TestLeaderFailover(t *testing.T) {
+	const interval = 500 * time.Millisecond
+
+	election := httptest.NewServer(newTestCache(t, nil).Router())
+	t.Cleanup(election.Close)
+	target, _ := url.Parse(election.URL)
+
+	// Each node reaches the election server through its own proxy, so closing
+	// a proxy kills that node as far as the others can tell
+	var nodes []*DistroCache
+	var proxies []*httptest.Server
+	runs := make([]atomic.Int64, 3)
+	for i := 0; i < 3; i++ {
+		proxy := httptest.NewServer(httputil.NewSingleHostReverseProxy(target))
+		t.Cleanup(proxy.Close)
+		proxies = append(proxies, proxy)
+
+		node := newTestCache(t, func(config *CacheConfig) {
+			config.NodeID = fmt.Sprintf("node-%d", i+1)
+			config.LeaderElection = true
+			config.LeaderElectionURL = proxy.URL
+			config.LeaderHeartbeatInterval = interval
+		})
+		node.RunOnLeader(20*time.Millisecond, func(ctx context.Context) { runs[i].Add(1) })
+		nodes = append(nodes, node)
+	}
+
+	deadline := time.Now().Add(2 * interval)
+	for leaderOf(nodes) < 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	first := leaderOf(nodes)
+	if first < 0 {
+		t.Fatal("no single leader elected")
+	}
+	epoch := nodes[first].LeaderStatus().Epoch
+
+	// Let the leader renew, which must keep its epoch and its jobs to itself
+	time.Sleep(interval + 100*time.Millisecond)
+	if got := nodes[first].LeaderStatus(); !got.Leader || got.Epoch != epoch {
+		t.Fatalf("leader after renewal = %+v, want leader with epoch %d", got, epoch)
+	}
+	for i := range nodes {
+		if i != first && runs[i].Load() != 0 {
+			t.Errorf("node-%d ran a leader-only job without being leader", i+1)
+		}
+	}
+	if runs[first].Load() == 0 {
+		t.Error("the leader never ran its leader-only job")
+	}
+
+	proxies[first].CloseClientConnections()
+	proxies[first].Close()
+	killed := time.Now()
+
+	// Within three intervals, plus a little for the HTTP round trips
+	deadline = killed.Add(3*interval + 100*time.Millisecond)
+	successor := -1
+	for time.Now().Before(deadline) {
+		for i, node := range nodes {
+			if i != first && node.IsLeader() {
+				successor = i
+			}
+		}
+		if successor >= 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if successor < 0 {
+		t.Fatalf("no node took over within 3 intervals of the leader dying")
+	}
+	t.Logf("node-%d took over %v after node-%d died", successor+1, time.Since(killed), first+1)
+
+	if got := nodes[successor].LeaderStatus().Epoch; got <= epoch {
+		t.Errorf("new leader's epoch %d, want more than %d", got, epoch)
+	}
+	if nodes[first].IsLeader() {
+		t.Error("the dead leader still believes it leads")
+	}
+}
+
+func TestRunOnLeaderWithoutElection(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	var runs atomic.Int64
+	dc.RunOnLeader(time.Millisecond, func(ctx context.Context) { runs.Add(1) })
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != 0 {
+		t.Errorf("job ran %d times with leader election off, want 0", got)
+	}
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+)
+
+// LoaderFunc loads a value for a key that missed the cache. It returns the
+// value along with the TTL and tags to store it under.
+type LoaderFunc func(ctx context.Context, key string) (interface{}, time.Duration, []string, error)
+
+// loaderEntry associates a key pattern with a loader
+type loaderEntry struct {
+	pattern string
+	fn      LoaderFunc
+}
+
+// matchKeyPattern reports whether key matches a glob pattern such as "user:*"
+func matchKeyPattern(pattern, key string) bool {
+	matched, err := path.Match(pattern, key)
+	return err == nil && matched
+}
+
+// RegisterLoader registers a read-through loader for keys matching pattern.
+// Registering a pattern again replaces its loader.
+func (dc *DistroCache) RegisterLoader(pattern string, fn LoaderFunc) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	dc.loaderMu.Lock()
+	defer dc.loaderMu.Unlock()
+
+	for i, entry := range dc.loaders {
+		if entry.pattern == pattern {
+			dc.loaders[i].fn = fn
+			return nil
+		}
+	}
+	dc.loaders = append(dc.loaders, loaderEntry{pattern: pattern, fn: fn})
+	return nil
+}
+
+// findLoader returns the first loader whose pattern matches key
+func (dc *DistroCache) findLoader(key string) (LoaderFunc, bool) {
+	dc.loaderMu.RLock()
+	defer dc.loaderMu.RUnlock()
+
+	for _, entry := range dc.loaders {
+		if matchKeyPattern(entry.pattern, key) {
+			return entry.fn, true
+		}
+	}
+	return nil, false
+}
+
+// loadThrough calls the loader for key and stores the result. Concurrent
+// misses for the same key share a single loader call, which runs with the
+// first caller's context values but is not cancelled when that caller goes away.
+func (dc *DistroCache) loadThrough(ctx context.Context, key string, fn LoaderFunc) (*CacheItem, bool) {
+	result, err, _ := dc.loadGroup.Do(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		value, ttl, tags, err := fn(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "loader failed", "key", key, "error", err)
+			return nil, err
+		}
+		if ttl == 0 {
+			ttl = dc.config.DefaultTTL
+		}
+
+		if err := dc.Set(ctx, key, value, ttl, tags); err != nil {
+			return nil, err
+		}
+
+		dc.mutex.RLock()
+		defer dc.mutex.RUnlock()
+		item, exists := dc.data.Get(ctx, key)
+		if !exists {
+			return nil, fmt.Errorf("loaded item for %q was not stored", key)
+		}
+		view, err := dc.inflateStored(item)
+		if err != nil {
+			return nil, err
+		}
+		return dc.withAccessStats(key, view), nil
+	})
+	if err != nil {
+		return nil, false
+	}
+	return result.(*CacheItem), true
+}
+
+// httpLoader returns a loader that fetches values from a remote endpoint.
+// The endpoint receives {"key": ...} and responds with
+// {"value": ..., "ttl": seconds, "tags": [...]}, or 404 if the key is unknown.
+// The ttl is checked like that of a set, except that loaded values must
+// expire.
+func (dc *DistroCache) httpLoader(url string, client *http.Client) LoaderFunc {
+	return func(ctx context.Context, key string) (interface{}, time.Duration, []string, error) {
+		body, err := json.Marshal(map[string]string{"key": key})
+		if err != nil {
+			return nil, 0, nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if info, ok := requestInfoFrom(ctx); ok {
+			req.Header.Set("X-Request-ID", info.RequestID)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, 0, nil, fmt.Errorf("loader returned status %d", resp.StatusCode)
+		}
+
+		var result struct {
+			Value interface{} `json:"value"`
+			TTL   int         `json:"ttl"`
+			Tags  []string    `json:"tags"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, 0, nil, err
+		}
+
+		if result.TTL == NoExpireTTL {
+			return nil, 0, nil, fmt.Errorf("loader asked for a value that never expires")
+		}
+		ttl, err := dc.requestTTL(result.TTL, false)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("loader returned an invalid ttl: %w", err)
+		}
+		return result.Value, ttl, result.Tags, nil
+	}
+}
+
+func (dc *DistroCache) handleRegisterLoader(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pattern string `json:"pattern"`
+		URL     string `json:"url"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Pattern == "" || req.URL == "" {
+		http.Error(w, "Pattern and url are required", http.StatusBadRequest)
+		return
+	}
+
+	loader := dc.httpLoader(req.URL, &http.Client{Timeout: 5 * time.Second})
+	if err := dc.RegisterLoader(req.Pattern, loader); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderCalledOnceForConcurrentMisses(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	err := dc.RegisterLoader("user:*", func(ctx context.Context, key string) (interface{}, time.Duration, []string, error) {
+		calls.Add(1)
+		<-release
+		return "loaded " + key, time.Minute, []string{"users"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item, found := dc.Get(ctx, "user:1")
+			if !found || item.Value != "loaded user:1" {
+				t.Errorf("Get = %v, %v; want the loaded value", item, found)
+			}
+		}()
+	}
+	// Give every caller time to miss before the loader returns
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader called %d times for concurrent misses, want 1", got)
+	}
+	if item, _ := dc.Peek(ctx, "user:1"); item == nil || len(item.Tags) != 1 || item.Tags[0] != "users" {
+		t.Errorf("stored item = %+v, want it tagged users", item)
+	}
+	if _, found := dc.Get(ctx, "order:1"); found {
+		t.Error("Get of a key no loader matches was a hit")
+	}
+}
+
+func TestRegisterLoaderOverHTTP(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key string `json:"key"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": "from origin " + req.Key, "ttl": 60})
+	}))
+	t.Cleanup(origin.Close)
+
+	mustServe(t, dc, 201, "POST", "/api/v1/admin/loaders", map[string]string{"pattern": "product:*", "url": origin.URL})
+	if code, _ := serve(t, dc, "POST", "/api/v1/admin/loaders", map[string]string{"pattern": "[", "url": origin.URL}); code != 400 {
+		t.Errorf("registering an invalid pattern: status %d, want 400", code)
+	}
+
+	item, found := dc.Get(context.Background(), "product:7")
+	if !found || item.Value != "from origin product:7" {
+		t.Errorf("Get = %v, %v; want the origin's value", item, found)
+	}
+}
+
+func TestLoadedValuesAreInflated(t *testing.T) {
+	for name, configure := range map[string]func(*CacheConfig){
+		"compressed": func(config *CacheConfig) {
+			config.CompressionAlgo = CompressionGzip
+			config.CompressionMinBytes = 1
+		},
+		"chunked": func(config *CacheConfig) {
+			config.ChunkSize = 64
+			config.Backend = NewInMemoryBackend()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dc := newTestCache(t, configure)
+			ctx := context.Background()
+
+			value := strings.Repeat("loaded ", 100)
+			err := dc.RegisterLoader("page:*", func(ctx context.Context, key string) (interface{}, time.Duration, []string, error) {
+				return value, time.Minute, nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The read-through miss and the hit after it return the same value
+			for _, read := range []string{"first", "second"} {
+				item, found := dc.Get(ctx, "page:1")
+				if !found || item.Value != value || item.Compressed != nil || item.Chunks != 0 {
+					t.Errorf("%s Get = %+v, %v; want the loaded value", read, item, found)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterLoaderRequiresAdmin(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) { config.AdminAPIKey = "admin" })
+	loader := map[string]string{"pattern": "product:*", "url": "http://127.0.0.1:1"}
+
+	mustServe(t, dc, http.StatusForbidden, "POST", "/api/v1/admin/loaders", loader)
+	mustServe(t, dc, http.StatusForbidden, "POST", "/api/v1/admin/loaders", loader, "X-API-Key", "guess")
+	if _, ok := dc.findLoader("product:1"); ok {
+		t.Error("refused loader registered anyway")
+	}
+	mustServe(t, dc, http.StatusCreated, "POST", "/api/v1/admin/loaders", loader, "X-API-Key", "admin")
+}
+
+func TestHTTPLoaderValidatesTTL(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.DefaultTTL = 5 * time.Minute
+		config.MaxTTL = time.Hour
+	})
+	ctx := context.Background()
+
+	var ttl atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": "loaded", "ttl": ttl.Load()})
+	}))
+	t.Cleanup(origin.Close)
+	mustServe(t, dc, http.StatusCreated, "POST", "/api/v1/admin/loaders", map[string]string{"pattern": "*", "url": origin.URL})
+
+	for _, tc := range []struct {
+		ttl   int64
+		found bool
+		want  time.Duration
+	}{
+		{-5, false, 0},
+		{NoExpireTTL, false, 0},
+		{7200, false, 0},
+		{0, true, 5 * time.Minute},
+		{600, true, 10 * time.Minute},
+	} {
+		key := fmt.Sprintf("ttl:%d", tc.ttl)
+		ttl.Store(tc.ttl)
+		item, found := dc.Get(ctx, key)
+		if found != tc.found || (found && item.TTL != tc.want) {
+			t.Errorf("loader ttl %d: Get = %+v, %v; want found %v with TTL %v", tc.ttl, item, found, tc.found, tc.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrOperationTimeout is returned when an operation cannot acquire the cache
+// write lock within OperationTimeout
+var ErrOperationTimeout = errors.New("timed out waiting for the cache lock")
+
+// lock acquires the write lock, giving up with ErrOperationTimeout after
+// OperationTimeout or when ctx ends first. Without an OperationTimeout it
+// blocks like mutex.Lock. The wait is recorded in
+// distrocache_lock_wait_seconds; uncontended acquisitions count as zero.
+// When ctx is fenced with WithFencingToken and the token is stale, the lock
+// is released again and ErrStaleFencingToken returned, so the check and the
+// write it guards cannot be separated by another acquisition.
+func (dc *DistroCache) lock(ctx context.Context) error {
+	if err := dc.acquireLock(ctx); err != nil {
+		return err
+	}
+	if err := dc.checkFence(ctx); err != nil {
+		dc.unlock()
+		return err
+	}
+	return nil
+}
+
+// acquireLock waits for the write lock on behalf of lock.
+//
+// A blocked Lock call cannot be abandoned, so a contended acquisition waits
+// in a helper goroutine. If the caller gives up, that goroutine releases the
+// lock as soon as it gets it; waiting writers keep their place in line and
+// still hold off new readers.
+func (dc *DistroCache) acquireLock(ctx context.Context) error {
+	if dc.mutex.TryLock() {
+		dc.stats.writeLockWait.Observe(0)
+		return nil
+	}
+
+	start := time.Now()
+	timeout := dc.config.OperationTimeout
+	if timeout <= 0 {
+		dc.mutex.Lock()
+		dc.stats.writeLockWait.Observe(time.Since(start).Seconds())
+		return nil
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		dc.mutex.Lock()
+		close(acquired)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-acquired:
+		dc.stats.writeLockWait.Observe(time.Since(start).Seconds())
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	go func() {
+		<-acquired
+		dc.mutex.Unlock()
+	}()
+	dc.stats.LockTimeouts.Inc()
+	return ErrOperationTimeout
+}
+
+// rlock acquires the read lock, recording the wait like lock. Only contended
+// acquisitions read the clock, which keeps the measurement off the hot path.
+func (dc *DistroCache) rlock() {
+	if dc.mutex.TryRLock() {
+		dc.stats.readLockWait.Observe(0)
+		return
+	}
+
+	start := time.Now()
+	dc.mutex.RLock()
+	dc.stats.readLockWait.Observe(time.Since(start).Seconds())
+}
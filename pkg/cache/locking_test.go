@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowSetBackend is an in-memory backend whose writes take delay
+type slowSetBackend struct {
+	*InMemoryBackend
+	delay time.Duration
+}
+
+func (b *slowSetBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	time.Sleep(b.delay)
+	return b.InMemoryBackend.Set(ctx, key, item)
+}
+
+func TestSlowWritesTimeOut(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.Backend = &slowSetBackend{InMemoryBackend: NewInMemoryBackend(), delay: 100 * time.Millisecond}
+		config.OperationTimeout = 50 * time.Millisecond
+	})
+	router := dc.Router()
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 10)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/cache/key:%d", i),
+				strings.NewReader(`{"value":"v"}`))
+			router.ServeHTTP(rec, req)
+			statuses <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	// Ten serialised writes would take a second
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("requests took %v, want the waiting ones to give up", elapsed)
+	}
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusOK] == 0 || counts[http.StatusServiceUnavailable] == 0 ||
+		counts[http.StatusOK]+counts[http.StatusServiceUnavailable] != 10 {
+		t.Errorf("statuses = %v, want some 200s and the rest 503", counts)
+	}
+
+	// Direct callers get the error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dc.Set(context.Background(), "holder", "v", time.Minute, nil)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := dc.Set(context.Background(), "waiter", "v", time.Minute, nil); !errors.Is(err, ErrOperationTimeout) {
+		t.Errorf("Set behind a slow write error = %v, want ErrOperationTimeout", err)
+	}
+	<-done
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// requestInfoKey is the context key for requestInfo
+type requestInfoKey struct{}
+
+// requestInfo identifies the HTTP request an operation runs on behalf of
+type requestInfo struct {
+	RequestID string
+	APIKey    string
+	TraceID   string
+}
+
+// withRequestInfo returns a context carrying info
+func withRequestInfo(ctx context.Context, info requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// requestInfoFrom returns the request info carried by ctx, if any
+func requestInfoFrom(ctx context.Context) (requestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(requestInfo)
+	return info, ok
+}
+
+// redactAPIKey keeps enough of an API key to tell keys apart in logs
+func redactAPIKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
+
+// traceIDFromHeaders returns the trace ID from a W3C traceparent header,
+// falling back to X-Trace-ID
+func traceIDFromHeaders(h http.Header) string {
+	// traceparent: version-traceid-parentid-flags
+	if parts := strings.Split(h.Get("traceparent"), "-"); len(parts) == 4 {
+		return parts[1]
+	}
+	return h.Get("X-Trace-ID")
+}
+
+// requestContextMiddleware attaches the request ID, API key and trace ID to
+// the request context so every log line written while serving it can be
+// correlated. A request ID is generated when the client sends none, and is
+// echoed in the X-Request-ID response header.
+func requestContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := requestInfo{
+			RequestID: r.Header.Get("X-Request-ID"),
+			APIKey:    r.Header.Get("X-API-Key"),
+			TraceID:   traceIDFromHeaders(r.Header),
+		}
+		if info.RequestID == "" {
+			info.RequestID, _ = newUUID()
+		}
+
+		w.Header().Set("X-Request-ID", info.RequestID)
+		next.ServeHTTP(w, r.WithContext(withRequestInfo(r.Context(), info)))
+	})
+}
+
+// contextHandler adds the request info carried by a context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info, ok := requestInfoFrom(ctx); ok {
+		if info.RequestID != "" {
+			record.AddAttrs(slog.String("request_id", info.RequestID))
+		}
+		if info.APIKey != "" {
+			record.AddAttrs(slog.String("api_key", redactAPIKey(info.APIKey)))
+		}
+		if info.TraceID != "" {
+			record.AddAttrs(slog.String("trace_id", info.TraceID))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// NewLogger creates a JSON logger at the given level ("debug", "info",
+// "warn" or "error") that includes request info from the context
+func NewLogger(level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	return slog.New(contextHandler{slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})})
+}
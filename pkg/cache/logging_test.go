@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logBuffer collects log output safely from any goroutine
+type logBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// records returns every JSON log record written so far
+func (b *logBuffer) records(t *testing.T) []map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("log line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// captureLogs sends the default logger's debug output to a buffer until the
+// test ends
+func captureLogs(t *testing.T) *logBuffer {
+	logs := &logBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logs
+}
+
+func TestGetLogCarriesRequestInfo(t *testing.T) {
+	logs := captureLogs(t)
+	dc := newTestCache(t, nil)
+	if err := dc.Set(context.Background(), "user:1", "alice", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/user:1", nil,
+		"X-Request-ID", "req-42", "X-API-Key", "secret-key", "X-Trace-ID", "trace-7")
+
+	for _, record := range logs.records(t) {
+		if record["msg"] != "cache get" {
+			continue
+		}
+		if record["key"] != "user:1" || record["request_id"] != "req-42" ||
+			record["trace_id"] != "trace-7" || record["api_key"] != "secr****" {
+			t.Errorf("cache get log = %v, want the request's ID, trace ID and redacted API key", record)
+		}
+		return
+	}
+	t.Fatal("no cache get log line written")
+}
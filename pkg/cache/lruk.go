@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// lruKPolicy is LRU-K: it keeps the times of the last K references to each
+// key and evicts the key whose Kth most recent reference is oldest. Keys
+// referenced fewer than K times have no Kth reference and go first, least
+// recently used first, so a scan that touches each key once cannot push out
+// keys read repeatedly. Times come from a counter bumped on every reference
+// rather than the clock, so ordering is exact however close the references
+// are. Keys are kept in a heap in eviction order, so choosing a victim does
+// not walk every key.
+type lruKPolicy struct {
+	mutex   sync.Mutex
+	k       int
+	tick    uint64
+	entries map[string]*lruKEntry
+	queue   lruKQueue
+}
+
+// lruKEntry is a key's reference history and its place in the queue
+type lruKEntry struct {
+	key   string
+	refs  []uint64 // most recent reference first, at most k long
+	full  bool     // whether refs holds k references
+	rank  uint64   // the Kth reference when full, else the most recent
+	index int
+}
+
+// lruKQueue is a heap of entries, the next victim first: keys short of K
+// references before those with K, then by rank, oldest first
+type lruKQueue []*lruKEntry
+
+func (q lruKQueue) Len() int { return len(q) }
+
+func (q lruKQueue) Less(i, j int) bool {
+	if q[i].full != q[j].full {
+		return !q[i].full
+	}
+	return q[i].rank < q[j].rank
+}
+
+func (q lruKQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *lruKQueue) Push(x any) {
+	entry := x.(*lruKEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *lruKQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// newLRUKPolicy creates an LRU-K policy keeping k references per key; k of
+// zero or less means LRU-2
+func newLRUKPolicy(k int) *lruKPolicy {
+	if k <= 0 {
+		k = 2
+	}
+	return &lruKPolicy{k: k, entries: make(map[string]*lruKEntry)}
+}
+
+// Insert records a write as a reference
+func (p *lruKPolicy) Insert(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.reference(key)
+}
+
+// Access records a hit as a reference
+func (p *lruKPolicy) Access(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, exists := p.entries[key]; exists {
+		p.reference(key)
+	}
+}
+
+// Remove forgets a key and its history
+func (p *lruKPolicy) Remove(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if entry, exists := p.entries[key]; exists {
+		heap.Remove(&p.queue, entry.index)
+		delete(p.entries, key)
+	}
+}
+
+// Victim returns the key with the oldest Kth most recent reference, taking
+// keys with fewer than K references first, by their most recent one. Only
+// the skipped keys ahead of the victim are examined.
+func (p *lruKPolicy) Victim(skip func(key string) bool) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var passed []*lruKEntry
+	defer func() {
+		for _, entry := range passed {
+			heap.Push(&p.queue, entry)
+		}
+	}()
+
+	for p.queue.Len() > 0 {
+		if key := p.queue[0].key; skip == nil || !skip(key) {
+			return key, true
+		}
+		passed = append(passed, heap.Pop(&p.queue).(*lruKEntry))
+	}
+	return "", false
+}
+
+// reference prepends the next tick to key's history and moves it to its
+// new place in the queue; callers must hold the lock
+func (p *lruKPolicy) reference(key string) {
+	p.tick++
+	entry, exists := p.entries[key]
+	if !exists {
+		entry = &lruKEntry{key: key}
+		p.entries[key] = entry
+	}
+
+	if len(entry.refs) < p.k {
+		entry.refs = append(entry.refs, 0)
+	}
+	copy(entry.refs[1:], entry.refs)
+	entry.refs[0] = p.tick
+	entry.full = len(entry.refs) == p.k
+	entry.rank = entry.refs[0]
+	if entry.full {
+		entry.rank = entry.refs[p.k-1]
+	}
+
+	if exists {
+		heap.Fix(&p.queue, entry.index)
+	} else {
+		heap.Push(&p.queue, entry)
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLRUKKeepsHotKeysThroughAScan(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxSize = 100
+		config.EvictionPolicy = EvictionLRUK
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("hot:%d", i)
+		if err := dc.Set(ctx, key, i, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+		dc.Get(ctx, key)
+	}
+	for i := 0; i < 500; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("scan:%d", i), i, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, found := dc.Peek(ctx, fmt.Sprintf("hot:%d", i)); !found {
+			t.Errorf("hot:%d evicted by a scan", i)
+		}
+	}
+	if _, found := dc.Peek(ctx, "scan:499"); !found {
+		t.Error("the latest scan key was evicted")
+	}
+}
+
+func TestLRUKVictimSkips(t *testing.T) {
+	p := newLRUKPolicy(2)
+	for _, key := range []string{"a", "b", "c"} {
+		p.Insert(key)
+	}
+	p.Access("a")
+
+	if key, _ := p.Victim(nil); key != "b" {
+		t.Errorf("victim = %q, want b", key)
+	}
+	if key, _ := p.Victim(func(key string) bool { return key != "a" }); key != "a" {
+		t.Errorf("victim skipping b and c = %q, want a", key)
+	}
+	// Skipped keys keep their place
+	if key, _ := p.Victim(nil); key != "b" {
+		t.Errorf("victim after a skipping call = %q, want b", key)
+	}
+	p.Remove("b")
+	if key, _ := p.Victim(nil); key != "c" {
+		t.Errorf("victim after removing b = %q, want c", key)
+	}
+	if _, found := p.Victim(func(string) bool { return true }); found {
+		t.Error("victim found with every key skipped")
+	}
+}
+
+// BenchmarkEvictionScan writes a stream of keys read once into a full cache
+// while reading a small hot set, and reports how often the hot reads hit
+func BenchmarkEvictionScan(b *testing.B) {
+	const size, hot = 10000, 1000
+
+	for _, policy := range []string{EvictionLRU, EvictionLRUK} {
+		b.Run(policy, func(b *testing.B) {
+			dc := newTestCache(b, func(config *CacheConfig) {
+				config.MaxSize = size
+				config.EvictionPolicy = policy
+			})
+			ctx := context.Background()
+
+			for i := 0; i < size; i++ {
+				key := fmt.Sprintf("hot:%d", i%hot)
+				if i >= hot {
+					key = fmt.Sprintf("fill:%d", i)
+				}
+				dc.Set(ctx, key, i, time.Hour, nil)
+			}
+			for i := 0; i < hot; i++ {
+				dc.Get(ctx, fmt.Sprintf("hot:%d", i))
+			}
+
+			hits := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				dc.Set(ctx, fmt.Sprintf("scan:%d", i), i, time.Hour, nil)
+				if i%10 == 0 {
+					if _, found := dc.Get(ctx, fmt.Sprintf("hot:%d", (i/10)%hot)); found {
+						hits++
+					}
+				}
+			}
+			b.ReportMetric(float64(hits)/float64(b.N/10+1), "hot-hit-ratio")
+		})
+	}
+}
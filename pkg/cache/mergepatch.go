@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrNotObject is returned when a merge patch targets a value that is not a
+// JSON object
+var ErrNotObject = errors.New("stored value is not a JSON object")
+
+// applyMergePatch applies a JSON Merge Patch (RFC 7396) to target and returns
+// the result. Null members of patch remove keys; nested objects merge.
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{}, len(patchObject))
+	}
+	for k, v := range patchObject {
+		if v == nil {
+			delete(targetObject, k)
+		} else {
+			targetObject[k] = applyMergePatch(targetObject[k], v)
+		}
+	}
+	return targetObject
+}
+
+// toJSONObject returns a deep copy of value as a decoded JSON object, so it
+// can be patched without touching a value readers may still hold
+func toJSONObject(value interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, ErrNotObject
+	}
+	return object, nil
+}
+
+// MergePatch atomically applies a JSON Merge Patch (RFC 7396) to the object
+// stored at key, bumping its version, and returns the merged value and its
+// version. The TTL, tags and metadata are kept. It fails with ErrNotFound for
+// a missing or expired key and ErrNotObject when the value is not an object.
+func (dc *DistroCache) MergePatch(ctx context.Context, key string, patch map[string]interface{}) (map[string]interface{}, int64, error) {
+	if err := dc.validateKey(key); err != nil {
+		return nil, 0, err
+	}
+
+	if err := dc.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer dc.unlock()
+
+	if err := dc.authorizeLocked(ctx, key); err != nil {
+		return nil, 0, err
+	}
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || dc.isExpired(item) {
+		return nil, 0, ErrNotFound
+	}
+
+	value, err := dc.storedValue(item)
+	if err != nil {
+		return nil, 0, err
+	}
+	object, err := toJSONObject(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	merged := applyMergePatch(object, patch).(map[string]interface{})
+
+	compressed, err := dc.compressValue(merged)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := dc.writeThrough(ctx, key, merged); err != nil {
+		return nil, 0, err
+	}
+
+	// Store a copy so readers holding the old item never see it change
+	updated := *item
+	updated.Value = merged
+	updated.Compression = ""
+	updated.Compressed = nil
+	if compressed != nil {
+		updated.Value = nil
+		updated.Compression = dc.codec.Name()
+		updated.Compressed = compressed
+	}
+	updated.Size = estimateSize(merged)
+	updated.Version = dc.nextVersion(ctx, key)
+	updated.Chunks, updated.ChunkedBytes = 0, 0
+	dc.dropChunks(key)
+	if err := dc.chunkItem(key, &updated); err != nil {
+		return nil, 0, err
+	}
+	if err := dc.data.Set(ctx, key, &updated); err != nil {
+		return nil, 0, err
+	}
+
+	if dc.writeBehind != nil {
+		dc.writeBehind.MarkDirty(key, merged)
+	}
+	dc.updateSizeGauges()
+	return merged, updated.Version, nil
+}
+
+func (dc *DistroCache) handleMergePatch(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]interface{}
+	if dc.config.MaxRequestBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, dc.config.MaxRequestBodyBytes)
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil || patch == nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Merge patch must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	key := requestKey(r)
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	merged, version, err := dc.MergePatch(r.Context(), key, patch)
+	if errors.Is(err, ErrNotObject) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key":     mux.Vars(r)["key"],
+		"value":   merged,
+		"version": version,
+	})
+}
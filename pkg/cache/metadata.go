@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+)
+
+// copyMetadata returns a copy of m, never nil
+func copyMetadata(m map[string]interface{}) map[string]interface{} {
+	dup := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		dup[k] = v
+	}
+	return dup
+}
+
+// Metadata returns a copy of an item's metadata
+func (dc *DistroCache) Metadata(ctx context.Context, key string) (map[string]interface{}, bool) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || dc.isExpired(item) {
+		return nil, false
+	}
+	return copyMetadata(item.Metadata), true
+}
+
+// UpdateMetadata merges patch into an item's metadata and returns the result.
+// Fields set to nil are removed. The value, TTL and version are untouched.
+func (dc *DistroCache) UpdateMetadata(ctx context.Context, key string, patch map[string]interface{}) (map[string]interface{}, error) {
+	if err := dc.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer dc.unlock()
+
+	if err := dc.authorizeLocked(ctx, key); err != nil {
+		return nil, err
+	}
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || dc.isExpired(item) {
+		return nil, ErrNotFound
+	}
+
+	// Replace rather than mutate the map so readers encoding the item
+	// outside the lock never see a concurrent write
+	merged := mergeMetadata(item.Metadata, patch)
+	item.Metadata = merged
+	if err := dc.data.Set(ctx, key, item); err != nil {
+		return nil, err
+	}
+
+	return copyMetadata(merged), nil
+}
+
+// mergeMetadata returns a copy of m with patch applied; fields set to nil
+// are removed
+func mergeMetadata(m, patch map[string]interface{}) map[string]interface{} {
+	merged := copyMetadata(m)
+	for k, v := range patch {
+		if v == nil {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// EntryUpdate re-tags an item and merges into its metadata
+type EntryUpdate struct {
+	AddTags    []string               `json:"add_tags"`
+	RemoveTags []string               `json:"remove_tags"`
+	Metadata   map[string]interface{} `json:"metadata"`
+}
+
+// UpdateEntry applies update to an item and returns its new tags and
+// metadata. The value, TTL, creation time and version are untouched, so the
+// item keeps its remaining lifetime. A tag both added and removed is removed.
+func (dc *DistroCache) UpdateEntry(ctx context.Context, key string, update EntryUpdate) ([]string, map[string]interface{}, error) {
+	if err := dc.lock(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer dc.unlock()
+
+	if err := dc.authorizeLocked(ctx, key); err != nil {
+		return nil, nil, err
+	}
+
+	item, exists := dc.data.Get(ctx, key)
+	if !exists || dc.isExpired(item) {
+		return nil, nil, ErrNotFound
+	}
+
+	remove := make(map[string]bool, len(update.RemoveTags))
+	for _, tag := range update.RemoveTags {
+		remove[tag] = true
+	}
+
+	tags := make([]string, 0, len(item.Tags)+len(update.AddTags))
+	var dropped []string
+	for _, tag := range item.Tags {
+		if remove[tag] {
+			dropped = append(dropped, tag)
+		} else {
+			tags = append(tags, tag)
+		}
+	}
+	var added []string
+	for _, tag := range uniqueStrings(update.AddTags) {
+		if !remove[tag] && !slices.Contains(tags, tag) {
+			added = append(added, tag)
+			tags = append(tags, tag)
+		}
+	}
+
+	// Only adding tags can break the limit; an item already over a lowered
+	// limit may still shed tags
+	if len(added) > 0 {
+		if err := dc.validateTags(tags); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Replace rather than mutate the tags and metadata, as UpdateMetadata does
+	item.Tags = tags
+	item.Metadata = mergeMetadata(item.Metadata, update.Metadata)
+	if err := dc.data.Set(ctx, key, item); err != nil {
+		return nil, nil, err
+	}
+	dc.removeFromTagIndex(key, dropped)
+	dc.addToTagIndex(key, added)
+
+	return slices.Clone(tags), copyMetadata(item.Metadata), nil
+}
+
+func (dc *DistroCache) handleGetMetadata(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	if err := dc.authorizeKey(ctx, key, false); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	metadata, found := dc.Metadata(ctx, key)
+	if !found {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+func (dc *DistroCache) handlePatchMetadata(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	var patch map[string]interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	metadata, err := dc.UpdateMetadata(ctx, key, patch)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+func (dc *DistroCache) handleUpdateEntry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := requestKey(r)
+
+	if writeKeyError(w, dc.validateKey(key)) {
+		return
+	}
+
+	var update EntryUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	for _, tag := range append(update.AddTags, update.RemoveTags...) {
+		if err := checkKeyNamespace(r, tag); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	update.AddTags = scopeTags(r, update.AddTags)
+	update.RemoveTags = scopeTags(r, update.RemoveTags)
+
+	tags, metadata, err := dc.UpdateEntry(ctx, key, update)
+	if writeKeyError(w, err) {
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key":      mux.Vars(r)["key"],
+		"tags":     presentKeys(r, tags),
+		"metadata": metadata,
+	})
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUpdateEntryTags(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	dc := newTestCache(t, func(config *CacheConfig) { config.Clock = clock })
+	ctx := context.Background()
+
+	if err := dc.Set(ctx, "doc", "body", time.Hour, []string{"v1", "docs"}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := dc.Peek(ctx, "doc")
+	clock.Advance(time.Minute)
+
+	mustServe(t, dc, http.StatusOK, "PATCH", "/api/v1/cache/doc/metadata", map[string]interface{}{
+		"add_tags":    []string{"deprecated"},
+		"remove_tags": []string{"v1"},
+		"metadata":    map[string]interface{}{"reason": "migration"},
+	})
+
+	item, found := dc.Get(ctx, "doc")
+	if !found {
+		t.Fatal("doc missing after a metadata update")
+	}
+	tags := slices.Sorted(slices.Values(item.Tags))
+	if !slices.Equal(tags, []string{"deprecated", "docs"}) {
+		t.Errorf("tags = %v, want deprecated and docs", tags)
+	}
+	if item.Metadata["reason"] != "migration" {
+		t.Errorf("metadata = %v, want reason migration", item.Metadata)
+	}
+	if item.Value != "body" || item.TTL != before.TTL || !item.CreatedAt.Equal(before.CreatedAt) || item.Version != before.Version {
+		t.Errorf("update changed the entry itself: %+v, was %+v", item, before)
+	}
+
+	// The tag index follows the change
+	if removed, _ := dc.InvalidateByTag(ctx, "v1"); removed != 0 {
+		t.Errorf("invalidating the removed tag removed %d items, want 0", removed)
+	}
+	if removed, _ := dc.InvalidateByTag(ctx, "deprecated"); removed != 1 {
+		t.Errorf("invalidating the added tag removed %d items, want 1", removed)
+	}
+
+	mustServe(t, dc, http.StatusNotFound, "PATCH", "/api/v1/cache/missing/metadata",
+		map[string]interface{}{"add_tags": []string{"x"}})
+}
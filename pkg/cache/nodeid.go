@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+)
+
+// Node ID strategies
+const (
+	NodeIDStatic   = "static"
+	NodeIDHostname = "hostname"
+	NodeIDUUID     = "uuid"
+)
+
+// resolveNodeID returns the node ID selected by config.NodeIDStrategy.
+// "static" (the default) uses config.NodeID as given, "hostname" uses the
+// machine hostname so the ID is stable across restarts, and "uuid" generates
+// a random UUID v4 at startup.
+func resolveNodeID(config *CacheConfig) (string, error) {
+	switch config.NodeIDStrategy {
+	case "", NodeIDStatic:
+		return config.NodeID, nil
+	case NodeIDHostname:
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", err
+		}
+		if hostname == "" {
+			return "", fmt.Errorf("hostname is empty")
+		}
+		return hostname, nil
+	case NodeIDUUID:
+		return newUUID()
+	default:
+		return "", fmt.Errorf("unknown node id strategy %q", config.NodeIDStrategy)
+	}
+}
+
+// newUUID generates a random RFC 4122 version 4 UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"testing"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// healthNodeID returns the node ID a cache reports on its health endpoint
+func healthNodeID(t *testing.T, dc *DistroCache) string {
+	t.Helper()
+
+	var health struct {
+		NodeID string `json:"node_id"`
+	}
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/health", nil), &health); err != nil {
+		t.Fatal(err)
+	}
+	return health.NodeID
+}
+
+func TestNodeIDStrategies(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no hostname: %v", err)
+	}
+
+	dc := newTestCache(t, func(config *CacheConfig) { config.NodeIDStrategy = NodeIDHostname })
+	if got := healthNodeID(t, dc); got == "" || got != hostname {
+		t.Errorf("hostname strategy node ID = %q, want %q", got, hostname)
+	}
+
+	first := healthNodeID(t, newTestCache(t, func(config *CacheConfig) { config.NodeIDStrategy = NodeIDUUID }))
+	second := healthNodeID(t, newTestCache(t, func(config *CacheConfig) { config.NodeIDStrategy = NodeIDUUID }))
+	if !uuidV4.MatchString(first) {
+		t.Errorf("uuid strategy node ID = %q, want a UUID v4", first)
+	}
+	if first == second {
+		t.Errorf("two caches got the same UUID %q", first)
+	}
+
+	dc = newTestCache(t, func(config *CacheConfig) { config.NodeID = "node-7" })
+	if got := healthNodeID(t, dc); got != "node-7" {
+		t.Errorf("static strategy node ID = %q, want node-7", got)
+	}
+}
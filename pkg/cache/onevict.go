@@ -0,0 +1,65 @@
+package cache
+
+// EvictReason says why an item left the cache
+type EvictReason string
+
+// Reasons passed to OnEvict
+const (
+	// EvictReasonCapacity: evicted to make room for a new item
+	EvictReasonCapacity EvictReason = "capacity"
+	// EvictReasonExpired: removed after its TTL passed
+	EvictReasonExpired EvictReason = "expired"
+	// EvictReasonDeleted: removed by Delete or a prefix flush
+	EvictReasonDeleted EvictReason = "deleted"
+	// EvictReasonInvalidated: removed by a tag invalidation
+	EvictReasonInvalidated EvictReason = "invalidated"
+)
+
+// EvictFunc is called with each item removed from the cache. Items replaced
+// by a newer Set are not reported.
+type EvictFunc func(item *CacheItem, reason EvictReason)
+
+// removal is an item waiting to be reported to OnEvict
+type removal struct {
+	item   *CacheItem
+	reason EvictReason
+}
+
+// SetOnEvict replaces the OnEvict callback; nil disables it
+func (dc *DistroCache) SetOnEvict(fn EvictFunc) {
+	dc.mutex.Lock()
+	defer dc.unlock()
+
+	dc.onEvict = fn
+}
+
+// removed queues item for the OnEvict callback, which runs once the write
+// lock is released; callers must hold the write lock
+func (dc *DistroCache) removed(item *CacheItem, reason EvictReason) {
+	if dc.onEvict == nil {
+		return
+	}
+	// Chunks are gone once the item is dropped, so join them now
+	if assembled, err := dc.assemble(item); err == nil {
+		item = assembled
+	}
+	dc.removals = append(dc.removals, removal{item: item, reason: reason})
+}
+
+// unlock releases the write lock, then reports the items removed while it
+// was held. Running OnEvict outside the lock lets the callback call back into
+// the cache without deadlocking.
+func (dc *DistroCache) unlock() {
+	removals := dc.removals
+	onEvict := dc.onEvict
+	dc.removals = nil
+	dc.mutex.Unlock()
+
+	for _, r := range removals {
+		item := r.item
+		if view, err := inflate(item); err == nil {
+			item = view
+		}
+		onEvict(item, r.reason)
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// valueFormat is how GET returns an item: the full item (the default), only
+// its JSON value, or its value decoded from base64 as bytes
+type valueFormat int
+
+const (
+	formatItem valueFormat = iota
+	formatRaw
+	formatBinary
+)
+
+// requestValueFormat picks the response format of a GET from ?raw=true or an
+// Accept header listing application/octet-stream
+func requestValueFormat(r *http.Request) valueFormat {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == "application/octet-stream" {
+			return formatBinary
+		}
+	}
+	if r.URL.Query().Get("raw") == "true" {
+		return formatRaw
+	}
+	return formatItem
+}
+
+// writeValue writes only the value of item. Binary responses need a string
+// value holding standard base64; anything else is answered with 406.
+func writeValue(w http.ResponseWriter, item *CacheItem, format valueFormat) {
+	if format == formatBinary {
+		encoded, ok := item.Value.(string)
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if !ok || err != nil {
+			http.Error(w, "Value is not base64-encoded binary", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item.Value)
+}
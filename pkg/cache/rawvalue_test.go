@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRawValueResponses(t *testing.T) {
+	dc := newTestCache(t, nil)
+	value := map[string]interface{}{"name": "alice", "roles": []interface{}{"admin", "dev"}}
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/user", map[string]interface{}{"value": value})
+
+	var raw interface{}
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/user?raw=true", nil), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(raw, value) {
+		t.Errorf("raw response = %v, want the stored value %v", raw, value)
+	}
+
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/user", nil), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope["key"] != "user" || !reflect.DeepEqual(envelope["value"], value) {
+		t.Errorf("default response = %v, want the full item", envelope)
+	}
+
+	binary := []byte{0x00, 0xff, 0x10, 'x'}
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/blob",
+		map[string]interface{}{"value": base64.StdEncoding.EncodeToString(binary)})
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/blob", nil, "Accept", "application/octet-stream")
+	if !bytes.Equal(body, binary) {
+		t.Errorf("binary response = %v, want %v", body, binary)
+	}
+	mustServe(t, dc, http.StatusNotAcceptable, "GET", "/api/v1/cache/user", nil, "Accept", "application/octet-stream")
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Field naming styles for CacheItem responses
+const (
+	ResponseFieldsSnakeCase = "snake_case"
+	ResponseFieldsCamelCase = "camelCase"
+)
+
+// camelCaseItem mirrors CacheItem field for field so an item converts to it
+// directly; only the JSON names differ. A field added to CacheItem must be
+// added here too, or the conversion stops compiling.
+type camelCaseItem struct {
+	Key             string                 `json:"key"`
+	Value           interface{}            `json:"value"`
+	Version         int64                  `json:"version"`
+	TTL             time.Duration          `json:"ttl"`
+	CreatedAt       time.Time              `json:"createdAt"`
+	AccessedAt      time.Time              `json:"accessedAt"`
+	AccessCount     int64                  `json:"accessCount"`
+	AccessRate      float64                `json:"accessRate"`
+	Size            int64                  `json:"size"`
+	Tags            []string               `json:"tags,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	OnExpireURL     string                 `json:"onExpireUrl,omitempty"`
+	ExternalETag    string                 `json:"externalEtag,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	Compression     string                 `json:"compression,omitempty"`
+	Compressed      []byte                 `json:"compressed,omitempty"`
+	Chunks          int                    `json:"chunks,omitempty"`
+	ChunkedBytes    int64                  `json:"chunkedBytes,omitempty"`
+	ACL             *KeyACL                `json:"acl,omitempty"`
+}
+
+// itemRenderer converts an item to the value encoded in responses
+type itemRenderer func(item *CacheItem) interface{}
+
+// newItemRenderer returns the renderer for a ResponseFieldStyle; an empty
+// style selects snake_case
+func newItemRenderer(style string) (itemRenderer, error) {
+	switch style {
+	case "", ResponseFieldsSnakeCase:
+		return func(item *CacheItem) interface{} { return item }, nil
+	case ResponseFieldsCamelCase:
+		return func(item *CacheItem) interface{} { return (*camelCaseItem)(item) }, nil
+	default:
+		return nil, fmt.Errorf("unknown response field style %q", style)
+	}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseFieldStyles(t *testing.T) {
+	for _, tc := range []struct {
+		style        string
+		field, other string
+	}{
+		{ResponseFieldsSnakeCase, "access_count", "accessCount"},
+		{ResponseFieldsCamelCase, "accessCount", "access_count"},
+	} {
+		t.Run(tc.style, func(t *testing.T) {
+			dc := newTestCache(t, func(config *CacheConfig) { config.ResponseFieldStyle = tc.style })
+			ctx := context.Background()
+
+			if err := dc.Set(ctx, "key", "value", time.Minute, nil); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				dc.Get(ctx, "key")
+			}
+
+			var item map[string]interface{}
+			if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/key", nil), &item); err != nil {
+				t.Fatal(err)
+			}
+			if item[tc.field] != 5.0 {
+				t.Errorf("%s = %v, want 5: %v", tc.field, item[tc.field], item)
+			}
+			if _, found := item[tc.other]; found {
+				t.Errorf("response has %s as well as %s", tc.other, tc.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrNotSemaphore is returned when the key named by a semaphore holds a
+// value other than a list of holders
+var ErrNotSemaphore = errors.New("value is not a semaphore")
+
+// ErrNotHolder is returned when releasing a semaphore the owner does not hold
+var ErrNotHolder = errors.New("owner does not hold the semaphore")
+
+// toHolders converts a stored semaphore value to its list of holders. Values
+// read back from a persistent backend arrive as []interface{}.
+func toHolders(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		holders := make([]string, 0, len(v))
+		for _, holder := range v {
+			owner, ok := holder.(string)
+			if !ok {
+				return nil, ErrNotSemaphore
+			}
+			holders = append(holders, owner)
+		}
+		return holders, nil
+	default:
+		return nil, ErrNotSemaphore
+	}
+}
+
+// semaphoreHolders returns the holders of the semaphore stored at name and
+// its item, or nil when it is not held; callers must hold the write lock
+func (dc *DistroCache) semaphoreHolders(ctx context.Context, name string) ([]string, *CacheItem, error) {
+	item, exists := dc.data.Get(ctx, name)
+	if exists && dc.isExpired(item) {
+		dc.expireItem(ctx, name, item)
+		exists = false
+	}
+	if !exists {
+		return nil, nil, nil
+	}
+
+	value, err := dc.storedValue(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	holders, err := toHolders(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Readers may still hold the stored slice, so never modify it in place
+	return slices.Clone(holders), item, nil
+}
+
+// AcquireSemaphore takes one of slots places in the semaphore called name
+// for ownerID, reporting false when all slots are taken. The holders are
+// stored as a list under the key name; reading the list, checking its length
+// and appending happen under the write lock, so concurrent callers cannot
+// both take the last slot. Every acquisition, including one by an owner
+// already holding a slot, restarts the item's ttl, so holders that stop
+// renewing are all released when it expires.
+func (dc *DistroCache) AcquireSemaphore(ctx context.Context, name string, slots int, ttl time.Duration, ownerID string) (bool, error) {
+	_, acquired, err := dc.acquireSemaphore(ctx, name, slots, ttl, ownerID)
+	return acquired, err
+}
+
+// AcquireLock takes the single-slot semaphore called name for ownerID and
+// returns a fencing token for it, to pass to WithFencingToken (or send as
+// X-Fencing-Token) with the writes the lock protects. Every new holder gets
+// a higher token than the last; a holder renewing the lock keeps its token,
+// so writes it has in flight stay valid.
+func (dc *DistroCache) AcquireLock(ctx context.Context, name string, ttl time.Duration, ownerID string) (uint64, bool, error) {
+	return dc.acquireSemaphore(ctx, name, 1, ttl, ownerID)
+}
+
+// acquireSemaphore implements AcquireSemaphore, returning the fencing token
+// issued when a slot is taken
+func (dc *DistroCache) acquireSemaphore(ctx context.Context, name string, slots int, ttl time.Duration, ownerID string) (uint64, bool, error) {
+	if err := dc.validateKey(name); err != nil {
+		return 0, false, err
+	}
+
+	if err := dc.lock(ctx); err != nil {
+		return 0, false, err
+	}
+	defer dc.unlock()
+
+	holders, item, err := dc.semaphoreHolders(ctx, name)
+	if err != nil {
+		return 0, false, err
+	}
+
+	renewing := slices.Contains(holders, ownerID)
+	if !renewing {
+		if len(holders) >= slots {
+			return 0, false, nil
+		}
+		holders = append(holders, ownerID)
+	}
+
+	if item != nil {
+		updated := dc.withHolders(ctx, name, item, holders)
+		updated.TTL = ttl
+		updated.CreatedAt = dc.now()
+		if err := dc.data.Set(ctx, name, updated); err != nil {
+			return 0, false, err
+		}
+		return dc.grantFencingToken(name, renewing), true, nil
+	}
+
+	if err := dc.storeLocked(ctx, name, holders, nil, ttl, nil, SetOptions{}); err != nil {
+		return 0, false, err
+	}
+	return dc.issueFencingToken(name), true, nil
+}
+
+// ReleaseSemaphore gives up the slot ownerID holds in the semaphore called
+// name. The semaphore's key is removed once its last holder releases it.
+func (dc *DistroCache) ReleaseSemaphore(name, ownerID string) error {
+	ctx := context.Background()
+	if err := dc.lock(ctx); err != nil {
+		return err
+	}
+	defer dc.unlock()
+
+	holders, item, err := dc.semaphoreHolders(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	pos := slices.Index(holders, ownerID)
+	if pos < 0 {
+		return ErrNotHolder
+	}
+	holders = slices.Delete(holders, pos, pos+1)
+
+	if len(holders) == 0 {
+		dc.removed(item, EvictReasonDeleted)
+		dc.dropItem(ctx, name)
+		dc.updateSizeGauges()
+		return nil
+	}
+
+	return dc.data.Set(ctx, name, dc.withHolders(ctx, name, item, holders))
+}
+
+// withHolders returns a copy of the semaphore item stored at name holding
+// holders under a new version. Readers may still be encoding the stored
+// item, so it is never modified in place. Callers must hold the write lock.
+func (dc *DistroCache) withHolders(ctx context.Context, name string, item *CacheItem, holders []string) *CacheItem {
+	updated := *item
+	updated.Value = holders
+	updated.Compression = ""
+	updated.Compressed = nil
+	updated.Chunks, updated.ChunkedBytes = 0, 0
+	updated.Size = estimateSize(holders)
+	updated.Version = dc.nextVersion(ctx, name)
+	dc.dropChunks(name)
+	return &updated
+}
+
+func (dc *DistroCache) handleAcquireSemaphore(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req struct {
+		Slots   int    `json:"slots"`
+		TTL     int    `json:"ttl"`
+		OwnerID string `json:"owner_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Slots <= 0 || req.OwnerID == "" {
+		http.Error(w, "slots and owner_id are required", http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := dc.requestTTL(req.TTL, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, acquired, err := dc.acquireSemaphore(r.Context(), name, req.Slots, ttl, req.OwnerID)
+	if writeKeyError(w, err) {
+		return
+	}
+	if errors.Is(err, ErrNotSemaphore) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"name":     name,
+		"acquired": acquired,
+	}
+	if acquired {
+		response["fencing_token"] = token
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (dc *DistroCache) handleReleaseSemaphore(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req struct {
+		OwnerID string `json:"owner_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OwnerID == "" {
+		http.Error(w, "owner_id is required", http.StatusBadRequest)
+		return
+	}
+
+	err := dc.ReleaseSemaphore(name, req.OwnerID)
+	switch {
+	case errors.Is(err, ErrNotHolder):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotSemaphore):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "released"})
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreAdmitsExactlyN(t *testing.T) {
+	const slots, callers = 5, 50
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var acquired atomic.Int64
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			ok, err := dc.AcquireSemaphore(ctx, "db", slots, time.Minute, fmt.Sprintf("worker-%d", i))
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := acquired.Load(); got != slots {
+		t.Errorf("%d of %d concurrent callers acquired a %d-slot semaphore", got, callers, slots)
+	}
+	dc.mutex.Lock()
+	holders, _, err := dc.semaphoreHolders(ctx, "db")
+	dc.mutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) != slots {
+		t.Errorf("semaphore lists %d holders, want %d", len(holders), slots)
+	}
+}
+
+// TestSemaphoreDoesNotMutateReadItems checks, under -race, that an item a
+// reader got back is never changed by later acquisitions and releases
+func TestSemaphoreDoesNotMutateReadItems(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	if _, err := dc.AcquireSemaphore(ctx, "jobs", 2, time.Minute, "anchor"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 300; i++ {
+			if _, err := dc.AcquireSemaphore(ctx, "jobs", 2, time.Minute, "worker"); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := dc.ReleaseSemaphore("jobs", "worker"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 300; i++ {
+			item, found := dc.Get(ctx, "jobs")
+			if !found {
+				t.Error("semaphore not found")
+				return
+			}
+			before, _ := json.Marshal(item)
+			after, _ := json.Marshal(item)
+			if string(before) != string(after) {
+				t.Errorf("item changed under a reader: %s then %s", before, after)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestSemaphoreRenewalKeepsSlot(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		acquired, err := dc.AcquireSemaphore(ctx, "single", 1, time.Minute, "a")
+		if err != nil || !acquired {
+			t.Fatalf("renewal %d: acquired=%v err=%v", i, acquired, err)
+		}
+	}
+	if acquired, _ := dc.AcquireSemaphore(ctx, "single", 1, time.Minute, "b"); acquired {
+		t.Error("second owner took a held single-slot semaphore")
+	}
+	if err := dc.ReleaseSemaphore("single", "b"); err != ErrNotHolder {
+		t.Errorf("release by a non-holder error = %v, want ErrNotHolder", err)
+	}
+	if err := dc.ReleaseSemaphore("single", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := dc.Peek(ctx, "single"); found {
+		t.Error("semaphore key kept after its last holder released it")
+	}
+	if acquired, _ := dc.AcquireSemaphore(ctx, "single", 1, time.Minute, "b"); !acquired {
+		t.Error("released semaphore could not be acquired")
+	}
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+)
+
+// goBackground runs fn in a goroutine tied to the cache's lifetime. fn must
+// return once ctx is cancelled; Shutdown waits for it.
+func (dc *DistroCache) goBackground(fn func(ctx context.Context)) {
+	dc.background.Add(1)
+	go func() {
+		defer dc.background.Done()
+		fn(dc.ctx)
+	}()
+}
+
+// Shutdown stops the background goroutines (cleanup, write-behind flushing,
+// expiry webhooks, hit rate alerts, GC tuning, leader election and
+// RunOnLeader jobs) and waits for them to exit. Pending write-behind entries
+// get a final flush, and a leader gives up its leadership. It returns
+// ctx.Err() if ctx ends before every goroutine has stopped.
+func (dc *DistroCache) Shutdown(ctx context.Context) error {
+	dc.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		dc.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the background goroutines like Shutdown, waiting for them
+// without a deadline, then closes the storage backend. It is safe to call
+// more than once; later calls return the first call's result. The cache must
+// not be used after Close.
+func (dc *DistroCache) Close() error {
+	dc.closeOnce.Do(func() {
+		dc.Shutdown(context.Background())
+
+		dc.mutex.Lock()
+		defer dc.unlock()
+
+		dc.persistAccessStats(context.Background())
+		dc.closeErr = dc.data.Close()
+	})
+	return dc.closeErr
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// checkGoroutinesExit fails the test unless the number of goroutines falls
+// back to baseline within a second
+func checkGoroutinesExit(t *testing.T, baseline int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		buf := make([]byte, 1<<20)
+		t.Errorf("%d goroutines left running, want at most %d:\n%s", n, baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+// newBusyCache returns a cache with every background goroutine enabled
+func newBusyCache(t *testing.T) *DistroCache {
+	return newTestCache(t, func(config *CacheConfig) {
+		config.CleanupInterval = 10 * time.Millisecond
+		config.WriteBehind = true
+		config.WriteBehindInterval = 10 * time.Millisecond
+		config.WriteBehindFn = func(string, interface{}) error { return nil }
+		config.HitRateAlertThreshold = 0.5
+		config.AlertWebhookURL = "http://127.0.0.1:1"
+		config.AlertCheckInterval = 10 * time.Millisecond
+		config.AutoGCTuning = true
+		config.MaxMemoryBytes = 1 << 30
+	})
+}
+
+func TestShutdownStopsBackgroundGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	dc := newBusyCache(t)
+	dc.RunOnLeader(10*time.Millisecond, func(context.Context) {})
+	if err := dc.Set(context.Background(), "key", "value", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := dc.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	checkGoroutinesExit(t, baseline)
+}
+
+func TestCloseStopsBackgroundGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	dc := newBusyCache(t)
+	time.Sleep(50 * time.Millisecond)
+
+	if err := dc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	checkGoroutinesExit(t, baseline)
+}
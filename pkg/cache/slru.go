@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// SLRU segments, in eviction order
+const (
+	segmentCold = iota
+	segmentWarm
+	segmentHot
+	segmentCount
+)
+
+// slruEntry is a key and the segment it currently lives in
+type slruEntry struct {
+	key     string
+	segment int
+}
+
+// slruPolicy is a segmented LRU. New keys enter the cold segment, move to
+// warm on their first hit and to hot on later hits. Warm and hot are capped
+// at 30% and 50% of capacity; overflow is demoted one segment down, so cold
+// holds the remaining 20% in steady state. Victims are taken from the least
+// recently used end of cold, then warm, then hot, so keys that are read
+// repeatedly survive a burst of one-off inserts.
+type slruPolicy struct {
+	mutex    sync.Mutex
+	segments [segmentCount]*list.List
+	limits   [segmentCount]int // cold is unbounded; it absorbs demotions
+	entries  map[string]*list.Element
+}
+
+// newSLRUPolicy creates a segmented LRU sized for capacity items
+func newSLRUPolicy(capacity int) *slruPolicy {
+	p := &slruPolicy{entries: make(map[string]*list.Element)}
+	for i := range p.segments {
+		p.segments[i] = list.New()
+	}
+
+	p.limits[segmentWarm] = max(1, capacity*30/100)
+	p.limits[segmentHot] = max(1, capacity*50/100)
+	return p
+}
+
+// Insert adds a new key to cold, or refreshes an overwritten key in place
+func (p *slruPolicy) Insert(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if elem, exists := p.entries[key]; exists {
+		p.segments[elem.Value.(*slruEntry).segment].MoveToFront(elem)
+		return
+	}
+	p.entries[key] = p.segments[segmentCold].PushFront(&slruEntry{key: key, segment: segmentCold})
+}
+
+// Access promotes a key one segment, or refreshes it within hot
+func (p *slruPolicy) Access(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	elem, exists := p.entries[key]
+	if !exists {
+		return
+	}
+
+	entry := elem.Value.(*slruEntry)
+	if entry.segment == segmentHot {
+		p.segments[segmentHot].MoveToFront(elem)
+		return
+	}
+	p.moveTo(elem, entry.segment+1)
+}
+
+// Remove forgets a key
+func (p *slruPolicy) Remove(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if elem, exists := p.entries[key]; exists {
+		p.segments[elem.Value.(*slruEntry).segment].Remove(elem)
+		delete(p.entries, key)
+	}
+}
+
+// Victim returns the least recently used key of the lowest segment holding
+// a key that is not skipped
+func (p *slruPolicy) Victim(skip func(key string) bool) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, segment := range p.segments {
+		for elem := segment.Back(); elem != nil; elem = elem.Prev() {
+			if key := elem.Value.(*slruEntry).key; skip == nil || !skip(key) {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// moveTo places elem at the front of segment and demotes any overflow;
+// callers must hold the lock
+func (p *slruPolicy) moveTo(elem *list.Element, segment int) {
+	entry := elem.Value.(*slruEntry)
+	p.segments[entry.segment].Remove(elem)
+	entry.segment = segment
+	p.entries[entry.key] = p.segments[segment].PushFront(entry)
+
+	for s := segment; s > segmentCold && p.segments[s].Len() > p.limits[s]; s-- {
+		demoted := p.segments[s].Back().Value.(*slruEntry)
+		p.segments[s].Remove(p.segments[s].Back())
+		demoted.segment = s - 1
+		p.entries[demoted.key] = p.segments[s-1].PushFront(demoted)
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSLRUKeepsFrequentlyReadItems(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxSize = 10
+		config.EvictionPolicy = EvictionSLRU
+	})
+	ctx := context.Background()
+
+	if err := dc.Set(ctx, "hot", "value", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	dc.Get(ctx, "hot")
+	dc.Get(ctx, "hot")
+
+	// A stream of new items, each read at most once, fills and churns cold
+	for i := 0; i < 100; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("cold:%d", i), i, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, found := dc.Peek(ctx, "hot"); !found {
+		t.Error("frequently read item evicted by a stream of new items")
+	}
+	if _, found := dc.Peek(ctx, "cold:0"); found {
+		t.Error("the oldest cold item survived")
+	}
+}
+
+func TestSLRUSegments(t *testing.T) {
+	p := newSLRUPolicy(10)
+	for _, key := range []string{"a", "b", "c"} {
+		p.Insert(key)
+	}
+	p.Access("a")
+	p.Access("a")
+	p.Access("b")
+
+	segment := func(key string) int { return p.entries[key].Value.(*slruEntry).segment }
+	if segment("a") != segmentHot || segment("b") != segmentWarm || segment("c") != segmentCold {
+		t.Errorf("segments a=%d b=%d c=%d, want hot, warm and cold", segment("a"), segment("b"), segment("c"))
+	}
+
+	// Victims come from cold first, then warm, then hot
+	for _, want := range []string{"c", "b", "a"} {
+		key, found := p.Victim(nil)
+		if !found || key != want {
+			t.Fatalf("victim = %q, want %q", key, want)
+		}
+		p.Remove(key)
+	}
+}
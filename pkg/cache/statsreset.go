@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+// statsEpoch holds the counter totals at the last stats reset. Prometheus
+// counters only go up, so a reset records the current totals and reported
+// stats subtract them.
+type statsEpoch struct {
+	hits        int64
+	misses      int64
+	sets        int64
+	deletes     int64
+	evictions   int64
+	bytesServed int64
+	startedAt   time.Time
+}
+
+// totals returns the counter totals since the cache started
+func (cs *CacheStats) totals() statsEpoch {
+	return statsEpoch{
+		hits:        cs.hitCount.Load(),
+		misses:      cs.missCount.Load(),
+		sets:        cs.setCount.Load(),
+		deletes:     cs.deleteCount.Load(),
+		evictions:   cs.evictionCount.Load(),
+		bytesServed: cs.bytesServed.Load(),
+		startedAt:   cs.now(),
+	}
+}
+
+// sinceEpoch returns the counters accumulated since the last reset
+func (cs *CacheStats) sinceEpoch() statsEpoch {
+	current := cs.totals()
+	epoch := cs.epoch.Load()
+	if epoch == nil {
+		current.startedAt = cs.startedAt
+		return current
+	}
+	return statsEpoch{
+		hits:        current.hits - epoch.hits,
+		misses:      current.misses - epoch.misses,
+		sets:        current.sets - epoch.sets,
+		deletes:     current.deletes - epoch.deletes,
+		evictions:   current.evictions - epoch.evictions,
+		bytesServed: current.bytesServed - epoch.bytesServed,
+		startedAt:   epoch.startedAt,
+	}
+}
+
+// Counters are the operation counts of one cache since its last stats
+// reset. Unlike the Prometheus metrics they are never shared between caches,
+// so a test can assert what a single operation did.
+type Counters struct {
+	Hits        int64
+	Misses      int64
+	Sets        int64
+	Deletes     int64
+	Evictions   int64
+	BytesServed int64
+	// Since is when counting started: the last reset or cache creation
+	Since time.Time
+}
+
+// counters converts an epoch's counts to Counters
+func (e statsEpoch) counters() Counters {
+	return Counters{
+		Hits:        e.hits,
+		Misses:      e.misses,
+		Sets:        e.sets,
+		Deletes:     e.deletes,
+		Evictions:   e.evictions,
+		BytesServed: e.bytesServed,
+		Since:       e.startedAt,
+	}
+}
+
+// Counters returns the operation counts since the last stats reset
+func (dc *DistroCache) Counters() Counters {
+	return dc.stats.sinceEpoch().counters()
+}
+
+// ResetStats starts a new stats epoch: hits, misses, sets, deletes,
+// evictions and bytes served reported by GetStats count from zero again, as
+// do the latency percentiles and the eviction rate. Cached items are kept.
+// Prometheus counters keep their totals. Cache operations are blocked while
+// the epoch is taken, so no operation is split across it.
+func (dc *DistroCache) ResetStats(ctx context.Context) error {
+	_, err := dc.TakeCounters(ctx)
+	return err
+}
+
+// TakeCounters returns the operation counts since the last stats reset and
+// resets them, like ResetStats, in one step so no operation is lost between
+// the read and the reset
+func (dc *DistroCache) TakeCounters(ctx context.Context) (Counters, error) {
+	if err := dc.lock(ctx); err != nil {
+		return Counters{}, err
+	}
+	defer dc.unlock()
+
+	counters := dc.stats.sinceEpoch().counters()
+	epoch := dc.stats.totals()
+	dc.stats.epoch.Store(&epoch)
+	dc.tagAccess.reset()
+	dc.stats.getLatency.Reset()
+	dc.stats.setLatency.Reset()
+	dc.stats.evictions.Reset()
+	return counters, nil
+}
+
+// isAdmin reports whether r may use the admin endpoints guarded by
+// AdminAPIKey: it sends the key, or none is configured
+func (dc *DistroCache) isAdmin(r *http.Request) bool {
+	adminKey := dc.config.AdminAPIKey
+	return adminKey == "" ||
+		subtle.ConstantTimeCompare([]byte(r.Header.Get("X-API-Key")), []byte(adminKey)) == 1
+}
+
+// adminOnly refuses requests to the admin routes without AdminAPIKey, when
+// one is configured
+func (dc *DistroCache) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !dc.isAdmin(r) {
+			http.Error(w, "The admin API key is required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleResetStats serves /stats/reset, which resets the stats for a
+// benchmark run. The caller must confirm with ?confirm=true, so the stats
+// are not wiped by a stray request.
+func (dc *DistroCache) handleResetStats(w http.ResponseWriter, r *http.Request) {
+	if dc.isAdmin(r) && r.URL.Query().Get("confirm") != "true" {
+		http.Error(w, "Add ?confirm=true to reset the stats", http.StatusBadRequest)
+		return
+	}
+	dc.handleAdminResetStats(w, r)
+}
+
+// handleAdminResetStats serves /admin/stats/reset, which resets the stats
+// without asking for confirmation. Both routes require AdminAPIKey when one
+// is configured.
+func (dc *DistroCache) handleAdminResetStats(w http.ResponseWriter, r *http.Request) {
+	if !dc.isAdmin(r) {
+		http.Error(w, "Resetting the stats requires the admin API key", http.StatusForbidden)
+		return
+	}
+
+	if err := dc.ResetStats(r.Context()); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
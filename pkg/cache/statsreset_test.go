@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResetStatsRoutes(t *testing.T) {
+	dc := newTestCache(t, nil)
+
+	mustServe(t, dc, http.StatusBadRequest, "POST", "/api/v1/stats/reset", nil)
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/stats/reset?confirm=true", nil)
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/admin/stats/reset", nil)
+
+	guarded := newTestCache(t, func(config *CacheConfig) { config.AdminAPIKey = "admin" })
+	for _, target := range []string{"/api/v1/stats/reset?confirm=true", "/api/v1/admin/stats/reset"} {
+		mustServe(t, guarded, http.StatusForbidden, "POST", target, nil)
+		mustServe(t, guarded, http.StatusForbidden, "POST", target, nil, "X-API-Key", "guess")
+		mustServe(t, guarded, http.StatusOK, "POST", target, nil, "X-API-Key", "admin")
+	}
+	mustServe(t, guarded, http.StatusBadRequest, "POST", "/api/v1/stats/reset", nil, "X-API-Key", "admin")
+}
+
+func TestStatsAfterReset(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) { config.MaxSize = 2 })
+	ctx := context.Background()
+
+	// Activity before the reset, of every kind counted
+	for _, key := range []string{"a", "b", "c"} {
+		if err := dc.Set(ctx, key, key, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dc.Get(ctx, "c")
+	dc.Get(ctx, "missing")
+	dc.Delete(ctx, "c")
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/admin/stats/reset", nil)
+
+	if err := dc.Set(ctx, "d", "d", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	dc.Get(ctx, "d")
+	dc.Get(ctx, "d")
+	dc.Get(ctx, "missing")
+
+	var stats map[string]interface{}
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/stats", nil)
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]interface{}{"hits": 2.0, "misses": 1.0, "sets": 1.0, "deletes": 0.0, "evictions": 0.0} {
+		if stats[field] != want {
+			t.Errorf("%s after reset = %v, want %v", field, stats[field], want)
+		}
+	}
+}
+
+func TestCountersArePerCache(t *testing.T) {
+	ctx := context.Background()
+	first := newTestCache(t, nil)
+	second := newTestCache(t, nil)
+
+	if err := first.Set(ctx, "user:1", "alice", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	first.Get(ctx, "user:1")
+	first.Delete(ctx, "user:1")
+
+	before := second.Counters()
+	second.Get(ctx, "missing")
+	after := second.Counters()
+	if got := after.Misses - before.Misses; got != 1 {
+		t.Errorf("one missed Get counted %d misses", got)
+	}
+	if after.Hits != 0 || after.Sets != 0 || after.Deletes != 0 {
+		t.Errorf("second cache counted the first cache's operations: %+v", after)
+	}
+
+	taken, err := first.TakeCounters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken.Hits != 1 || taken.Misses != 0 || taken.Sets != 1 || taken.Deletes != 1 {
+		t.Errorf("TakeCounters = %+v, want one hit, set and delete", taken)
+	}
+	if got := first.Counters(); got.Hits != 0 || got.Sets != 0 || got.Deletes != 0 || got.Since.Before(taken.Since) {
+		t.Errorf("Counters after TakeCounters = %+v, want zero counts since the take", got)
+	}
+}
+
+func TestAdminRoutesRequireAdminKey(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) { config.AdminAPIKey = "admin" })
+	if err := dc.Set(context.Background(), "user:1", "alice", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range []struct {
+		method, target string
+		body           interface{}
+	}{
+		{"GET", "/api/v1/admin/items/user:1", nil},
+		{"POST", "/api/v1/admin/stats/reset", nil},
+		{"GET", "/api/v1/admin/config", nil},
+		{"GET", "/api/v1/admin/write-behind/pending", nil},
+		{"GET", "/api/v1/admin/fan-out-rules", nil},
+		{"POST", "/api/v1/admin/fan-out-rules", map[string]interface{}{"source_pattern": "user:*", "targets": []string{"profile:{1}"}}},
+		{"GET", "/api/v1/admin/tag-patterns", nil},
+		{"POST", "/api/v1/admin/tag-patterns", map[string]string{"pattern": "user:*", "tag": "users"}},
+		{"POST", "/api/v1/admin/loaders", map[string]string{"pattern": "user:*", "url": "http://127.0.0.1:1"}},
+	} {
+		for _, apiKey := range []string{"", "guess"} {
+			if code, _ := serve(t, dc, route.method, route.target, route.body, "X-API-Key", apiKey); code != http.StatusForbidden {
+				t.Errorf("%s %s with API key %q: status %d, want 403", route.method, route.target, apiKey, code)
+			}
+		}
+		if code, body := serve(t, dc, route.method, route.target, route.body, "X-API-Key", "admin"); code == http.StatusForbidden {
+			t.Errorf("%s %s with the admin key: 403 %s", route.method, route.target, body)
+		}
+	}
+}
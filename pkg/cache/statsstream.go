@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// statsEvent is one event of the live stats stream. Rates cover the time
+// since the previous event.
+type statsEvent struct {
+	HitsRate        float64 `json:"hits_rate"`
+	MissRate        float64 `json:"miss_rate"`
+	ItemCount       int     `json:"item_count"`
+	MemoryBytes     int64   `json:"memory_bytes"`
+	EvictionsPerSec float64 `json:"evictions_per_sec"`
+}
+
+// storedBytes sums the stored size of every item, compressed or chunked
+// where it is.
+// Like ttlDistribution it stops after ScanLockTimeout, so the sum can be
+// short on a very large cache.
+func (dc *DistroCache) storedBytes() int64 {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	deadline := time.Now().Add(dc.scanLockTimeout())
+	var total int64
+	scanned := 0
+	dc.data.Scan(func(_ string, item *CacheItem) bool {
+		scanned++
+		if scanned%1024 == 0 && time.Now().After(deadline) {
+			return false
+		}
+		if item.Compressed != nil {
+			total += int64(len(item.Compressed))
+		} else if item.Chunks > 0 {
+			total += item.ChunkedBytes
+		} else {
+			total += item.Size
+		}
+		return true
+	})
+	return total
+}
+
+// CloseStreams ends open stats streams and expiry watches. Register it with
+// http.Server.RegisterOnShutdown so long-lived responses do not hold up a
+// graceful shutdown; Shutdown and Close end them too.
+func (dc *DistroCache) CloseStreams() {
+	dc.closeStreams()
+}
+
+// handleStatsStream pushes a stats event every StatsStreamInterval as
+// server-sent events until the client disconnects
+func (dc *DistroCache) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	interval := dc.config.StatsStreamInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	// The stream outlives the server's WriteTimeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	lastHits := dc.stats.hitCount.Load()
+	lastMisses := dc.stats.missCount.Load()
+	lastEvictions := dc.stats.evictionCount.Load()
+	last := time.Now()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-dc.streams.Done():
+			return
+		case now := <-ticker.C:
+			hits := dc.stats.hitCount.Load()
+			misses := dc.stats.missCount.Load()
+			evictions := dc.stats.evictionCount.Load()
+
+			event := statsEvent{
+				ItemCount:       dc.data.Len(),
+				MemoryBytes:     dc.storedBytes(),
+				EvictionsPerSec: float64(evictions-lastEvictions) / now.Sub(last).Seconds(),
+			}
+			if lookups := (hits - lastHits) + (misses - lastMisses); lookups > 0 {
+				event.HitsRate = float64(hits-lastHits) / float64(lookups)
+				event.MissRate = float64(misses-lastMisses) / float64(lookups)
+			}
+			lastHits, lastMisses, lastEvictions, last = hits, misses, evictions, now
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readStatsEvents reads n events from a stats stream
+func readStatsEvents(t *testing.T, scanner *bufio.Scanner, n int) []statsEvent {
+	t.Helper()
+
+	var events []statsEvent
+	for len(events) < n && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event statsEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("decoding event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) < n {
+		t.Fatalf("stream ended after %d events, want %d: %v", len(events), n, scanner.Err())
+	}
+	return events
+}
+
+func TestStatsStream(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.StatsStreamInterval = 20 * time.Millisecond
+	})
+	server := httptest.NewServer(dc.Router())
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	if err := dc.Set(ctx, "user:1", "alice", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	baseline := runtime.NumGoroutine()
+
+	streamCtx, disconnect := context.WithCancel(ctx)
+	req, _ := http.NewRequestWithContext(streamCtx, "GET", server.URL+"/api/v1/stats/stream", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	dc.Get(ctx, "user:1")
+	dc.Get(ctx, "missing")
+	events := readStatsEvents(t, bufio.NewScanner(resp.Body), 3)
+	for i, event := range events {
+		if event.ItemCount != 1 || event.MemoryBytes <= 0 {
+			t.Errorf("event %d = %+v, want one item and its size", i, event)
+		}
+	}
+
+	disconnect()
+	resp.Body.Close()
+	checkGoroutinesExit(t, baseline)
+}
+
+func TestCloseStreamsEndsStatsStream(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.StatsStreamInterval = 20 * time.Millisecond
+	})
+	server := httptest.NewServer(dc.Router())
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/api/v1/stats/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	readStatsEvents(t, scanner, 1)
+
+	dc.CloseStreams()
+	done := make(chan struct{})
+	go func() {
+		for scanner.Scan() {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("stream still open a second after CloseStreams")
+	}
+}
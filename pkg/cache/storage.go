@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrItemTooLarge is returned when an item does not fit in the backend
+var ErrItemTooLarge = errors.New("item too large for storage backend")
+
+// ErrStoreFull is returned when the backend has no room for another item
+var ErrStoreFull = errors.New("storage backend is full")
+
+// StorageBackend holds the cache items. The cache serialises writes with its
+// own lock, but reads run concurrently under the read lock, so
+// implementations must be safe for concurrent use. Reads never call Set;
+// access statistics are kept by the cache (see accessTracker).
+//
+// Set returns an error because backends with bounded slots or disk I/O can
+// fail to store an item; the cache reports those failures to the caller.
+type StorageBackend interface {
+	Get(ctx context.Context, key string) (*CacheItem, bool)
+	Set(ctx context.Context, key string, item *CacheItem) error
+	Delete(ctx context.Context, key string) bool
+	// Scan calls fn for every item until it returns false; fn must not
+	// modify the backend
+	Scan(fn func(key string, item *CacheItem) bool)
+	// Len returns the number of items stored
+	Len() int
+	Close() error
+}
+
+// Formats backends that store bytes encode items in
+const (
+	// StorageFormatGob keeps the Go types of values, e.g. ints stay ints
+	StorageFormatGob = "gob"
+	// StorageFormatJSON is human-readable, but values read back are
+	// json.Number, string, bool, []interface{} or map[string]interface{}
+	StorageFormatJSON = "json"
+)
+
+// gobItemMarker starts gob-encoded items; JSON-encoded ones start with '{'
+const gobItemMarker byte = 0x01
+
+func init() {
+	// Types values decoded from JSON request bodies are made of
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(json.Number(""))
+	gob.Register(time.Time{})
+}
+
+// formattedBackend is a backend that stores items as bytes in a
+// configurable format
+type formattedBackend interface {
+	setFormat(format string)
+}
+
+// validStorageFormat reports an unknown StorageFormat
+func validStorageFormat(format string) error {
+	switch format {
+	case "", StorageFormatGob, StorageFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown storage format %q", format)
+	}
+}
+
+// encodeItem serialises an item for backends that store bytes. Items whose
+// value gob cannot encode, e.g. a type never passed to gob.Register, are
+// stored as JSON instead.
+func encodeItem(item *CacheItem, format string) ([]byte, error) {
+	if format == StorageFormatJSON {
+		return json.Marshal(item)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(gobItemMarker)
+	if err := gob.NewEncoder(&buf).Encode(item); err != nil {
+		return json.Marshal(item)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeItem deserialises an item written by encodeItem in either format.
+// JSON numbers are decoded as json.Number so integer counters keep full
+// precision.
+func decodeItem(data []byte) (*CacheItem, error) {
+	var item CacheItem
+	if len(data) > 0 && data[0] == gobItemMarker {
+		if err := gob.NewDecoder(bytes.NewReader(data[1:])).Decode(&item); err != nil {
+			return nil, err
+		}
+		return &item, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// InMemoryBackend keeps items in a Go map
+type InMemoryBackend struct {
+	mutex sync.RWMutex
+	items map[string]*CacheItem
+}
+
+// NewInMemoryBackend creates an empty in-memory backend
+func NewInMemoryBackend() *InMemoryBackend {
+	return &InMemoryBackend{items: make(map[string]*CacheItem)}
+}
+
+func (b *InMemoryBackend) Get(ctx context.Context, key string) (*CacheItem, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	item, exists := b.items[key]
+	return item, exists
+}
+
+func (b *InMemoryBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.items[key] = item
+	return nil
+}
+
+func (b *InMemoryBackend) Delete(ctx context.Context, key string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, exists := b.items[key]
+	delete(b.items, key)
+	return exists
+}
+
+func (b *InMemoryBackend) Scan(fn func(key string, item *CacheItem) bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for key, item := range b.items {
+		if !fn(key, item) {
+			return
+		}
+	}
+}
+
+func (b *InMemoryBackend) Len() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return len(b.items)
+}
+
+func (b *InMemoryBackend) Close() error {
+	return nil
+}
+
+// indexExisting rebuilds the tag index, cleanup order and eviction state for
+// items already held by the backend, e.g. a persistent one reopened
+func (dc *DistroCache) indexExisting() {
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		dc.addToTagIndex(key, item.Tags)
+		if dc.keyPos != nil {
+			dc.keyPos[key] = len(dc.keyOrder)
+			dc.keyOrder = append(dc.keyOrder, key)
+		}
+		if dc.eviction != nil {
+			dc.eviction.Insert(key)
+		}
+		return true
+	})
+	dc.updateSizeGauges()
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// BadgerBackend stores items in a BadgerDB directory, which suits caches
+// larger than memory with write-heavy workloads
+type BadgerBackend struct {
+	db     *badger.DB
+	count  atomic.Int64
+	format string
+}
+
+// NewBadgerBackend opens or creates a BadgerDB database in dir
+func NewBadgerBackend(dir string) (*BadgerBackend, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BadgerBackend{db: db}
+	err = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			b.count.Add(1)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *BadgerBackend) Get(ctx context.Context, key string) (*CacheItem, bool) {
+	var item *CacheItem
+	err := b.db.View(func(txn *badger.Txn) error {
+		entry, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		return entry.Value(func(data []byte) error {
+			item, err = decodeItem(data)
+			return err
+		})
+	})
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		slog.WarnContext(ctx, "badger get failed", "key", key, "error", err)
+	}
+	return item, item != nil
+}
+
+func (b *BadgerBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	data, err := encodeItem(item, b.format)
+	if err != nil {
+		return err
+	}
+
+	added := false
+	err = b.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(key))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		added = err != nil
+		return txn.Set([]byte(key), data)
+	})
+	if err == nil && added {
+		b.count.Add(1)
+	}
+	return err
+}
+
+func (b *BadgerBackend) Delete(ctx context.Context, key string) bool {
+	deleted := false
+	err := b.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(key)); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		deleted = true
+		return txn.Delete([]byte(key))
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "badger delete failed", "key", key, "error", err)
+		return false
+	}
+	if deleted {
+		b.count.Add(-1)
+	}
+	return deleted
+}
+
+func (b *BadgerBackend) Scan(fn func(key string, item *CacheItem) bool) {
+	b.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			entry := it.Item()
+			var item *CacheItem
+			err := entry.Value(func(data []byte) error {
+				var err error
+				item, err = decodeItem(data)
+				return err
+			})
+			if err != nil {
+				continue
+			}
+			if !fn(string(entry.KeyCopy(nil)), item) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BadgerBackend) Len() int {
+	return int(b.count.Load())
+}
+
+func (b *BadgerBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *BadgerBackend) setFormat(format string) {
+	b.format = format
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket is the bucket holding cache items
+var boltBucket = []byte("items")
+
+// BoltBackend stores items in a BoltDB file. Writes are not fsynced, since
+// cached data can be rebuilt from its origin; a crash may lose recent writes
+// but never corrupts the file.
+type BoltBackend struct {
+	db     *bolt.DB
+	count  atomic.Int64
+	format string
+}
+
+// NewBoltBackend opens or creates the BoltDB file at path
+func NewBoltBackend(path string) (*BoltBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	db.NoSync = true
+
+	b := &BoltBackend{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+		b.count.Store(int64(bucket.Stats().KeyN))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *BoltBackend) Get(ctx context.Context, key string) (*CacheItem, bool) {
+	var item *CacheItem
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		var err error
+		item, err = decodeItem(data)
+		return err
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "bolt get failed", "key", key, "error", err)
+	}
+	return item, item != nil
+}
+
+func (b *BoltBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	data, err := encodeItem(item, b.format)
+	if err != nil {
+		return err
+	}
+
+	added := false
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		added = bucket.Get([]byte(key)) == nil
+		return bucket.Put([]byte(key), data)
+	})
+	if err == nil && added {
+		b.count.Add(1)
+	}
+	return err
+}
+
+func (b *BoltBackend) Delete(ctx context.Context, key string) bool {
+	deleted := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if bucket.Get([]byte(key)) == nil {
+			return nil
+		}
+		deleted = true
+		return bucket.Delete([]byte(key))
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "bolt delete failed", "key", key, "error", err)
+		return false
+	}
+	if deleted {
+		b.count.Add(-1)
+	}
+	return deleted
+}
+
+func (b *BoltBackend) Scan(fn func(key string, item *CacheItem) bool) {
+	b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltBucket).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			item, err := decodeItem(v)
+			if err != nil {
+				continue
+			}
+			if !fn(string(k), item) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltBackend) Len() int {
+	return int(b.count.Load())
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *BoltBackend) setFormat(format string) {
+	b.format = format
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package cache
+
+import (
+	"errors"
+)
+
+// newMMapBackend is unavailable on platforms without mmap
+func newMMapBackend(path string, slots int, slotSize int) (StorageBackend, error) {
+	return nil, errors.New("mmap backend is not supported on this platform")
+}
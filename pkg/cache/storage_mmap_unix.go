@@ -0,0 +1,162 @@
+//go:build unix
+
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapSlotHeader is the length prefix stored at the start of each slot
+const mmapSlotHeader = 4
+
+// mmapBackend keeps serialised items in fixed-size slots of a memory-mapped
+// file, so item data lives in the OS page cache rather than the Go heap.
+// Only the key to offset index is held in memory.
+type mmapBackend struct {
+	mutex    sync.RWMutex
+	file     *os.File
+	data     []byte
+	slotSize int64
+	offsets  map[string]int64
+	free     []int64
+	next     int64
+	format   string
+}
+
+// newMMapBackend maps path with room for slots items of slotSize bytes. Any
+// existing contents of the file are discarded.
+func newMMapBackend(path string, slots int, slotSize int) (*mmapBackend, error) {
+	if slots <= 0 || slotSize <= mmapSlotHeader {
+		return nil, fmt.Errorf("invalid mmap store size: %d slots of %d bytes", slots, slotSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(slots) * int64(slotSize)
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	data, err := unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("mmap %s: %w", path, err)
+	}
+
+	return &mmapBackend{
+		file:     file,
+		data:     data,
+		slotSize: int64(slotSize),
+		offsets:  make(map[string]int64),
+	}, nil
+}
+
+// read decodes the item in the slot at offset; callers must hold the lock
+func (s *mmapBackend) read(offset int64) (*CacheItem, bool) {
+	length := int64(binary.LittleEndian.Uint32(s.data[offset:]))
+	item, err := decodeItem(s.data[offset+mmapSlotHeader : offset+mmapSlotHeader+length])
+	return item, err == nil
+}
+
+func (s *mmapBackend) Get(ctx context.Context, key string) (*CacheItem, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	offset, exists := s.offsets[key]
+	if !exists {
+		return nil, false
+	}
+	return s.read(offset)
+}
+
+func (s *mmapBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	payload, err := encodeItem(item, s.format)
+	if err != nil {
+		return err
+	}
+	if int64(len(payload)) > s.slotSize-mmapSlotHeader {
+		return fmt.Errorf("%w: %d bytes, slot holds %d", ErrItemTooLarge, len(payload), s.slotSize-mmapSlotHeader)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	offset, exists := s.offsets[key]
+	if !exists {
+		switch {
+		case len(s.free) > 0:
+			offset = s.free[len(s.free)-1]
+			s.free = s.free[:len(s.free)-1]
+		case s.next+s.slotSize <= int64(len(s.data)):
+			offset = s.next
+			s.next += s.slotSize
+		default:
+			return ErrStoreFull
+		}
+		s.offsets[key] = offset
+	}
+
+	binary.LittleEndian.PutUint32(s.data[offset:], uint32(len(payload)))
+	copy(s.data[offset+mmapSlotHeader:], payload)
+	return nil
+}
+
+func (s *mmapBackend) Delete(ctx context.Context, key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	offset, exists := s.offsets[key]
+	if !exists {
+		return false
+	}
+	delete(s.offsets, key)
+	s.free = append(s.free, offset)
+	return true
+}
+
+func (s *mmapBackend) Scan(fn func(key string, item *CacheItem) bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for key, offset := range s.offsets {
+		item, ok := s.read(offset)
+		if !ok {
+			continue
+		}
+		if !fn(key, item) {
+			return
+		}
+	}
+}
+
+func (s *mmapBackend) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.offsets)
+}
+
+func (s *mmapBackend) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := unix.Munmap(s.data); err != nil {
+		return err
+	}
+	s.data = nil
+	return s.file.Close()
+}
+
+func (s *mmapBackend) setFormat(format string) {
+	s.format = format
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heapInUse returns the live heap after a full collection
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func TestMMapBackendKeepsValuesOffHeap(t *testing.T) {
+	const items, valueSize = 100000, 1000
+	if testing.Short() {
+		t.Skip("writes 100MB of values")
+	}
+
+	backend, err := newMMapBackend(filepath.Join(t.TempDir(), "cache.mmap"), items, 2048)
+	if err != nil {
+		t.Skipf("mmap unavailable: %v", err)
+	}
+	defer backend.Close()
+	ctx := context.Background()
+
+	value := strings.Repeat("v", valueSize)
+	before := heapInUse()
+	for i := 0; i < items; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		item := &CacheItem{Key: key, Value: value, CreatedAt: time.Now(), TTL: time.Hour}
+		if err := backend.Set(ctx, key, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grown := int64(heapInUse()) - int64(before)
+
+	// Only the key index stays on the heap, a fraction of the value bytes
+	payload := int64(items * valueSize)
+	t.Logf("heap grew %d MB for %d MB of values", grown>>20, payload>>20)
+	if grown > payload/4 {
+		t.Errorf("heap grew %d bytes storing %d bytes of values, want under a quarter", grown, payload)
+	}
+
+	for _, i := range []int{0, items / 2, items - 1} {
+		key := fmt.Sprintf("key:%d", i)
+		item, found := backend.Get(ctx, key)
+		if !found || item.Value != value {
+			t.Errorf("Get(%s) = %v, want the stored value", key, found)
+		}
+	}
+}
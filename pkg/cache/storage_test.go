@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestBackendEquivalence runs the same operations against a cache on each
+// storage backend. The whole suite can also be run against one backend with
+// the -backend flag.
+func TestBackendEquivalence(t *testing.T) {
+	for _, backend := range []string{"memory", "bolt", "badger", "mmap"} {
+		t.Run(backend, func(t *testing.T) {
+			clock := NewMockClock(time.Now())
+			config := func(config *CacheConfig) {
+				config.Clock = clock
+				config.MaxSize = 5
+				useTestBackend(t, config, backend)
+			}
+			dc := newTestCache(t, config)
+			ctx := context.Background()
+
+			if err := dc.Set(ctx, "user:1", "alice", time.Minute, []string{"users"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := dc.Set(ctx, "user:1", "alicia", time.Minute, []string{"users"}); err != nil {
+				t.Fatal(err)
+			}
+			if item, found := dc.Get(ctx, "user:1"); !found || item.Value != "alicia" {
+				t.Errorf("Get after overwrite = %v, %v; want alicia", item, found)
+			}
+
+			if _, err := dc.Increment(ctx, "hits", 41); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := dc.Increment(ctx, "hits", 1); err != nil || n != 42 {
+				t.Errorf("Increment = %d, %v; want 42", n, err)
+			}
+
+			if err := dc.Set(ctx, "user:2", "bob", time.Minute, []string{"users"}); err != nil {
+				t.Fatal(err)
+			}
+			if removed, err := dc.InvalidateByTag(ctx, "users"); err != nil || removed != 2 {
+				t.Errorf("InvalidateByTag = %d, %v; want 2 removed", removed, err)
+			}
+
+			if err := dc.Set(ctx, "short", "value", time.Second, nil); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(2 * time.Second)
+			if _, found := dc.Get(ctx, "short"); found {
+				t.Error("expired item still served")
+			}
+			if _, err := dc.Cleanup(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			// Filling past MaxSize evicts the least recently used keys
+			for i := 0; i < 6; i++ {
+				if err := dc.Set(ctx, fmt.Sprintf("fill:%d", i), i, time.Minute, nil); err != nil {
+					t.Fatal(err)
+				}
+				clock.Advance(time.Millisecond)
+			}
+			keys := dc.Keys()
+			slices.Sort(keys)
+			want := []string{"fill:1", "fill:2", "fill:3", "fill:4", "fill:5"}
+			if !slices.Equal(keys, want) {
+				t.Errorf("keys = %v, want %v", keys, want)
+			}
+			if got := dc.Len(); got != 5 {
+				t.Errorf("Len = %d, want 5", got)
+			}
+
+			if !dc.Delete(ctx, "fill:3") {
+				t.Error("Delete of a present key reported nothing removed")
+			}
+			if _, found := dc.Peek(ctx, "fill:3"); found {
+				t.Error("deleted key still present")
+			}
+		})
+	}
+}
+
+// TestStorageFormatRoundTrip writes a nested value to a bolt file, reopens
+// it in a new cache and checks what each format reads back
+func TestStorageFormatRoundTrip(t *testing.T) {
+	value := map[string]interface{}{
+		"name":   "alice",
+		"age":    30,
+		"joined": time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		"scores": []interface{}{1.5, int64(2)},
+		"address": map[string]interface{}{
+			"city": "Paris",
+			"zip":  75001,
+		},
+	}
+
+	for _, format := range []string{StorageFormatGob, StorageFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.db")
+			open := func() *DistroCache {
+				return newTestCache(t, func(config *CacheConfig) {
+					config.StorageFormat = format
+					var err error
+					if config.Backend, err = NewBoltBackend(path); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+			ctx := context.Background()
+
+			dc := open()
+			if err := dc.Set(ctx, "user:1", value, time.Hour, []string{"users"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := dc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			item, found := open().Get(ctx, "user:1")
+			if !found {
+				t.Fatal("item lost across the reopen")
+			}
+			if !slices.Equal(item.Tags, []string{"users"}) {
+				t.Errorf("tags = %v, want [users]", item.Tags)
+			}
+
+			switch format {
+			case StorageFormatGob:
+				if !reflect.DeepEqual(item.Value, value) {
+					t.Errorf("value read back = %#v, want %#v", item.Value, value)
+				}
+			case StorageFormatJSON:
+				got, _ := item.Value.(map[string]interface{})
+				if age, ok := got["age"].(json.Number); !ok || age != "30" {
+					t.Errorf("age read back from JSON = %#v, want json.Number 30", got["age"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// streamValue writes the JSON value of item StreamChunkSize bytes at a
+// time, flushing after each piece, so a large value reaches the client as
+// chunked transfer encoding instead of in one write. Each piece gets its
+// own WriteTimeout, so a value that takes longer than that to send in full
+// is not cut off.
+func (dc *DistroCache) streamValue(w http.ResponseWriter, item *CacheItem) {
+	data, err := json.Marshal(item.Value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	size := dc.config.StreamChunkSize
+	if size <= 0 {
+		size = 64 << 10
+	}
+	timeout := dc.config.WriteTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	for start := 0; start < len(data); start += size {
+		controller.SetWriteDeadline(time.Now().Add(timeout))
+		end := min(start+size, len(data))
+		if _, err := w.Write(data[start:end]); err != nil {
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
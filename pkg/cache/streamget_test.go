@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flushRecorder records how much of the body had been written at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushedAt = append(f.flushedAt, f.Body.Len())
+	f.ResponseRecorder.Flush()
+}
+
+func TestStreamGet(t *testing.T) {
+	const chunk = 64 << 10
+	dc := newTestCache(t, func(config *CacheConfig) { config.StreamChunkSize = chunk })
+
+	value := map[string]interface{}{"payload": strings.Repeat("abcdefgh", 100000)}
+	if err := dc.Set(context.Background(), "report", value, time.Hour, nil); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(value)
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	dc.Router().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/cache/report?stream=true", nil))
+
+	if !bytes.Equal(rec.Body.Bytes(), want) {
+		t.Fatalf("streamed %d bytes, want the %d-byte encoded value", rec.Body.Len(), len(want))
+	}
+	// A flush after every piece, the first long before the value is complete
+	pieces := (len(want) + chunk - 1) / chunk
+	if len(rec.flushedAt) != pieces {
+		t.Fatalf("%d flushes, want one per %d-byte piece: %d", len(rec.flushedAt), chunk, pieces)
+	}
+	for i, written := range rec.flushedAt {
+		if wantWritten := min((i+1)*chunk, len(want)); written != wantWritten {
+			t.Errorf("flush %d after %d bytes, want %d", i, written, wantWritten)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Limits on the tags learned from key prefixes, so keys with unbounded
+// prefixes cannot grow the table without limit
+const (
+	maxLearnedPrefixes      = 1024
+	maxLearnedTagsPerPrefix = 8
+)
+
+// Orders accepted by the sort parameter of /stats/tags
+const (
+	TagSortMissRate = "miss_rate_desc"
+	TagSortMisses   = "misses_desc"
+	TagSortHits     = "hits_desc"
+)
+
+// TagPattern attributes misses on keys matching Pattern to Tags, for keys
+// whose tags cannot be inferred from earlier writes
+type TagPattern struct {
+	Pattern string   `json:"pattern"`
+	Tags    []string `json:"tags"`
+}
+
+// TagAccess is the number of hits and misses attributed to a tag
+type TagAccess struct {
+	Tag      string  `json:"tag"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	MissRate float64 `json:"miss_rate"`
+}
+
+// tagAccessStats counts hits and misses per tag. A hit is counted against
+// the tags of the item served. A miss has no item, so its tags are taken
+// from the first registered TagPattern matching the key or, failing that,
+// from the tags seen on earlier writes of keys with the same prefix (the
+// key up to and including its first ':').
+type tagAccessStats struct {
+	mutex    sync.Mutex
+	counts   map[string]*TagAccess
+	patterns []TagPattern
+	learned  map[string][]string // key prefix -> tags
+}
+
+func newTagAccessStats() *tagAccessStats {
+	return &tagAccessStats{
+		counts:  make(map[string]*TagAccess),
+		learned: make(map[string][]string),
+	}
+}
+
+// keyPrefix returns the key up to and including its first ':', and the rest
+func keyPrefix(key string) (string, string, bool) {
+	i := strings.IndexByte(key, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return key[:i+1], key[i+1:], true
+}
+
+// learn records the tags written with key for its prefix. Tags naming the
+// key itself, such as "user:123" on key "user:123", are skipped since other
+// keys of the prefix would not carry them.
+func (ta *tagAccessStats) learn(key string, tags []string) {
+	prefix, id, ok := keyPrefix(key)
+	if !ok || len(tags) == 0 {
+		return
+	}
+
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+
+	known, exists := ta.learned[prefix]
+	if !exists && len(ta.learned) >= maxLearnedPrefixes {
+		return
+	}
+	for _, tag := range tags {
+		if len(known) >= maxLearnedTagsPerPrefix {
+			break
+		}
+		if (id != "" && strings.Contains(tag, id)) || slices.Contains(known, tag) {
+			continue
+		}
+		known = append(known, tag)
+	}
+	ta.learned[prefix] = known
+}
+
+// inferTags returns the tags a missing key would carry; callers must hold
+// ta.mutex
+func (ta *tagAccessStats) inferTags(key string) []string {
+	for _, pattern := range ta.patterns {
+		if matchKeyPattern(pattern.Pattern, key) {
+			return pattern.Tags
+		}
+	}
+	if prefix, _, ok := keyPrefix(key); ok {
+		return ta.learned[prefix]
+	}
+	return nil
+}
+
+// counter returns the counts of tag, creating them; callers must hold ta.mutex
+func (ta *tagAccessStats) counter(tag string) *TagAccess {
+	count, exists := ta.counts[tag]
+	if !exists {
+		count = &TagAccess{Tag: tag}
+		ta.counts[tag] = count
+	}
+	return count
+}
+
+// recordHit counts a hit against the tags of the item served
+func (ta *tagAccessStats) recordHit(tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+
+	for _, tag := range tags {
+		ta.counter(tag).Hits++
+	}
+}
+
+// recordMiss counts a miss of key against tags, or against the tags
+// inferred for key when tags is empty
+func (ta *tagAccessStats) recordMiss(key string, tags []string) {
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+
+	if len(tags) == 0 {
+		tags = ta.inferTags(key)
+	}
+	for _, tag := range tags {
+		ta.counter(tag).Misses++
+	}
+}
+
+// addPattern registers a pattern, replacing an earlier one with the same
+// glob
+func (ta *tagAccessStats) addPattern(pattern TagPattern) {
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+
+	for i, existing := range ta.patterns {
+		if existing.Pattern == pattern.Pattern {
+			ta.patterns[i] = pattern
+			return
+		}
+	}
+	ta.patterns = append(ta.patterns, pattern)
+}
+
+// listPatterns returns a snapshot of the registered patterns
+func (ta *tagAccessStats) listPatterns() []TagPattern {
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+
+	patterns := make([]TagPattern, len(ta.patterns))
+	copy(patterns, ta.patterns)
+	return patterns
+}
+
+// reset zeroes the counts, keeping patterns and learned prefixes
+func (ta *tagAccessStats) reset() {
+	ta.mutex.Lock()
+	defer ta.mutex.Unlock()
+
+	ta.counts = make(map[string]*TagAccess)
+}
+
+// top returns the n tags ranked by order, ties broken by tag name
+func (ta *tagAccessStats) top(order string, n int) ([]TagAccess, error) {
+	var less func(a, b TagAccess) bool
+	switch order {
+	case TagSortMissRate:
+		less = func(a, b TagAccess) bool {
+			if a.MissRate != b.MissRate {
+				return a.MissRate > b.MissRate
+			}
+			return a.Misses > b.Misses
+		}
+	case TagSortMisses:
+		less = func(a, b TagAccess) bool { return a.Misses > b.Misses }
+	case TagSortHits:
+		less = func(a, b TagAccess) bool { return a.Hits > b.Hits }
+	default:
+		return nil, fmt.Errorf("unknown sort %q", order)
+	}
+
+	ta.mutex.Lock()
+	result := make([]TagAccess, 0, len(ta.counts))
+	for _, count := range ta.counts {
+		entry := *count
+		if total := entry.Hits + entry.Misses; total > 0 {
+			entry.MissRate = float64(entry.Misses) / float64(total)
+		}
+		result = append(result, entry)
+	}
+	ta.mutex.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if less(result[i], result[j]) {
+			return true
+		}
+		if less(result[j], result[i]) {
+			return false
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result[:min(n, len(result))], nil
+}
+
+// AddTagPattern registers a mapping used to attribute misses on keys
+// matching pattern.Pattern to pattern.Tags
+func (dc *DistroCache) AddTagPattern(pattern TagPattern) error {
+	if _, err := path.Match(pattern.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern.Pattern, err)
+	}
+
+	dc.tagAccess.addPattern(pattern)
+	return nil
+}
+
+// TagPatterns returns a snapshot of the registered tag patterns
+func (dc *DistroCache) TagPatterns() []TagPattern {
+	return dc.tagAccess.listPatterns()
+}
+
+// TopTagAccess returns the n tags with the most hits, misses or highest
+// miss rate, depending on order
+func (dc *DistroCache) TopTagAccess(order string, n int) ([]TagAccess, error) {
+	return dc.tagAccess.top(order, n)
+}
+
+func (dc *DistroCache) handleListTagPatterns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"patterns": dc.TagPatterns(),
+	})
+}
+
+func (dc *DistroCache) handleAddTagPattern(w http.ResponseWriter, r *http.Request) {
+	var pattern TagPattern
+	if err := json.NewDecoder(r.Body).Decode(&pattern); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	pattern.Tags = uniqueStrings(pattern.Tags)
+	if pattern.Pattern == "" || len(pattern.Tags) == 0 {
+		http.Error(w, "pattern and tags are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := dc.AddTagPattern(pattern); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
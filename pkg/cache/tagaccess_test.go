@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTopMissReport(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	// Earlier writes teach the cache that user:* keys are tagged users
+	for i := 0; i < 3; i++ {
+		if err := dc.Set(ctx, fmt.Sprintf("user:%d", i), "u", time.Minute, []string{"users"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := dc.Set(ctx, fmt.Sprintf("order:%d", i), "o", time.Minute, []string{"orders"}); err != nil {
+			t.Fatal(err)
+		}
+		dc.Get(ctx, fmt.Sprintf("order:%d", i))
+	}
+	dc.Get(ctx, "user:0")
+	for i := 100; i < 110; i++ {
+		dc.Get(ctx, fmt.Sprintf("user:%d", i))
+	}
+	dc.Get(ctx, "order:100")
+
+	// A registered pattern attributes keys with no tagged writes
+	mustServe(t, dc, http.StatusCreated, "POST", "/api/v1/admin/tag-patterns",
+		map[string]interface{}{"pattern": "session:*", "tags": []string{"sessions"}})
+	dc.Get(ctx, "session:abc")
+
+	var stats TagStats
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/stats/tags?sort=miss_rate_desc&limit=10", nil)
+	if err := json.Unmarshal(body, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Access) != 3 {
+		t.Fatalf("access report = %+v, want users, sessions and orders", stats.Access)
+	}
+	// sessions missed every time, users 10 of 11, orders 1 of 4
+	for i, want := range []TagAccess{
+		{Tag: "sessions", Misses: 1, MissRate: 1},
+		{Tag: "users", Hits: 1, Misses: 10, MissRate: 10.0 / 11},
+		{Tag: "orders", Hits: 3, Misses: 1, MissRate: 0.25},
+	} {
+		if stats.Access[i] != want {
+			t.Errorf("access[%d] = %+v, want %+v", i, stats.Access[i], want)
+		}
+	}
+
+	mustServe(t, dc, http.StatusBadRequest, "GET", "/api/v1/stats/tags?sort=sideways", nil)
+}
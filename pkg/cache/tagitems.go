@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// TagItems returns the values of the non-expired items tagged with tag,
+// keyed by cache key. A positive limit caps the number of items returned.
+// Reads do not count as hits or refresh access times.
+func (dc *DistroCache) TagItems(ctx context.Context, tag string, limit int) map[string]interface{} {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	items := make(map[string]interface{})
+	for _, key := range dc.tagIndex[tag] {
+		if limit > 0 && len(items) >= limit {
+			break
+		}
+		item, exists := dc.data.Get(ctx, key)
+		if !exists || dc.isExpired(item) {
+			continue
+		}
+		// Over HTTP, keys the caller may not read are left out
+		if _, fromRequest := requestInfoFrom(ctx); fromRequest && checkACL(ctx, item, false) != nil {
+			continue
+		}
+		value, err := dc.storedValue(item)
+		if err != nil {
+			slog.ErrorContext(ctx, "cache tag items failed", "key", key, "error", err)
+			continue
+		}
+		items[key] = value
+	}
+	return items
+}
+
+func (dc *DistroCache) handleTagItems(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	items := dc.TagItems(r.Context(), requestTag(r), limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count": len(items),
+		"items": items,
+	})
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"container/heap"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxTopTags caps the top query parameter of /stats/tags
+const maxTopTags = 1000
+
+// TagCount is a tag and the number of keys carrying it
+type TagCount struct {
+	Tag  string `json:"tag"`
+	Keys int    `json:"keys"`
+}
+
+// tagCountHeap is a min-heap on key count, used to keep the largest N tags
+type tagCountHeap []TagCount
+
+// smallerTag orders tags by key count, breaking ties by name so results are
+// stable
+func smallerTag(a, b TagCount) bool {
+	if a.Keys != b.Keys {
+		return a.Keys < b.Keys
+	}
+	return a.Tag > b.Tag
+}
+
+func (h tagCountHeap) Len() int            { return len(h) }
+func (h tagCountHeap) Less(i, j int) bool  { return smallerTag(h[i], h[j]) }
+func (h tagCountHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *tagCountHeap) Push(x interface{}) { *h = append(*h, x.(TagCount)) }
+func (h *tagCountHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// largestTags returns the n tags with the most keys, largest first, in
+// O(tags · log n) time and O(n) memory; callers must hold the lock
+func (dc *DistroCache) largestTags(n int) []TagCount {
+	if n <= 0 {
+		return []TagCount{}
+	}
+
+	h := make(tagCountHeap, 0, n)
+	for tag, keys := range dc.tagIndex {
+		entry := TagCount{Tag: tag, Keys: len(keys)}
+		if h.Len() < n {
+			heap.Push(&h, entry)
+		} else if smallerTag(h[0], entry) {
+			h[0] = entry
+			heap.Fix(&h, 0)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool { return smallerTag(h[j], h[i]) })
+	return h
+}
+
+// TagStats describes the size of the tag index
+type TagStats struct {
+	TotalTags    int        `json:"total_tags"`
+	TotalEntries int        `json:"total_entries"`
+	Largest      []TagCount `json:"largest"`
+	// Access ranks tags by hits or misses when /stats/tags is given a sort
+	Access []TagAccess `json:"access,omitempty"`
+}
+
+// GetTagStats returns the number of tags, tag→key entries and the top
+// largest tags
+func (dc *DistroCache) GetTagStats(top int) TagStats {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	return TagStats{
+		TotalTags:    len(dc.tagIndex),
+		TotalEntries: dc.tagEntries,
+		Largest:      dc.largestTags(top),
+	}
+}
+
+// TagSummary is a tag in the tag listing and the number of keys carrying it
+type TagSummary struct {
+	Tag      string `json:"tag"`
+	KeyCount int    `json:"key_count"`
+}
+
+// ListTags returns every tag starting with prefix, most keys first
+func (dc *DistroCache) ListTags(prefix string) []TagSummary {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	tags := make([]TagSummary, 0, len(dc.tagIndex))
+	for tag, keys := range dc.tagIndex {
+		if strings.HasPrefix(tag, prefix) {
+			tags = append(tags, TagSummary{Tag: tag, KeyCount: len(keys)})
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].KeyCount != tags[j].KeyCount {
+			return tags[i].KeyCount > tags[j].KeyCount
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+// registerTagMetrics exports the tag index size to Prometheus. The values
+// are read under the cache lock when scraped.
+func (dc *DistroCache) registerTagMetrics() {
+	read := func(fn func() float64) func() float64 {
+		return func() float64 {
+			dc.mutex.RLock()
+			defer dc.mutex.RUnlock()
+			return fn()
+		}
+	}
+
+	dc.registerer.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "distrocache_tags_total",
+			Help: "Number of distinct tags in the tag index",
+		}, read(func() float64 { return float64(len(dc.tagIndex)) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "distrocache_tag_entries_total",
+			Help: "Number of tag to key entries in the tag index",
+		}, read(func() float64 { return float64(dc.tagEntries) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "distrocache_largest_tag_keys",
+			Help: "Number of keys carrying the largest tag",
+		}, read(func() float64 {
+			if largest := dc.largestTags(1); len(largest) > 0 {
+				return float64(largest[0].Keys)
+			}
+			return 0
+		})),
+	)
+}
+
+func (dc *DistroCache) handleTagStats(w http.ResponseWriter, r *http.Request) {
+	top := 10
+	if raw := r.URL.Query().Get("top"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "top must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		top = min(n, maxTopTags)
+	}
+
+	stats := dc.GetTagStats(top)
+	if order := r.URL.Query().Get("sort"); order != "" {
+		limit := 10
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxTopTags)
+		}
+
+		access, err := dc.TopTagAccess(order, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stats.Access = access
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (dc *DistroCache) handleListTags(w http.ResponseWriter, r *http.Request) {
+	listed := dc.ListTags(scopeName(r, r.URL.Query().Get("prefix")))
+
+	tags := listed[:0]
+	for _, tag := range listed {
+		if hiddenFromRequest(r, tag.Tag) {
+			continue
+		}
+		if tenant := requestTenant(r); tenant != "" {
+			tag.Tag = strings.TrimPrefix(tag.Tag, tenantPrefix(tenant))
+		}
+		tags = append(tags, tag)
+	}
+	writeJSON(w, http.StatusOK, tags)
+}
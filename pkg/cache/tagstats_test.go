@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListTags(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	set := func(key string, tags ...string) {
+		t.Helper()
+		if err := dc.Set(ctx, key, "v", time.Minute, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		set(fmt.Sprintf("user:%d", i), "users", "user-profiles")
+	}
+	for i := 0; i < 2; i++ {
+		set(fmt.Sprintf("product:%d", i), "products")
+	}
+	set("user:0", "users") // retagging drops user-profiles from user:0
+	dc.Delete(ctx, "product:1")
+
+	var tags []TagSummary
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/tags", nil), &tags); err != nil {
+		t.Fatal(err)
+	}
+	want := []TagSummary{{"users", 3}, {"user-profiles", 2}, {"products", 1}}
+	if !slices.Equal(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusOK, "GET", "/api/v1/tags?prefix=user", nil), &tags); err != nil {
+		t.Fatal(err)
+	}
+	if want := want[:2]; !slices.Equal(tags, want) {
+		t.Errorf("tags with prefix user = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Tenant keys and tags are stored as tenantMarker + tenant + tenantSeparator
+// + name. Un-scoped names may not start with tenantMarker (see
+// checkKeyNamespace), so un-scoped clients can never reach a tenant's data,
+// whatever their keys look like.
+const (
+	tenantMarker    = "\x00"
+	tenantSeparator = "/"
+)
+
+type tenantContextKey struct{}
+
+// tenantMiddleware stores the tenant from the route in the request context
+func tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := mux.Vars(r)["tenant"]
+		ctx := context.WithValue(r.Context(), tenantContextKey{}, tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestTenant returns the tenant a request is scoped to, or ""
+func requestTenant(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// tenantPrefix returns the internal key prefix for a tenant
+func tenantPrefix(tenant string) string {
+	return tenantMarker + tenant + tenantSeparator
+}
+
+// inTenantNamespace reports whether an internal key or tag belongs to a tenant
+func inTenantNamespace(name string) bool {
+	return strings.HasPrefix(name, tenantMarker)
+}
+
+// scopeName namespaces a key or tag to the request's tenant
+func scopeName(r *http.Request, name string) string {
+	if tenant := requestTenant(r); tenant != "" {
+		return tenantPrefix(tenant) + name
+	}
+	return name
+}
+
+// requestKey returns the internal cache key addressed by a request
+func requestKey(r *http.Request) string {
+	return scopeName(r, mux.Vars(r)["key"])
+}
+
+// requestTag returns the internal tag addressed by a request
+func requestTag(r *http.Request) string {
+	return scopeName(r, mux.Vars(r)["tag"])
+}
+
+// scopeTags namespaces a list of tags to the request's tenant
+func scopeTags(r *http.Request, tags []string) []string {
+	if requestTenant(r) == "" {
+		return tags
+	}
+
+	scoped := make([]string, len(tags))
+	for i, tag := range tags {
+		scoped[i] = scopeName(r, tag)
+	}
+	return scoped
+}
+
+// presentKeys strips the tenant namespace from a list of keys
+func presentKeys(r *http.Request, keys []string) []string {
+	tenant := requestTenant(r)
+	if tenant == "" {
+		return keys
+	}
+
+	prefix := tenantPrefix(tenant)
+	view := make([]string, len(keys))
+	for i, key := range keys {
+		view[i] = strings.TrimPrefix(key, prefix)
+	}
+	return view
+}
+
+// hiddenFromRequest reports whether a key or tag belongs to a tenant's
+// namespace that the request, being un-scoped, must not see in listings
+func hiddenFromRequest(r *http.Request, name string) bool {
+	return requestTenant(r) == "" && inTenantNamespace(name)
+}
+
+// presentItem strips the tenant namespace from an item before it is returned
+func presentItem(r *http.Request, item *CacheItem) *CacheItem {
+	tenant := requestTenant(r)
+	if tenant == "" {
+		return item
+	}
+
+	prefix := tenantPrefix(tenant)
+	view := *item
+	view.Key = strings.TrimPrefix(item.Key, prefix)
+	view.Tags = make([]string, len(item.Tags))
+	for i, tag := range item.Tags {
+		view.Tags[i] = strings.TrimPrefix(tag, prefix)
+	}
+	return &view
+}
+
+// PrefixStats returns the number of items and tags whose names start with prefix
+func (dc *DistroCache) PrefixStats(prefix string) (items int, tags int) {
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+
+	dc.data.Scan(func(key string, _ *CacheItem) bool {
+		if strings.HasPrefix(key, prefix) {
+			items++
+		}
+		return true
+	})
+	for tag := range dc.tagIndex {
+		if strings.HasPrefix(tag, prefix) {
+			tags++
+		}
+	}
+	return items, tags
+}
+
+// FlushPrefix removes every item whose key starts with prefix
+func (dc *DistroCache) FlushPrefix(ctx context.Context, prefix string) (int, error) {
+	if err := dc.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer dc.unlock()
+
+	var matched []*CacheItem
+	dc.data.Scan(func(key string, item *CacheItem) bool {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, item)
+		}
+		return true
+	})
+
+	for _, item := range matched {
+		dc.removeFromTagIndex(item.Key, item.Tags)
+		dc.removed(item, EvictReasonDeleted)
+		dc.dropItem(ctx, item.Key)
+	}
+	dc.updateSizeGauges()
+	return len(matched), nil
+}
+
+func (dc *DistroCache) handleTenantStats(w http.ResponseWriter, r *http.Request) {
+	tenant := requestTenant(r)
+	items, tags := dc.PrefixStats(tenantPrefix(tenant))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant":      tenant,
+		"total_items": items,
+		"total_tags":  tags,
+		"node_id":     dc.config.NodeID,
+	})
+}
+
+func (dc *DistroCache) handleTenantFlush(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deleted, err := dc.FlushPrefix(ctx, tenantPrefix(requestTenant(r)))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"deleted": deleted,
+	})
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newTenantCache returns a cache holding a tenant item and an un-scoped one
+func newTenantCache(t *testing.T) *DistroCache {
+	dc := newTestCache(t, nil)
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/t/acme/cache/secret",
+		map[string]interface{}{"value": "s3cret", "tags": []string{"users"}})
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache/mine",
+		map[string]interface{}{"value": 1, "tags": []string{"public"}})
+	return dc
+}
+
+func TestUnscopedKeyListingHidesTenantKeys(t *testing.T) {
+	dc := newTenantCache(t)
+
+	var keys struct {
+		Keys  []string `json:"keys"`
+		Count int      `json:"count"`
+	}
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache/keys?regex=.*", nil)
+	if err := json.Unmarshal(body, &keys); err != nil {
+		t.Fatal(err)
+	}
+	if keys.Count != 1 || len(keys.Keys) != 1 || keys.Keys[0] != "mine" {
+		t.Errorf("un-scoped key listing = %+v, want only mine", keys)
+	}
+
+}
+
+func TestUnscopedTagListingHidesTenantTags(t *testing.T) {
+	dc := newTenantCache(t)
+
+	var tags []TagSummary
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/tags", nil)
+	if err := json.Unmarshal(body, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Tag != "public" {
+		t.Errorf("un-scoped tag listing = %+v, want only public", tags)
+	}
+
+	body = mustServe(t, dc, http.StatusOK, "GET", "/api/v1/t/acme/tags", nil)
+	if err := json.Unmarshal(body, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Tag != "users" {
+		t.Errorf("tenant tag listing = %+v, want only users", tags)
+	}
+}
+
+func TestUnscopedKeysWithSlashesStaySeparateFromTenants(t *testing.T) {
+	dc := newTenantCache(t)
+
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache?key=users%2F42", map[string]interface{}{"value": "alice"})
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/cache?key=users%2F42", nil)
+	if !strings.Contains(string(body), `"alice"`) {
+		t.Errorf("users/42 = %s, want alice", body)
+	}
+
+	// An un-scoped key shaped like a tenant key is an ordinary key
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/cache?key=acme%2Fsecret", map[string]interface{}{"value": "public"})
+	body = mustServe(t, dc, http.StatusOK, "GET", "/api/v1/t/acme/cache/secret", nil)
+	if !strings.Contains(string(body), `"s3cret"`) {
+		t.Errorf("tenant acme secret = %s after an un-scoped acme/secret set, want s3cret", body)
+	}
+
+	// The tenant marker is reserved
+	mustServe(t, dc, http.StatusBadRequest, "GET", "/api/v1/cache?key=%00acme%2Fsecret", nil)
+	mustServe(t, dc, http.StatusBadRequest, "POST", "/api/v1/cache/mine",
+		map[string]interface{}{"value": 1, "tags": []string{"\x00acme/users"}})
+}
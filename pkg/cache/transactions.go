@@ -0,0 +1,433 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrTransactionNotFound is returned for unknown, finished or expired transactions
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// stagedWrite is a set waiting for its transaction to commit
+type stagedWrite struct {
+	key   string
+	value interface{}
+	ttl   time.Duration
+	tags  []string
+	opts  SetOptions
+}
+
+// PendingTransaction holds the writes staged by a transaction. Nothing is
+// visible to readers until Commit applies all of them at once.
+type PendingTransaction struct {
+	ID        string
+	ExpiresAt time.Time
+
+	mutex  sync.Mutex
+	writes []stagedWrite
+	index  map[string]int // key -> position in writes
+}
+
+// stage adds a write, replacing an earlier write of the same key
+func (txn *PendingTransaction) stage(write stagedWrite) int {
+	txn.mutex.Lock()
+	defer txn.mutex.Unlock()
+
+	if pos, exists := txn.index[write.key]; exists {
+		txn.writes[pos] = write
+	} else {
+		txn.index[write.key] = len(txn.writes)
+		txn.writes = append(txn.writes, write)
+	}
+	return len(txn.writes)
+}
+
+// transactionRegistry tracks open transactions until they are committed,
+// rolled back or time out
+type transactionRegistry struct {
+	mutex   sync.Mutex
+	pending map[string]*PendingTransaction
+	timeout time.Duration
+	now     func() time.Time
+}
+
+func newTransactionRegistry(timeout time.Duration, now func() time.Time) *transactionRegistry {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &transactionRegistry{
+		pending: make(map[string]*PendingTransaction),
+		timeout: timeout,
+		now:     now,
+	}
+}
+
+// begin opens a transaction
+func (tr *transactionRegistry) begin() (*PendingTransaction, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	txn := &PendingTransaction{
+		ID:        id,
+		ExpiresAt: tr.now().Add(tr.timeout),
+		index:     make(map[string]int),
+	}
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tr.pending[id] = txn
+	return txn, nil
+}
+
+// get returns an open transaction, discarding it if it has timed out
+func (tr *transactionRegistry) get(id string) (*PendingTransaction, bool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	txn, exists := tr.pending[id]
+	if !exists {
+		return nil, false
+	}
+	if tr.now().After(txn.ExpiresAt) {
+		delete(tr.pending, id)
+		return nil, false
+	}
+	return txn, true
+}
+
+// take removes an open transaction so it can be finished exactly once
+func (tr *transactionRegistry) take(id string) (*PendingTransaction, bool) {
+	txn, exists := tr.get(id)
+	if !exists {
+		return nil, false
+	}
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if tr.pending[id] != txn {
+		return nil, false
+	}
+	delete(tr.pending, id)
+	return txn, true
+}
+
+// prune discards timed-out transactions
+func (tr *transactionRegistry) prune() {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	now := tr.now()
+	for id, txn := range tr.pending {
+		if now.After(txn.ExpiresAt) {
+			delete(tr.pending, id)
+		}
+	}
+}
+
+// BeginTransaction opens a transaction for staging writes
+func (dc *DistroCache) BeginTransaction() (*PendingTransaction, error) {
+	return dc.transactions.begin()
+}
+
+// StageSet stages a write in an open transaction
+func (dc *DistroCache) StageSet(id, key string, value interface{}, ttl time.Duration, tags []string, opts SetOptions) (int, error) {
+	if err := dc.validateKey(key); err != nil {
+		return 0, err
+	}
+	if err := dc.validateTags(tags); err != nil {
+		return 0, err
+	}
+
+	txn, exists := dc.transactions.get(id)
+	if !exists {
+		return 0, ErrTransactionNotFound
+	}
+	return txn.stage(stagedWrite{key: key, value: value, ttl: ttl, tags: tags, opts: opts}), nil
+}
+
+// RollbackTransaction discards an open transaction and its staged writes
+func (dc *DistroCache) RollbackTransaction(id string) error {
+	if _, exists := dc.transactions.take(id); !exists {
+		return ErrTransactionNotFound
+	}
+	return nil
+}
+
+// priorState is what a key held before a commit changed it, so a failed
+// commit can put it back. A chunked item is kept whole, since its chunks go
+// when it is replaced or evicted.
+type priorState struct {
+	key          string
+	item         *CacheItem
+	exists       bool
+	tombstone    tombstone
+	hasTombstone bool
+}
+
+// undoLog records the prior state of every key a commit writes or evicts,
+// in order
+type undoLog struct {
+	states []priorState
+}
+
+// record notes what key holds now; callers must hold the write lock. It does
+// nothing on a nil log, outside a commit.
+func (u *undoLog) record(ctx context.Context, dc *DistroCache, key string) {
+	if u == nil {
+		return
+	}
+
+	state := priorState{key: key}
+	if item, exists := dc.data.Get(ctx, key); exists {
+		if assembled, err := dc.assemble(item); err == nil {
+			state.item, state.exists = assembled, true
+		}
+	}
+	state.tombstone, state.hasTombstone = dc.tombstones[key]
+	u.states = append(u.states, state)
+}
+
+// CommitTransaction applies every staged write of a transaction under a
+// single acquisition of the write lock, so readers see either none or all of
+// them. Versions are checked before anything is written, and if storing a
+// write fails the ones already applied, and any items evicted to make room
+// for them, are put back. The transaction is finished either way. Returns
+// the number of keys written.
+//
+// In write-through mode every write is persisted with WriteThroughFn, under
+// the lock and after the version checks, before any of them is stored. The
+// backend is not transactional: if persisting one write fails, the commit
+// fails and nothing is cached, but the writes persisted before it stay in the
+// backend.
+func (dc *DistroCache) CommitTransaction(ctx context.Context, id string) (int, error) {
+	txn, exists := dc.transactions.take(id)
+	if !exists {
+		return 0, ErrTransactionNotFound
+	}
+
+	txn.mutex.Lock()
+	writes := txn.writes
+	txn.mutex.Unlock()
+
+	compressed := make([][]byte, len(writes))
+	for i, write := range writes {
+		data, err := dc.compressValue(write.value)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", write.key, err)
+		}
+		compressed[i] = data
+	}
+
+	if err := dc.applyWrites(ctx, writes, compressed); err != nil {
+		return 0, err
+	}
+
+	if dc.writeBehind != nil {
+		for _, write := range writes {
+			dc.writeBehind.MarkDirty(write.key, write.value)
+		}
+	}
+	for _, write := range writes {
+		dc.applyFanOut(ctx, write.key)
+	}
+	return len(writes), nil
+}
+
+// applyWrites stores all writes or none of them
+func (dc *DistroCache) applyWrites(ctx context.Context, writes []stagedWrite, compressed [][]byte) error {
+	if err := dc.lock(ctx); err != nil {
+		return err
+	}
+	defer dc.unlock()
+
+	for _, write := range writes {
+		if err := dc.checkVersion(ctx, write.key, write.opts.Version); err != nil {
+			return fmt.Errorf("%s: %w", write.key, err)
+		}
+		// The ACL may have changed since the write was staged
+		if err := dc.authorizeLocked(ctx, write.key); err != nil {
+			return fmt.Errorf("%s: %w", write.key, err)
+		}
+	}
+	for _, write := range writes {
+		if err := dc.writeThrough(ctx, write.key, write.value); err != nil {
+			return fmt.Errorf("%s: %w", write.key, err)
+		}
+	}
+
+	dc.undo = &undoLog{}
+	defer func() { dc.undo = nil }()
+	removals := len(dc.removals)
+
+	for i, write := range writes {
+		dc.undo.record(ctx, dc, write.key)
+		if err := dc.storeLocked(ctx, write.key, write.value, compressed[i], write.ttl, write.tags, write.opts); err != nil {
+			states := dc.undo.states
+			for j := len(states) - 1; j >= 0; j-- {
+				dc.restoreItem(ctx, states[j])
+			}
+			// Evictions undone here never happened as far as OnEvict knows
+			dc.removals = dc.removals[:removals]
+			dc.updateSizeGauges()
+			return fmt.Errorf("%s: %w", write.key, err)
+		}
+	}
+	return nil
+}
+
+// restoreItem puts a key back to the state recorded before a failed commit;
+// callers must hold the write lock
+func (dc *DistroCache) restoreItem(ctx context.Context, state priorState) {
+	key := state.key
+	if current, exists := dc.data.Get(ctx, key); exists {
+		dc.removeFromTagIndex(key, current.Tags)
+		dc.dropItem(ctx, key)
+	}
+	if state.exists {
+		item := *state.item
+		if err := dc.chunkItem(key, &item); err == nil {
+			if err := dc.putItem(ctx, key, &item); err == nil {
+				dc.addToTagIndex(key, item.Tags)
+			} else {
+				dc.dropChunks(key)
+			}
+		}
+	}
+	if state.hasTombstone {
+		dc.tombstones[key] = state.tombstone
+	} else {
+		delete(dc.tombstones, key)
+	}
+}
+
+// writeTransactionError responds with the status matching a transaction error
+func writeTransactionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrTransactionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrStaleVersion):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrWriteThrough):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		writeStoreError(w, err)
+	}
+}
+
+func (dc *DistroCache) handleBeginTransaction(w http.ResponseWriter, r *http.Request) {
+	txn, err := dc.BeginTransaction()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"txn_id":     txn.ID,
+		"expires_at": txn.ExpiresAt,
+	})
+}
+
+func (dc *DistroCache) handleStageSet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key          string                 `json:"key"`
+		Value        interface{}            `json:"value"`
+		TTL          int                    `json:"ttl,omitempty"`
+		NoExpire     bool                   `json:"no_expire,omitempty"`
+		Tags         []string               `json:"tags,omitempty"`
+		Version      int64                  `json:"version,omitempty"`
+		OnExpireURL  string                 `json:"on_expire_url,omitempty"`
+		ExternalETag string                 `json:"external_etag,omitempty"`
+		Metadata     map[string]interface{} `json:"metadata,omitempty"`
+		ACL          *KeyACL                `json:"acl,omitempty"`
+	}
+
+	if dc.config.MaxRequestBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, dc.config.MaxRequestBodyBytes)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	if err := checkKeyNamespace(r, req.Key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := dc.requestTTL(req.TTL, req.NoExpire)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := dc.authorizeKey(r.Context(), req.Key, true); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	opts := SetOptions{
+		Version:      req.Version,
+		OnExpireURL:  req.OnExpireURL,
+		ExternalETag: req.ExternalETag,
+		Metadata:     req.Metadata,
+		ACL:          req.ACL,
+	}
+	staged, err := dc.StageSet(mux.Vars(r)["txn_id"], req.Key, req.Value, ttl, uniqueStrings(req.Tags), opts)
+	if writeKeyError(w, err) {
+		return
+	}
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "staged",
+		"writes": staged,
+	})
+}
+
+func (dc *DistroCache) handleCommitTransaction(w http.ResponseWriter, r *http.Request) {
+	written, err := dc.CommitTransaction(r.Context(), mux.Vars(r)["txn_id"])
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "committed",
+		"written": written,
+	})
+}
+
+func (dc *DistroCache) handleRollbackTransaction(w http.ResponseWriter, r *http.Request) {
+	if err := dc.RollbackTransaction(mux.Vars(r)["txn_id"]); err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "rolled_back"})
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingBackend is an in-memory backend that refuses to store items whose
+// key starts with a given prefix
+type failingBackend struct {
+	*InMemoryBackend
+	prefix string
+}
+
+func (b *failingBackend) Set(ctx context.Context, key string, item *CacheItem) error {
+	if strings.HasPrefix(key, b.prefix) {
+		return ErrStoreFull
+	}
+	return b.InMemoryBackend.Set(ctx, key, item)
+}
+
+// snapshot returns every live key with its value, version and tags
+func snapshot(t *testing.T, dc *DistroCache) map[string]string {
+	t.Helper()
+
+	ctx := context.Background()
+	state := make(map[string]string)
+	for _, key := range dc.Keys() {
+		item, found := dc.Peek(ctx, key)
+		if !found {
+			continue
+		}
+		tags := append([]string(nil), item.Tags...)
+		sort.Strings(tags)
+		state[key] = strings.Join([]string{toString(item.Value), toString(item.Version), strings.Join(tags, ",")}, "|")
+	}
+	return state
+}
+
+func toString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestCommitTransactionFailureLeavesNoPartialState(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.MaxSize = 3
+		config.Backend = &failingBackend{InMemoryBackend: NewInMemoryBackend(), prefix: "bad"}
+	})
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := dc.Set(ctx, key, "old-"+key, time.Minute, []string{"old"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := snapshot(t, dc)
+
+	txn, err := dc.BeginTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The cache is full, so the new keys evict old ones before "bad" fails
+	for _, key := range []string{"a", "new1", "new2", "bad"} {
+		if _, err := dc.StageSet(txn.ID, key, "new-"+key, time.Minute, []string{"new"}, SetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dc.CommitTransaction(ctx, txn.ID); !errors.Is(err, ErrStoreFull) {
+		t.Fatalf("CommitTransaction error = %v, want ErrStoreFull", err)
+	}
+
+	after := snapshot(t, dc)
+	if len(after) != len(before) {
+		t.Errorf("keys after failed commit = %v, want %v", after, before)
+	}
+	for key, want := range before {
+		if got := after[key]; got != want {
+			t.Errorf("%s = %q after failed commit, want %q", key, got, want)
+		}
+	}
+	if keys := dc.PreviewInvalidation([]string{"new"}, false); len(keys) != 0 {
+		t.Errorf("tag index still lists %v under the failed commit's tag", keys)
+	}
+	if keys := dc.PreviewInvalidation([]string{"old"}, false); len(keys) != 3 {
+		t.Errorf("tag index lists %v under the original tag, want all three keys", keys)
+	}
+}
+
+func TestCommitTransactionStaleVersionWritesNothing(t *testing.T) {
+	backend := newRecordingBackend()
+	dc := newWriteThroughCache(t, backend)
+	ctx := context.Background()
+
+	if err := dc.SetWithOptions(ctx, "b", "current", time.Minute, nil, SetOptions{Version: 10}); err != nil {
+		t.Fatal(err)
+	}
+	writes := backend.count()
+
+	txn, _ := dc.BeginTransaction()
+	dc.StageSet(txn.ID, "a", "new", time.Minute, nil, SetOptions{})
+	dc.StageSet(txn.ID, "b", "stale", time.Minute, nil, SetOptions{Version: 5})
+	if _, err := dc.CommitTransaction(ctx, txn.ID); !errors.Is(err, ErrStaleVersion) {
+		t.Fatalf("CommitTransaction error = %v, want ErrStaleVersion", err)
+	}
+
+	if _, found := dc.Peek(ctx, "a"); found {
+		t.Error("a was cached by a commit that failed its version check")
+	}
+	if got := backend.count(); got != writes {
+		t.Errorf("backend saw %d writes from a commit that failed its version check", got-writes)
+	}
+}
+
+func TestCommitTransactionAppliesAllWrites(t *testing.T) {
+	backend := newRecordingBackend()
+	dc := newWriteThroughCache(t, backend)
+	ctx := context.Background()
+
+	txn, _ := dc.BeginTransaction()
+	dc.StageSet(txn.ID, "account:1", 50, time.Minute, []string{"accounts"}, SetOptions{})
+	dc.StageSet(txn.ID, "account:2", 150, time.Minute, []string{"accounts"}, SetOptions{})
+	if _, found := dc.Peek(ctx, "account:1"); found {
+		t.Fatal("staged write visible before commit")
+	}
+
+	written, err := dc.CommitTransaction(ctx, txn.ID)
+	if err != nil || written != 2 {
+		t.Fatalf("CommitTransaction = %d, %v; want 2, nil", written, err)
+	}
+	for _, key := range []string{"account:1", "account:2"} {
+		if _, found := dc.Peek(ctx, key); !found {
+			t.Errorf("%s missing after commit", key)
+		}
+		if _, found := backend.value(key); !found {
+			t.Errorf("%s not written through", key)
+		}
+	}
+	if _, err := dc.CommitTransaction(ctx, txn.ID); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("second commit error = %v, want ErrTransactionNotFound", err)
+	}
+}
+
+func TestCommitChecksACLSetAfterStaging(t *testing.T) {
+	dc := newTestCache(t, nil)
+	ctx := context.Background()
+
+	var begun struct {
+		TxnID string `json:"txn_id"`
+	}
+	if err := json.Unmarshal(mustServe(t, dc, http.StatusCreated, "POST", "/api/v1/transactions/begin", nil), &begun); err != nil {
+		t.Fatal(err)
+	}
+	mustServe(t, dc, http.StatusOK, "POST", "/api/v1/transactions/"+begun.TxnID+"/set",
+		map[string]interface{}{"key": "config", "value": "stolen"}, "X-API-Key", "intruder")
+
+	// The owner locks the key down after the write was staged
+	if err := dc.SetWithOptions(ctx, "config", "secret", time.Minute, nil,
+		SetOptions{ACL: &KeyACL{Write: []string{"owner"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	mustServe(t, dc, http.StatusForbidden, "POST", "/api/v1/transactions/"+begun.TxnID+"/commit", nil, "X-API-Key", "intruder")
+	if item, _ := dc.Peek(ctx, "config"); item.Value != "secret" {
+		t.Errorf("config = %v after a refused commit, want secret", item.Value)
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// NoExpireTTL is the ttl, in seconds, that asks for an item that never expires
+const NoExpireTTL = -1
+
+// maxTTLSeconds is the largest ttl in seconds that fits in a time.Duration
+const maxTTLSeconds = math.MaxInt64 / int64(time.Second)
+
+// requestTTL converts a ttl in seconds given over HTTP into a duration: 0
+// means DefaultTTL, while NoExpireTTL or noExpire store the item without
+// expiry. Other negative values and values above MaxTTL are rejected.
+func (dc *DistroCache) requestTTL(seconds int, noExpire bool) (time.Duration, error) {
+	limit := maxTTLSeconds
+	if dc.config.MaxTTL > 0 {
+		limit = int64(dc.config.MaxTTL / time.Second)
+	}
+
+	switch {
+	case noExpire || seconds == NoExpireTTL:
+		return 0, nil
+	case seconds == 0:
+		return dc.config.DefaultTTL, nil
+	case seconds < 0:
+		return 0, fmt.Errorf("ttl must be positive, 0 for the default or %d to never expire", NoExpireTTL)
+	case int64(seconds) > limit:
+		return 0, fmt.Errorf("ttl exceeds the maximum of %d seconds", limit)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetTTLValidation(t *testing.T) {
+	dc := newTestCache(t, func(config *CacheConfig) {
+		config.DefaultTTL = 5 * time.Minute
+		config.MaxTTL = 24 * time.Hour
+	})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name    string
+		body    map[string]interface{}
+		status  int
+		wantTTL time.Duration
+	}{
+		{"negative", map[string]interface{}{"value": "v", "ttl": -5}, http.StatusBadRequest, 0},
+		{"zero", map[string]interface{}{"value": "v", "ttl": 0}, http.StatusOK, 5 * time.Minute},
+		{"omitted", map[string]interface{}{"value": "v"}, http.StatusOK, 5 * time.Minute},
+		{"in range", map[string]interface{}{"value": "v", "ttl": 3600}, http.StatusOK, time.Hour},
+		{"at cap", map[string]interface{}{"value": "v", "ttl": 86400}, http.StatusOK, 24 * time.Hour},
+		{"over cap", map[string]interface{}{"value": "v", "ttl": 86401}, http.StatusBadRequest, 0},
+		{"overflowing", map[string]interface{}{"value": "v", "ttl": int64(1) << 62}, http.StatusBadRequest, 0},
+		{"never expire", map[string]interface{}{"value": "v", "ttl": NoExpireTTL}, http.StatusOK, 0},
+		{"no_expire flag", map[string]interface{}{"value": "v", "no_expire": true}, http.StatusOK, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dc.Delete(ctx, "key")
+			mustServe(t, dc, tc.status, "POST", "/api/v1/cache/key", tc.body)
+
+			item, found := dc.Peek(ctx, "key")
+			if tc.status != http.StatusOK {
+				if found {
+					t.Error("rejected set stored the item")
+				}
+				return
+			}
+			if !found || item.TTL != tc.wantTTL {
+				t.Errorf("stored TTL = %v, want %v", item.TTL, tc.wantTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ttlBounds returns the configured TTL bucket boundaries sorted and
+// de-duplicated, defaulting to 1m and 5m
+func (dc *DistroCache) ttlBounds() []time.Duration {
+	bounds := slices.DeleteFunc(slices.Clone(dc.config.TTLBuckets), func(d time.Duration) bool {
+		return d <= 0
+	})
+	if len(bounds) == 0 {
+		return []time.Duration{time.Minute, 5 * time.Minute}
+	}
+	slices.Sort(bounds)
+	return slices.Compact(bounds)
+}
+
+// ttlLabel formats a bucket boundary compactly, e.g. "5m" rather than "5m0s"
+func ttlLabel(d time.Duration) string {
+	label := d.String()
+	if strings.HasSuffix(label, "m0s") {
+		label = strings.TrimSuffix(label, "0s")
+	}
+	if strings.HasSuffix(label, "h0m") {
+		label = strings.TrimSuffix(label, "0m")
+	}
+	return label
+}
+
+// ttlBucketLabels names the buckets split by bounds: "lt_1m", "1m_to_5m",
+// "gt_5m". A TTL equal to a boundary falls in the bucket above it.
+func ttlBucketLabels(bounds []time.Duration) []string {
+	labels := make([]string, len(bounds)+1)
+	labels[0] = "lt_" + ttlLabel(bounds[0])
+	for i := 1; i < len(bounds); i++ {
+		labels[i] = ttlLabel(bounds[i-1]) + "_to_" + ttlLabel(bounds[i])
+	}
+	labels[len(bounds)] = "gt_" + ttlLabel(bounds[len(bounds)-1])
+	return labels
+}
+
+// ttlDistribution counts unexpired items per TTL bucket, with items that
+// never expire under "no_expiry". Like KeysMatching it stops after
+// ScanLockTimeout so a large cache cannot hold up writers, and complete
+// reports whether every item was counted. Callers must hold the read lock.
+func (dc *DistroCache) ttlDistribution() (map[string]int, bool) {
+	bounds := dc.ttlBounds()
+	counts := make([]int, len(bounds)+1)
+	noExpiry := 0
+
+	deadline := time.Now().Add(dc.scanLockTimeout())
+	now := dc.now()
+	complete := true
+	scanned := 0
+	dc.data.Scan(func(_ string, item *CacheItem) bool {
+		scanned++
+		if scanned%1024 == 0 && time.Now().After(deadline) {
+			complete = false
+			return false
+		}
+		switch {
+		case item.ExpiredAt(now):
+		case item.TTL == 0:
+			noExpiry++
+		default:
+			counts[sort.Search(len(bounds), func(i int) bool { return item.TTL < bounds[i] })]++
+		}
+		return true
+	})
+
+	distribution := map[string]int{"no_expiry": noExpiry}
+	for i, label := range ttlBucketLabels(bounds) {
+		distribution[label] = counts[i]
+	}
+	return distribution, complete
+}
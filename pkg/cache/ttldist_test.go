@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestTTLDistribution(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		buckets []time.Duration
+		want    map[string]int
+	}{
+		{"default", nil, map[string]int{"lt_1m": 2, "1m_to_5m": 3, "gt_5m": 1, "no_expiry": 2}},
+		{"configured", []time.Duration{time.Hour, 10 * time.Second, 2 * time.Minute},
+			map[string]int{"lt_10s": 1, "10s_to_2m": 3, "2m_to_1h": 1, "gt_1h": 1, "no_expiry": 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := NewMockClock(time.Now())
+			dc := newTestCache(t, func(config *CacheConfig) {
+				config.Clock = clock
+				config.TTLBuckets = tc.buckets
+			})
+			ctx := context.Background()
+
+			ttls := []time.Duration{5 * time.Second, 30 * time.Second, time.Minute, 90 * time.Second,
+				4 * time.Minute, 2 * time.Hour, 0, 0}
+			for i, ttl := range ttls {
+				if err := dc.Set(ctx, fmt.Sprintf("key:%d", i), i, ttl, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Expired items are not counted
+			if err := dc.Set(ctx, "gone", "x", time.Millisecond, nil); err != nil {
+				t.Fatal(err)
+			}
+			clock.Advance(time.Second)
+
+			stats := dc.GetStats()
+			if got := stats["ttl_distribution"].(map[string]int); !maps.Equal(got, tc.want) {
+				t.Errorf("ttl_distribution = %v, want %v", got, tc.want)
+			}
+			if stats["ttl_distribution_truncated"] != false {
+				t.Error("distribution of a small cache reported truncated")
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// expiryNotification is the payload POSTed to an item's on_expire_url
+type expiryNotification struct {
+	Key  string   `json:"key"`
+	Tags []string `json:"tags,omitempty"`
+	url  string
+}
+
+// webhookDispatcher delivers expiry notifications from a pool of workers so
+// that slow or failing endpoints never block the cleanup sweep
+type webhookDispatcher struct {
+	workers  int
+	queue    chan expiryNotification
+	client   *http.Client
+	retries  int
+	failures prometheus.Counter
+}
+
+// newWebhookDispatcher creates a dispatcher for the given number of delivery
+// workers; the cache starts them with run
+func newWebhookDispatcher(workers, retries int, failures prometheus.Counter) *webhookDispatcher {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &webhookDispatcher{
+		workers:  workers,
+		queue:    make(chan expiryNotification, 1024),
+		client:   &http.Client{Timeout: 5 * time.Second},
+		retries:  retries,
+		failures: failures,
+	}
+}
+
+// Notify queues a notification without blocking. If the queue is full the
+// notification is dropped and counted as a failure.
+func (wd *webhookDispatcher) Notify(ctx context.Context, url, key string, tags []string) {
+	select {
+	case wd.queue <- expiryNotification{Key: key, Tags: tags, url: url}:
+	default:
+		wd.failures.Inc()
+		slog.WarnContext(ctx, "expiry webhook queue full, dropping notification", "key", key)
+	}
+}
+
+// run delivers queued notifications until ctx is done. Notifications still
+// queued at shutdown are dropped.
+func (wd *webhookDispatcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-wd.queue:
+			if err := wd.deliver(ctx, notification); err != nil {
+				wd.failures.Inc()
+				slog.WarnContext(ctx, "expiry webhook failed", "key", notification.Key, "error", err)
+			}
+		}
+	}
+}
+
+// deliver POSTs a notification, retrying with exponential backoff
+func (wd *webhookDispatcher) deliver(ctx context.Context, notification expiryNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err = wd.post(ctx, notification.url, body)
+		if err == nil || attempt >= wd.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends a single webhook request
+func (wd *webhookDispatcher) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wd.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxWriteBehindBackoff caps the delay between retries of a failed flush
+const maxWriteBehindBackoff = 5 * time.Minute
+
+// dirtyEntry is a value waiting to be flushed to the write-behind backend
+type dirtyEntry struct {
+	value    interface{}
+	attempts int
+	nextTry  time.Time
+}
+
+// writeBehindQueue accumulates values modified since the last flush. Repeated
+// writes to the same key are coalesced so only the latest value is flushed.
+type writeBehindQueue struct {
+	mutex    sync.Mutex
+	dirty    map[string]*dirtyEntry
+	fn       func(key string, value interface{}) error
+	interval time.Duration
+}
+
+// newWriteBehindQueue creates a queue that flushes through fn
+func newWriteBehindQueue(fn func(key string, value interface{}) error, interval time.Duration) *writeBehindQueue {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &writeBehindQueue{
+		dirty:    make(map[string]*dirtyEntry),
+		fn:       fn,
+		interval: interval,
+	}
+}
+
+// MarkDirty records the latest value of a key for the next flush
+func (q *writeBehindQueue) MarkDirty(key string, value interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.dirty[key] = &dirtyEntry{value: value}
+}
+
+// Pending returns the number of entries waiting to be flushed
+func (q *writeBehindQueue) Pending() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.dirty)
+}
+
+// Flush writes every due entry to the backend. The backend is called without
+// holding the queue lock, so Sets are never blocked by a slow flush. Failed
+// entries are retried with exponential backoff.
+func (q *writeBehindQueue) Flush() {
+	now := time.Now()
+	due := make(map[string]*dirtyEntry)
+
+	q.mutex.Lock()
+	for key, entry := range q.dirty {
+		if !entry.nextTry.After(now) {
+			due[key] = entry
+		}
+	}
+	q.mutex.Unlock()
+
+	for key, entry := range due {
+		err := q.fn(key, entry.value)
+
+		q.mutex.Lock()
+		// Skip bookkeeping if the key was written again during the flush
+		if q.dirty[key] == entry {
+			if err == nil {
+				delete(q.dirty, key)
+			} else {
+				entry.attempts++
+				backoff := q.interval << uint(entry.attempts)
+				if backoff <= 0 || backoff > maxWriteBehindBackoff {
+					backoff = maxWriteBehindBackoff
+				}
+				entry.nextTry = time.Now().Add(backoff)
+			}
+		}
+		q.mutex.Unlock()
+
+		if err != nil {
+			log.Printf("write-behind flush for %s failed (attempt %d): %v", key, entry.attempts, err)
+		}
+	}
+}
+
+// run flushes the queue every interval until ctx is done, then flushes
+// once more so writes accepted before shutdown are not lost
+func (q *writeBehindQueue) run(ctx context.Context) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			q.Flush()
+			return
+		case <-ticker.C:
+			q.Flush()
+		}
+	}
+}
+
+func (dc *DistroCache) handleWriteBehindPending(w http.ResponseWriter, r *http.Request) {
+	pending := 0
+	if dc.writeBehind != nil {
+		pending = dc.writeBehind.Pending()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": dc.writeBehind != nil,
+		"pending": pending,
+	})
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newWriteBehindCache(t testing.TB, backend *recordingBackend, interval time.Duration) *DistroCache {
+	return newTestCache(t, func(config *CacheConfig) {
+		config.WriteBehind = true
+		config.WriteBehindInterval = interval
+		config.WriteBehindFn = backend.write
+	})
+}
+
+func TestWriteBehindFlushesAfterSetReturns(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	backend := newRecordingBackend()
+	dc := newWriteBehindCache(t, backend, interval)
+
+	start := time.Now()
+	if err := dc.Set(context.Background(), "user:1", "alice", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > interval/2 {
+		t.Errorf("Set took %v, want it to return without waiting for a flush", elapsed)
+	}
+	if got := backend.count(); got != 0 {
+		t.Errorf("backend written %d times before the flush, want 0", got)
+	}
+
+	deadline := time.Now().Add(2 * interval)
+	for backend.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if value, found := backend.value("user:1"); !found || value != "alice" {
+		t.Fatalf("backend value = %v, %v after 2 intervals; want alice", value, found)
+	}
+}
+
+func TestWriteBehindRetriesFailedFlushes(t *testing.T) {
+	backend := newRecordingBackend()
+	backend.reject["user:1"] = true
+	dc := newWriteBehindCache(t, backend, time.Hour)
+
+	if err := dc.Set(context.Background(), "user:1", "alice", time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dc.writeBehind.Flush()
+	var pending struct {
+		Enabled bool `json:"enabled"`
+		Pending int  `json:"pending"`
+	}
+	body := mustServe(t, dc, http.StatusOK, "GET", "/api/v1/admin/write-behind/pending", nil)
+	if err := json.Unmarshal(body, &pending); err != nil {
+		t.Fatal(err)
+	}
+	if !pending.Enabled || pending.Pending != 1 {
+		t.Errorf("pending after a failed flush = %+v, want 1 entry", pending)
+	}
+
+	// The retry waits out its backoff before it is due again
+	backend.mutex.Lock()
+	backend.reject["user:1"] = false
+	backend.mutex.Unlock()
+	dc.writeBehind.Flush()
+	if got := dc.writeBehind.Pending(); got != 1 {
+		t.Errorf("pending after a flush inside the backoff = %d, want 1", got)
+	}
+
+	dc.writeBehind.mutex.Lock()
+	dc.writeBehind.dirty["user:1"].nextTry = time.Time{}
+	dc.writeBehind.mutex.Unlock()
+	dc.writeBehind.Flush()
+	if got := dc.writeBehind.Pending(); got != 0 {
+		t.Errorf("pending after the retry = %d, want 0", got)
+	}
+	if _, found := backend.value("user:1"); !found {
+		t.Error("retried entry never reached the backend")
+	}
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingBackend is a WriteThroughFn that records every write it accepts
+// and fails those for keys in reject
+type recordingBackend struct {
+	mutex  sync.Mutex
+	writes []string
+	values map[string]interface{}
+	reject map[string]bool
+}
+
+func newRecordingBackend() *recordingBackend {
+	return &recordingBackend{values: make(map[string]interface{}), reject: make(map[string]bool)}
+}
+
+func (b *recordingBackend) write(key string, value interface{}) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.reject[key] {
+		return errors.New("backend unavailable")
+	}
+	b.writes = append(b.writes, key)
+	b.values[key] = value
+	return nil
+}
+
+func (b *recordingBackend) count() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.writes)
+}
+
+func (b *recordingBackend) value(key string) (interface{}, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	value, found := b.values[key]
+	return value, found
+}
+
+func newWriteThroughCache(t testing.TB, backend *recordingBackend) *DistroCache {
+	return newTestCache(t, func(config *CacheConfig) {
+		config.WriteThrough = true
+		config.WriteThroughFn = backend.write
+	})
+}
+
+func TestWriteThroughCalledOnEverySet(t *testing.T) {
+	backend := newRecordingBackend()
+	dc := newWriteThroughCache(t, backend)
+	ctx := context.Background()
+
+	for i, key := range []string{"a", "b", "a"} {
+		if err := dc.Set(ctx, key, i, time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := backend.count(); got != 3 {
+		t.Errorf("backend saw %d writes, want 3", got)
+	}
+	if value, _ := backend.value("a"); value != 2 {
+		t.Errorf("backend holds a=%v, want 2", value)
+	}
+}
+
+func TestWriteThroughErrorKeepsItemOut(t *testing.T) {
+	backend := newRecordingBackend()
+	backend.reject["down"] = true
+	dc := newWriteThroughCache(t, backend)
+	ctx := context.Background()
+
+	err := dc.Set(ctx, "down", "value", time.Minute, nil)
+	if !errors.Is(err, ErrWriteThrough) {
+		t.Fatalf("Set error = %v, want ErrWriteThrough", err)
+	}
+	if _, found := dc.Peek(ctx, "down"); found {
+		t.Error("item cached although the backend rejected it")
+	}
+
+	code, _ := serve(t, dc, "POST", "/api/v1/cache/down", map[string]interface{}{"value": 1})
+	if code != 502 {
+		t.Errorf("HTTP set status %d, want 502", code)
+	}
+}
+
+func TestWriteThroughSkipsRejectedWrites(t *testing.T) {
+	backend := newRecordingBackend()
+	dc := newWriteThroughCache(t, backend)
+	ctx := context.Background()
+
+	if err := dc.SetWithOptions(ctx, "doc", "v5", time.Minute, nil, SetOptions{Version: 5}); err != nil {
+		t.Fatal(err)
+	}
+	err := dc.SetWithOptions(ctx, "doc", "v3", time.Minute, nil, SetOptions{Version: 3})
+	if !errors.Is(err, ErrStaleVersion) {
+		t.Fatalf("stale Set error = %v, want ErrStaleVersion", err)
+	}
+
+	token, acquired, err := dc.AcquireLock(ctx, "job", time.Minute, "first")
+	if err != nil || !acquired {
+		t.Fatalf("AcquireLock: acquired=%v err=%v", acquired, err)
+	}
+	if err := dc.ReleaseSemaphore("job", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dc.AcquireLock(ctx, "job", time.Minute, "second"); err != nil {
+		t.Fatal(err)
+	}
+	fenced := WithFencingToken(ctx, "job", token)
+	if err := dc.Set(fenced, "doc", "fenced", time.Minute, nil); !errors.Is(err, ErrStaleFencingToken) {
+		t.Fatalf("fenced Set error = %v, want ErrStaleFencingToken", err)
+	}
+
+	if got := backend.count(); got != 1 {
+		t.Errorf("backend saw %d writes, want only the accepted one", got)
+	}
+	if value, _ := backend.value("doc"); value != "v5" {
+		t.Errorf("backend holds doc=%v, want v5", value)
+	}
+}
+
+func TestWriteThroughCoversIncrementAndMergePatch(t *testing.T) {
+	backend := newRecordingBackend()
+	dc := newWriteThroughCache(t, backend)
+	ctx := context.Background()
+
+	if _, err := dc.Increment(ctx, "hits", 2); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := backend.value("hits"); value != int64(2) {
+		t.Errorf("backend holds hits=%v, want 2", value)
+	}
+
+	if err := dc.Set(ctx, "user", map[string]interface{}{"name": "a"}, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dc.MergePatch(ctx, "user", map[string]interface{}{"age": 30}); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := backend.value("user")
+	if object, ok := value.(map[string]interface{}); !ok || object["age"] != 30 {
+		t.Errorf("backend holds user=%v, want the merged object", value)
+	}
+
+	backend.reject["hits"] = true
+	if _, err := dc.Increment(ctx, "hits", 1); !errors.Is(err, ErrWriteThrough) {
+		t.Fatalf("Increment error = %v, want ErrWriteThrough", err)
+	}
+	if item, _ := dc.Peek(ctx, "hits"); item.Value != int64(2) {
+		t.Errorf("cached hits=%v after a failed write-through, want 2", item.Value)
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	for _, mode := range []string{"memory-only", "write-through"} {
+		b.Run(mode, func(b *testing.B) {
+			var dc *DistroCache
+			if mode == "write-through" {
+				dc = newWriteThroughCache(b, newRecordingBackend())
+			} else {
+				dc = newTestCache(b, nil)
+			}
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := dc.Set(ctx, "key", i, time.Minute, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
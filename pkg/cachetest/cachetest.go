@@ -0,0 +1,56 @@
+// Package cachetest runs an in-process DistroCache server for testing code
+// that talks to the cache through a client.CacheClient, so such tests need no
+// cache server running on localhost:8080.
+package cachetest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1n1nth/DistroCache/pkg/cache"
+	"github.com/1n1nth/DistroCache/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StartTestServer starts a cache built from config, nil for
+// cache.DefaultConfig(), behind an httptest server on a free loopback port,
+// and returns a client pointed at it. The server, cache and client are shut
+// down when the test finishes.
+func StartTestServer(t testing.TB, config *cache.CacheConfig) *client.CacheClient {
+	t.Helper()
+
+	_, c := StartTestCache(t, config)
+	return c
+}
+
+// StartTestCache is StartTestServer that also returns the cache itself, for
+// tests that seed or inspect it directly rather than over HTTP
+func StartTestCache(t testing.TB, config *cache.CacheConfig) (*cache.DistroCache, *client.CacheClient) {
+	t.Helper()
+
+	if config == nil {
+		config = cache.DefaultConfig()
+	} else {
+		copied := *config
+		config = &copied
+	}
+	// Each cache registers its metrics, which would collide in the default
+	// registry as soon as a second test server started
+	if config.Registry == nil {
+		config.Registry = prometheus.NewRegistry()
+	}
+
+	dc := cache.NewDistroCache(config)
+	server := httptest.NewServer(dc.Router())
+	c := client.NewCacheClient(server.URL)
+
+	t.Cleanup(func() {
+		c.Close()
+		dc.CloseStreams()
+		server.Close()
+		if err := dc.Close(); err != nil {
+			t.Errorf("closing test cache: %v", err)
+		}
+	})
+	return dc, c
+}
@@ -0,0 +1,61 @@
+package cachetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1n1nth/DistroCache/pkg/cache"
+	"github.com/1n1nth/DistroCache/pkg/cachetest"
+	"github.com/1n1nth/DistroCache/pkg/client"
+)
+
+func TestStartTestServer(t *testing.T) {
+	first := cachetest.StartTestServer(t, nil)
+	second := cachetest.StartTestServer(t, nil)
+
+	if err := first.Set("user:1", "alice", 60, nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := first.Get("user:1"); err != nil || value != "alice" {
+		t.Errorf("Get = %v, %v; want alice", value, err)
+	}
+	if _, err := second.Get("user:1"); !errors.Is(err, client.ErrKeyNotFound) {
+		t.Errorf("Get from a second test server error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestStartTestCacheUsesConfig(t *testing.T) {
+	config := cache.DefaultConfig()
+	config.MaxSize = 1
+	dc, c := cachetest.StartTestCache(t, config)
+
+	if err := dc.Set(context.Background(), "a", 1, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("b", 2, 60, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := dc.Len(); got != 1 {
+		t.Errorf("cache holds %d items with MaxSize 1", got)
+	}
+	if config.Registry != nil {
+		t.Error("StartTestCache modified the caller's config")
+	}
+}
+
+func TestTestServerStopsWithTest(t *testing.T) {
+	var url string
+	t.Run("server", func(t *testing.T) {
+		c := cachetest.StartTestServer(t, nil)
+		url = c.BaseURL
+		if err := c.Ping(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err := client.NewCacheClient(url).Ping(context.Background()); err == nil {
+		t.Errorf("test server at %s still answering after its test ended", url)
+	}
+}
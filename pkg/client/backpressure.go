@@ -0,0 +1,48 @@
+package client
+
+import (
+	"log"
+	"net/http"
+	"sync"
+)
+
+// backpressureTransport logs a warning when a cache server reports that it
+// is nearly full through the X-Cache-Backpressure header. It warns once when
+// a server enters backpressure and again when it leaves, rather than on
+// every response.
+type backpressureTransport struct {
+	base     http.RoundTripper
+	mutex    sync.Mutex
+	pressure map[string]bool
+}
+
+// newBackpressureTransport wraps base, or http.DefaultTransport when nil
+func newBackpressureTransport(base http.RoundTripper) *backpressureTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &backpressureTransport{base: base, pressure: make(map[string]bool)}
+}
+
+func (t *backpressureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	host := req.URL.Host
+	active := resp.Header.Get("X-Cache-Backpressure") == "true"
+
+	t.mutex.Lock()
+	changed := t.pressure[host] != active
+	t.pressure[host] = active
+	t.mutex.Unlock()
+
+	if changed && active {
+		log.Printf("warning: cache server %s is under backpressure (load %s), evictions are imminent",
+			host, resp.Header.Get("X-Cache-Load"))
+	} else if changed {
+		log.Printf("cache server %s is no longer under backpressure", host)
+	}
+	return resp, nil
+}
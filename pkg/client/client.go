@@ -0,0 +1,893 @@
+// Package client is a Go client for DistroCache servers. It supports single
+// servers and replicated sets of nodes with tunable consistency, and can
+// fall back to an in-process cache while the servers are unreachable.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Consistency levels supported by CacheClient
+const (
+	ConsistencyOne    = "one"
+	ConsistencyQuorum = "quorum"
+	ConsistencyAll    = "all"
+	// ConsistencyAsync is a write consistency only: sets return without
+	// waiting for any node to acknowledge
+	ConsistencyAsync = "async"
+)
+
+// CacheClientConfig holds configuration for a CacheClient
+type CacheClientConfig struct {
+	// Nodes lists the base URLs of the cache servers holding copies of each key
+	Nodes []string
+	// ConsistencyLevel is one of "one", "quorum" or "all"
+	ConsistencyLevel string
+	// WriteConsistency is how many nodes must acknowledge a set: "async",
+	// "one", "quorum" or "all". Empty uses ConsistencyLevel.
+	WriteConsistency string
+	// WriteTimeout bounds how long a replicated set waits for
+	// acknowledgements; 0 waits until every required node answers or fails
+	WriteTimeout time.Duration
+	Timeout      time.Duration
+	// HTTPClient is used for all requests instead of a default client, e.g.
+	// to supply TLS settings or a custom transport. It is copied, so the
+	// caller's client is not modified, and Timeout is ignored when it is set.
+	HTTPClient *http.Client
+	// FallbackLocal serves reads and writes from an in-process LRU while the
+	// servers are unreachable, and replays the writes once they return
+	FallbackLocal bool
+	// FallbackSize caps the number of values held by the fallback
+	FallbackSize int
+	// FallbackRetryInterval is how often the servers are retried in fallback mode
+	FallbackRetryInterval time.Duration
+	// ReadYourWrites sends reads of keys this client wrote to the node that
+	// accepted the write, until all nodes acknowledge it or
+	// ReadYourWritesWindow elapses
+	ReadYourWrites       bool
+	ReadYourWritesWindow time.Duration
+}
+
+// CacheClient handles communication with DistroCache. It is safe for
+// concurrent use.
+type CacheClient struct {
+	BaseURL          string
+	Client           *http.Client
+	Nodes            []string
+	ConsistencyLevel string
+	WriteConsistency string
+	writeTimeout     time.Duration
+	fallback         *localFallback
+	session          *writeSession
+	closed           atomic.Bool
+}
+
+// NewCacheClient creates a new cache client
+func NewCacheClient(baseURL string) *CacheClient {
+	return &CacheClient{
+		BaseURL:          baseURL,
+		Client:           &http.Client{Timeout: 5 * time.Second, Transport: newBackpressureTransport(nil)},
+		Nodes:            []string{baseURL},
+		ConsistencyLevel: ConsistencyOne,
+	}
+}
+
+// NewCacheClientWithConfig creates a cache client for a set of nodes
+func NewCacheClientWithConfig(config CacheClientConfig) (*CacheClient, error) {
+	if len(config.Nodes) == 0 {
+		return nil, fmt.Errorf("at least one node is required")
+	}
+
+	switch config.ConsistencyLevel {
+	case "":
+		config.ConsistencyLevel = ConsistencyOne
+	case ConsistencyOne, ConsistencyQuorum, ConsistencyAll:
+	default:
+		return nil, fmt.Errorf("invalid consistency level %q", config.ConsistencyLevel)
+	}
+
+	switch config.WriteConsistency {
+	case "", ConsistencyAsync, ConsistencyOne, ConsistencyQuorum, ConsistencyAll:
+	default:
+		return nil, fmt.Errorf("invalid write consistency %q", config.WriteConsistency)
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	httpClient := &http.Client{Timeout: config.Timeout}
+	if config.HTTPClient != nil {
+		copied := *config.HTTPClient
+		httpClient = &copied
+	}
+	httpClient.Transport = newBackpressureTransport(httpClient.Transport)
+
+	client := &CacheClient{
+		BaseURL:          config.Nodes[0],
+		Client:           httpClient,
+		Nodes:            config.Nodes,
+		ConsistencyLevel: config.ConsistencyLevel,
+		WriteConsistency: config.WriteConsistency,
+		writeTimeout:     config.WriteTimeout,
+	}
+
+	if config.FallbackLocal {
+		if config.FallbackSize <= 0 {
+			config.FallbackSize = 1000
+		}
+		if config.FallbackRetryInterval <= 0 {
+			config.FallbackRetryInterval = 5 * time.Second
+		}
+		client.fallback = newLocalFallback(config.FallbackSize, config.FallbackRetryInterval)
+	}
+
+	if config.ReadYourWrites && len(config.Nodes) > 1 {
+		if config.ReadYourWritesWindow <= 0 {
+			config.ReadYourWritesWindow = 5 * time.Second
+		}
+		client.session = newWriteSession(config.ReadYourWritesWindow)
+	}
+
+	return client, nil
+}
+
+// Close releases idle connections. Calls made after Close fail with ErrClosed.
+func (c *CacheClient) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	c.Client.CloseIdleConnections()
+	return nil
+}
+
+// do sends a request unless the client is closed
+func (c *CacheClient) do(req *http.Request) (*http.Response, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+	return c.Client.Do(req)
+}
+
+// post sends a JSON body to path on node
+func (c *CacheClient) post(node, path string, body interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", node+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req)
+}
+
+// keyPath returns the path addressing key, with optional query parameters.
+// The key is escaped; keys containing a slash, and the dot segments, cannot
+// be matched by /cache/{key} and are sent as /cache?key= instead.
+func keyPath(key string, query url.Values) string {
+	if strings.Contains(key, "/") || key == "." || key == ".." {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("key", key)
+		return "/api/v1/cache?" + query.Encode()
+	}
+
+	path := "/api/v1/cache/" + url.PathEscape(key)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path
+}
+
+// InFallback reports whether the client is serving from its local fallback
+// because the cache servers are unreachable
+func (c *CacheClient) InFallback() bool {
+	return c.fallback != nil && c.fallback.Active()
+}
+
+// resync replays writes made in fallback mode once the servers respond again
+func (c *CacheClient) resync() {
+	for _, entry := range c.fallback.recover() {
+		if err := c.setRemote(entry.key, entry.value, entry.ttl, entry.tags); err != nil {
+			log.Printf("re-sync of %s failed: %v", entry.key, err)
+		}
+	}
+}
+
+// requiredAcks returns how many nodes must respond to satisfy the consistency level.
+// Quorum is a strict majority of the nodes, N/2+1.
+func (c *CacheClient) requiredAcks() int {
+	return c.acksFor(c.ConsistencyLevel)
+}
+
+// writeRequiredAcks returns how many nodes must acknowledge a set
+func (c *CacheClient) writeRequiredAcks() int {
+	if c.WriteConsistency == "" {
+		return c.requiredAcks()
+	}
+	return c.acksFor(c.WriteConsistency)
+}
+
+// acksFor returns how many of the nodes level requires
+func (c *CacheClient) acksFor(level string) int {
+	switch level {
+	case ConsistencyAsync:
+		return 0
+	case ConsistencyQuorum:
+		return len(c.Nodes)/2 + 1
+	case ConsistencyAll:
+		return len(c.Nodes)
+	default:
+		return 1
+	}
+}
+
+// versionedValue is a value read from a single node along with its version
+type versionedValue struct {
+	Value   interface{} `json:"value"`
+	Version int64       `json:"version"`
+}
+
+// Get retrieves a value from cache. With FallbackLocal, connection errors
+// are answered from the local fallback instead.
+func (c *CacheClient) Get(key string) (interface{}, error) {
+	if c.fallback == nil {
+		return c.getRemote(key)
+	}
+
+	if c.fallback.skipRemote() {
+		return c.getFallback(key)
+	}
+
+	value, err := c.getRemote(key)
+	if isConnectionError(err) {
+		c.fallback.fail(err)
+		return c.getFallback(key)
+	}
+	c.resync()
+
+	switch {
+	case err == nil:
+		c.fallback.set(key, value, 0, nil, false)
+	case errors.Is(err, ErrKeyNotFound):
+		c.fallback.remove(key)
+	}
+	return value, err
+}
+
+// getFallback reads a key from the local fallback
+func (c *CacheClient) getFallback(key string) (interface{}, error) {
+	if value, found := c.fallback.get(key); found {
+		return value, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// getRemote reads a key from the cache servers
+func (c *CacheClient) getRemote(key string) (interface{}, error) {
+	if len(c.Nodes) > 1 {
+		if value, found := c.getSession(key); found {
+			return value, nil
+		}
+		return c.GetQuorum(key)
+	}
+
+	result, err := c.getFromNode(c.BaseURL, key)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// getSession reads a key this client wrote recently from the node that
+// accepted the write. It reports false when the key has no pending write or
+// the node no longer holds that write, so the caller falls back to the
+// configured consistency level.
+func (c *CacheClient) getSession(key string) (interface{}, bool) {
+	if c.session == nil {
+		return nil, false
+	}
+
+	write, found := c.session.lookup(key)
+	if !found {
+		return nil, false
+	}
+
+	result, err := c.getFromNode(write.node, key)
+	if err != nil || result.Version < write.version {
+		return nil, false
+	}
+	return result.Value, true
+}
+
+// getFromNode reads a key from a single node
+func (c *CacheClient) getFromNode(node, key string) (*versionedValue, error) {
+	return c.getWithHeaders(node, key, nil)
+}
+
+// getWithHeaders reads a key from a single node, sending extra request headers
+func (c *CacheClient) getWithHeaders(node, key string, headers map[string]string) (*versionedValue, error) {
+	req, err := http.NewRequest("GET", node+keyPath(key, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("get", node, resp)
+	}
+
+	var result versionedValue
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetConditional retrieves a value only if it was cached from the upstream
+// resource version identified by etag. If the cached entry was built from a
+// different version, the server evicts it and ErrKeyNotFound is returned.
+func (c *CacheClient) GetConditional(key, etag string) (interface{}, error) {
+	result, err := c.getWithHeaders(c.BaseURL, key, map[string]string{"X-Upstream-ETag": etag})
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// GetRaw retrieves only the JSON encoding of a value from BaseURL, skipping
+// the item envelope, for decoding into a caller's own type
+func (c *CacheClient) GetRaw(key string) (json.RawMessage, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+keyPath(key, url.Values{"raw": {"true"}}), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("get", c.BaseURL, resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(bytes.TrimSpace(body)), nil
+}
+
+// GetStream retrieves the JSON encoding of a value from BaseURL as the server
+// streams it, so a large value can be decoded or copied without buffering
+// the whole response. The caller must close the returned reader; the
+// client's Timeout covers reading it to the end.
+func (c *CacheClient) GetStream(key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+keyPath(key, url.Values{"stream": {"true"}}), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newStatusError("get", c.BaseURL, resp)
+	}
+	return resp.Body, nil
+}
+
+// GetQuorum reads a key from the nodes required by the consistency level
+// and returns the value with the highest version
+func (c *CacheClient) GetQuorum(key string) (interface{}, error) {
+	type readResult struct {
+		value *versionedValue
+		err   error
+	}
+
+	results := make(chan readResult, len(c.Nodes))
+	for _, node := range c.Nodes {
+		go func(node string) {
+			value, err := c.getFromNode(node, key)
+			results <- readResult{value: value, err: err}
+		}(node)
+	}
+
+	required := c.requiredAcks()
+	responses := 0
+	var latest *versionedValue
+	var lastErr error
+
+	for i := 0; i < len(c.Nodes) && responses < required; i++ {
+		result := <-results
+		if result.err != nil {
+			if !errors.Is(result.err, ErrKeyNotFound) {
+				lastErr = result.err
+				continue
+			}
+			responses++
+			continue
+		}
+
+		responses++
+		if latest == nil || result.value.Version > latest.Version {
+			latest = result.value
+		}
+	}
+
+	if responses < required {
+		return nil, fmt.Errorf("read %w: %d/%d nodes responded: %w", ErrQuorumNotReached, responses, required, lastErr)
+	}
+	if latest == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return latest.Value, nil
+}
+
+// Set stores a value in cache. With FallbackLocal, connection errors store
+// the value locally; it is written to the server once it is reachable again.
+func (c *CacheClient) Set(key string, value interface{}, ttl int, tags []string) error {
+	if c.fallback == nil {
+		return c.setRemote(key, value, ttl, tags)
+	}
+
+	if c.fallback.skipRemote() {
+		c.fallback.set(key, value, ttl, tags, true)
+		return nil
+	}
+
+	err := c.setRemote(key, value, ttl, tags)
+	if isConnectionError(err) {
+		c.fallback.fail(err)
+		c.fallback.set(key, value, ttl, tags, true)
+		return nil
+	}
+	c.resync()
+
+	if err == nil {
+		c.fallback.set(key, value, ttl, tags, false)
+	}
+	return err
+}
+
+// setRemote writes a value to the cache servers
+func (c *CacheClient) setRemote(key string, value interface{}, ttl int, tags []string) error {
+	if len(c.Nodes) > 1 {
+		return c.SetQuorum(key, value, ttl, tags)
+	}
+
+	reqBody := map[string]interface{}{
+		"value": value,
+		"ttl":   ttl,
+		"tags":  tags,
+	}
+
+	return c.setOnNode(c.BaseURL, key, reqBody)
+}
+
+// SetWithETag stores a value along with the ETag of the upstream resource it
+// was built from, for use with GetConditional
+func (c *CacheClient) SetWithETag(key string, value interface{}, ttl int, tags []string, etag string) error {
+	reqBody := map[string]interface{}{
+		"value":         value,
+		"ttl":           ttl,
+		"tags":          tags,
+		"external_etag": etag,
+	}
+
+	if err := c.setOnNode(c.BaseURL, key, reqBody); err != nil {
+		return err
+	}
+	if c.session != nil {
+		// Only BaseURL holds the value, so reads stick to it for the window
+		c.session.record(key, c.BaseURL, 0)
+	}
+	return nil
+}
+
+// setOnNode writes a key to a single node
+func (c *CacheClient) setOnNode(node, key string, reqBody map[string]interface{}) error {
+	resp, err := c.post(node, keyPath(key, nil), reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return newStatusError("set", node, resp)
+	}
+
+	return nil
+}
+
+// WriteResult reports how many nodes acknowledged a set
+type WriteResult struct {
+	// Acked counts the nodes that acknowledged before the set returned;
+	// nodes answering later are not counted
+	Acked int
+	// Required is the number of acknowledgements the write consistency needs
+	Required int
+	// Nodes is the number of nodes the set was sent to
+	Nodes int
+}
+
+// SetWithAcks stores a value like Set, bypassing the local fallback, and
+// reports how many nodes acknowledged it. With several nodes it fails with
+// ErrQuorumNotReached when fewer than WriteConsistency requires acknowledge
+// within WriteTimeout; the result still carries the achieved count.
+func (c *CacheClient) SetWithAcks(key string, value interface{}, ttl int, tags []string) (WriteResult, error) {
+	if len(c.Nodes) > 1 {
+		return c.setReplicated(key, value, ttl, tags)
+	}
+
+	result := WriteResult{Required: 1, Nodes: 1}
+	err := c.setOnNode(c.BaseURL, key, map[string]interface{}{
+		"value": value,
+		"ttl":   ttl,
+		"tags":  tags,
+	})
+	if err == nil {
+		result.Acked = 1
+	}
+	return result, err
+}
+
+// SetQuorum writes a value to every node and waits until the number of
+// nodes required by the write consistency have acknowledged the write.
+// All nodes receive the same version so reads can resolve the newest value.
+func (c *CacheClient) SetQuorum(key string, value interface{}, ttl int, tags []string) error {
+	_, err := c.setReplicated(key, value, ttl, tags)
+	return err
+}
+
+// setReplicated writes a value to every node and waits for the
+// acknowledgements WriteConsistency requires, up to WriteTimeout
+func (c *CacheClient) setReplicated(key string, value interface{}, ttl int, tags []string) (WriteResult, error) {
+	version := time.Now().UnixNano()
+	reqBody := map[string]interface{}{
+		"value":   value,
+		"ttl":     ttl,
+		"tags":    tags,
+		"version": version,
+	}
+
+	type ack struct {
+		node string
+		err  error
+	}
+
+	acks := make(chan ack, len(c.Nodes))
+	for _, node := range c.Nodes {
+		go func(node string) {
+			acks <- ack{node: node, err: c.setOnNode(node, key, reqBody)}
+		}(node)
+	}
+
+	required := c.writeRequiredAcks()
+	acked := 0
+	received := 0
+	firstNode := ""
+	var lastErr error
+
+	var timeout <-chan time.Time
+	if c.writeTimeout > 0 {
+		timer := time.NewTimer(c.writeTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+wait:
+	for ; received < len(c.Nodes) && acked < required; received++ {
+		select {
+		case result := <-acks:
+			if result.err != nil {
+				lastErr = result.err
+				continue
+			}
+			if acked == 0 {
+				firstNode = result.node
+			}
+			acked++
+		case <-timeout:
+			lastErr = fmt.Errorf("no acknowledgement within %s", c.writeTimeout)
+			break wait
+		}
+	}
+
+	written := WriteResult{Acked: acked, Required: required, Nodes: len(c.Nodes)}
+	if acked < required {
+		return written, fmt.Errorf("write %w: %d/%d nodes acknowledged: %w", ErrQuorumNotReached, acked, required, lastErr)
+	}
+
+	// Async writes have no acknowledged node to read back from
+	if c.session != nil && acked > 0 && acked < len(c.Nodes) {
+		c.session.record(key, firstNode, version)
+		if acked < received {
+			// A node rejected the write; stick to firstNode for the window
+			return written, nil
+		}
+		go func(pending int) {
+			for ; pending > 0; pending-- {
+				if result := <-acks; result.err != nil {
+					return
+				}
+			}
+			c.session.confirm(key, version)
+		}(len(c.Nodes) - received)
+	}
+
+	return written, nil
+}
+
+// Delete removes a key from the cache servers and the local fallback. With
+// several nodes the key is deleted from every node under one version, and
+// the number of nodes required by the consistency level must acknowledge.
+// ErrKeyNotFound is returned when no responding node held the key.
+func (c *CacheClient) Delete(key string) error {
+	if c.fallback != nil {
+		c.fallback.remove(key)
+	}
+
+	if len(c.Nodes) == 1 {
+		return c.deleteOnNode(c.BaseURL, key, 0)
+	}
+
+	version := time.Now().UnixNano()
+	results := make(chan error, len(c.Nodes))
+	for _, node := range c.Nodes {
+		go func(node string) {
+			results <- c.deleteOnNode(node, key, version)
+		}(node)
+	}
+
+	required := c.requiredAcks()
+	acked := 0
+	deleted := false
+	var lastErr error
+
+	for i := 0; i < len(c.Nodes) && acked < required; i++ {
+		err := <-results
+		switch {
+		case err == nil:
+			deleted = true
+		case !errors.Is(err, ErrKeyNotFound):
+			lastErr = err
+			continue
+		}
+		acked++
+	}
+
+	if acked < required {
+		return fmt.Errorf("delete %w: %d/%d nodes acknowledged: %w", ErrQuorumNotReached, acked, required, lastErr)
+	}
+	if !deleted {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// deleteOnNode deletes a key from a single node; a non-zero version leaves a
+// tombstone that rejects older writes
+func (c *CacheClient) deleteOnNode(node, key string, version int64) error {
+	var query url.Values
+	if version != 0 {
+		query = url.Values{"version": {strconv.FormatInt(version, 10)}}
+	}
+
+	req, err := http.NewRequest("DELETE", node+keyPath(key, query), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrKeyNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return newStatusError("delete", node, resp)
+	}
+	return nil
+}
+
+// DeleteMany removes several keys in one request per node and reports which
+// were deleted and which no responding node held. With several nodes the
+// keys are deleted from every node, and the number of nodes required by the
+// consistency level must acknowledge.
+func (c *CacheClient) DeleteMany(keys []string) (deleted []string, notFound []string, err error) {
+	if c.fallback != nil {
+		for _, key := range keys {
+			c.fallback.remove(key)
+		}
+	}
+
+	type batchResult struct {
+		deleted []string
+		err     error
+	}
+	results := make(chan batchResult, len(c.Nodes))
+	for _, node := range c.Nodes {
+		go func(node string) {
+			deleted, err := c.deleteManyOnNode(node, keys)
+			results <- batchResult{deleted: deleted, err: err}
+		}(node)
+	}
+
+	required := c.requiredAcks()
+	acked := 0
+	removed := make(map[string]bool)
+	var lastErr error
+
+	for i := 0; i < len(c.Nodes) && acked < required; i++ {
+		result := <-results
+		if result.err != nil {
+			lastErr = result.err
+			continue
+		}
+		for _, key := range result.deleted {
+			removed[key] = true
+		}
+		acked++
+	}
+
+	if acked < required {
+		if len(c.Nodes) == 1 {
+			return nil, nil, lastErr
+		}
+		return nil, nil, fmt.Errorf("delete %w: %d/%d nodes acknowledged: %w", ErrQuorumNotReached, acked, required, lastErr)
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if removed[key] {
+			deleted = append(deleted, key)
+		} else {
+			notFound = append(notFound, key)
+		}
+	}
+	return deleted, notFound, nil
+}
+
+// deleteManyOnNode deletes several keys from a single node and returns the
+// ones it held
+func (c *CacheClient) deleteManyOnNode(node string, keys []string) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("DELETE", node+"/api/v1/cache/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("delete", node, resp)
+	}
+
+	var result struct {
+		Deleted []string `json:"deleted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Deleted, nil
+}
+
+// InvalidateTag invalidates all cached items with a specific tag
+func (c *CacheClient) InvalidateTag(tag string) error {
+	req, err := http.NewRequest("POST", c.BaseURL+"/api/v1/invalidate/tag/"+url.PathEscape(tag), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return newStatusError("invalidate tag", c.BaseURL, resp)
+	}
+	return nil
+}
+
+// Stats returns the statistics reported by the server at BaseURL
+func (c *CacheClient) Stats() (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/api/v1/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("stats", c.BaseURL, resp)
+	}
+
+	var stats map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// RegisterLoader registers a read-through loader on the cache server. On a
+// miss for a key matching pattern, the server POSTs {"key": ...} to loaderURL
+// and caches the returned {"value", "ttl", "tags"}.
+func (c *CacheClient) RegisterLoader(pattern, loaderURL string) error {
+	resp, err := c.post(c.BaseURL, "/api/v1/admin/loaders", map[string]string{
+		"pattern": pattern,
+		"url":     loaderURL,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return newStatusError("register loader", c.BaseURL, resp)
+	}
+
+	return nil
+}
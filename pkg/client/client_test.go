@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/1n1nth/DistroCache/pkg/cache"
+	"github.com/1n1nth/DistroCache/pkg/cachetest"
+	"github.com/1n1nth/DistroCache/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testNode is an in-process cache server that can be made to lag, dropping
+// the writes it is sent
+type testNode struct {
+	cache   *cache.DistroCache
+	server  *httptest.Server
+	lagging atomic.Bool
+}
+
+func startNodes(t *testing.T, n int) []*testNode {
+	t.Helper()
+
+	nodes := make([]*testNode, n)
+	for i := range nodes {
+		config := cache.DefaultConfig()
+		config.Registry = prometheus.NewRegistry()
+		node := &testNode{cache: cache.NewDistroCache(config)}
+		router := node.cache.Router()
+		node.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if node.lagging.Load() && r.Method != http.MethodGet {
+				http.Error(w, "lagging", http.StatusServiceUnavailable)
+				return
+			}
+			router.ServeHTTP(w, r)
+		}))
+		t.Cleanup(func() {
+			node.server.Close()
+			node.cache.Close()
+		})
+		nodes[i] = node
+	}
+	return nodes
+}
+
+func urls(nodes []*testNode) []string {
+	urls := make([]string, len(nodes))
+	for i, node := range nodes {
+		urls[i] = node.server.URL
+	}
+	return urls
+}
+
+func TestQuorumReadSeesQuorumWrite(t *testing.T) {
+	nodes := startNodes(t, 3)
+	c, err := client.NewCacheClientWithConfig(client.CacheClientConfig{
+		Nodes:            urls(nodes),
+		ConsistencyLevel: client.ConsistencyQuorum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SetQuorum("config", "v1", 60, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The third node misses the update and keeps serving v1
+	nodes[2].lagging.Store(true)
+	if err := c.SetQuorum("config", "v2", 60, nil); err != nil {
+		t.Fatalf("quorum write with one node lagging: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		value, err := c.GetQuorum("config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "v2" {
+			t.Fatalf("quorum read = %v, want v2", value)
+		}
+	}
+}
+
+func TestQuorumWriteFailsWithoutMajority(t *testing.T) {
+	nodes := startNodes(t, 3)
+	c, err := client.NewCacheClientWithConfig(client.CacheClientConfig{
+		Nodes:            urls(nodes),
+		ConsistencyLevel: client.ConsistencyQuorum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	nodes[1].lagging.Store(true)
+	nodes[2].lagging.Store(true)
+	if err := c.SetQuorum("config", "v1", 60, nil); !errors.Is(err, client.ErrQuorumNotReached) {
+		t.Errorf("SetQuorum error = %v, want ErrQuorumNotReached", err)
+	}
+}
+
+func TestInvalidConsistencyLevel(t *testing.T) {
+	_, err := client.NewCacheClientWithConfig(client.CacheClientConfig{
+		Nodes:            []string{"http://localhost:8080"},
+		ConsistencyLevel: "most",
+	})
+	if err == nil {
+		t.Error("NewCacheClientWithConfig accepted an unknown consistency level")
+	}
+}
+
+func TestGetConditional(t *testing.T) {
+	dc, c := cachetest.StartTestCache(t, nil)
+
+	opts := cache.SetOptions{ExternalETag: `"v1"`}
+	if err := dc.SetWithOptions(context.Background(), "page", "<html>", time.Minute, nil, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := c.GetConditional("page", `"v1"`); err != nil || value != "<html>" {
+		t.Errorf("GetConditional with the current ETag = %v, %v; want the cached value", value, err)
+	}
+	if _, err := c.GetConditional("page", `"v2"`); !errors.Is(err, client.ErrKeyNotFound) {
+		t.Errorf("GetConditional with a changed ETag error = %v, want ErrKeyNotFound", err)
+	}
+	if _, err := c.Get("page"); !errors.Is(err, client.ErrKeyNotFound) {
+		t.Errorf("Get after an ETag mismatch error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestGetRaw(t *testing.T) {
+	dc, c := cachetest.StartTestCache(t, nil)
+
+	value := map[string]interface{}{"name": "alice"}
+	if err := dc.Set(context.Background(), "user", value, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := c.GetRaw("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil || got.Name != "alice" {
+		t.Errorf("GetRaw = %s, %v; want the stored value alone", raw, err)
+	}
+	if _, err := c.GetRaw("missing"); !errors.Is(err, client.ErrKeyNotFound) {
+		t.Errorf("GetRaw of a missing key error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	dc, c := cachetest.StartTestCache(t, nil)
+
+	for _, key := range []string{"k1", "k3"} {
+		if err := dc.Set(context.Background(), key, "v", time.Minute, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, notFound, err := c.DeleteMany([]string{"k1", "k2", "k3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"k1", "k3"}) || !reflect.DeepEqual(notFound, []string{"k2"}) {
+		t.Errorf("DeleteMany = %v, %v; want [k1 k3], [k2]", deleted, notFound)
+	}
+	if dc.Len() != 0 {
+		t.Errorf("%d items left after DeleteMany", dc.Len())
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	dc, c := cachetest.StartTestCache(t, nil)
+
+	value := strings.Repeat("0123456789", 200000)
+	if err := dc.Set(context.Background(), "blob", value, time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := c.GetStream("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := json.Marshal(value); !bytes.Equal(got, want) {
+		t.Errorf("GetStream returned %d bytes, want the %d-byte encoded value", len(got), len(want))
+	}
+
+	if _, err := c.GetStream("missing"); !errors.Is(err, client.ErrKeyNotFound) {
+		t.Errorf("GetStream of a missing key error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestKeysWithURLSpecialCharacters(t *testing.T) {
+	dc, c := cachetest.StartTestCache(t, nil)
+
+	for _, key := range []string{"users/42", "what?now", "page#top", "100%", "a b", ".."} {
+		if err := c.Set(key, key+" value", 60, nil); err != nil {
+			t.Fatalf("Set(%q): %v", key, err)
+		}
+		if _, found := dc.Peek(context.Background(), key); !found {
+			t.Errorf("Set(%q) stored the value under another key", key)
+		}
+
+		got, err := c.Get(key)
+		if err != nil || got != key+" value" {
+			t.Errorf("Get(%q) = %v, %v; want %q", key, got, err, key+" value")
+		}
+		if raw, err := c.GetRaw(key); err != nil || string(raw) != `"`+key+` value"` {
+			t.Errorf("GetRaw(%q) = %s, %v", key, raw, err)
+		}
+
+		if err := c.Delete(key); err != nil {
+			t.Errorf("Delete(%q): %v", key, err)
+		}
+		if _, found := dc.Peek(context.Background(), key); found {
+			t.Errorf("Delete(%q) left the value in place", key)
+		}
+	}
+}
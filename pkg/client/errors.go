@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrKeyNotFound is returned when the requested key is not cached
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrQuorumNotReached is returned when fewer nodes than the consistency
+	// level requires answered a read or acknowledged a write
+	ErrQuorumNotReached = errors.New("quorum not reached")
+
+	// ErrClosed is returned by calls made after Close
+	ErrClosed = errors.New("cache client is closed")
+)
+
+// StatusError is returned when a cache server answers with an unexpected
+// HTTP status
+type StatusError struct {
+	Op         string // operation that failed, e.g. "set"
+	Node       string // base URL of the server
+	StatusCode int
+	Message    string // response body, trimmed
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s on %s failed with status %d", e.Op, e.Node, e.StatusCode)
+	}
+	return fmt.Sprintf("%s on %s failed with status %d: %s", e.Op, e.Node, e.StatusCode, e.Message)
+}
+
+// newStatusError builds a StatusError from a response, reading at most 1KB
+// of its body as the message
+func newStatusError(op, node string, resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &StatusError{
+		Op:         op,
+		Node:       node,
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"container/list"
+	"errors"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// fallbackEntry is a value held by the local fallback
+type fallbackEntry struct {
+	key       string
+	value     interface{}
+	ttl       int
+	tags      []string
+	expiresAt time.Time
+	// dirty marks writes made while the server was unreachable, which are
+	// replayed to the server once it returns
+	dirty bool
+}
+
+// localFallback is a best-effort in-process LRU that CacheClient serves from
+// while the cache server is unreachable. It is not consulted while the
+// server is healthy, only kept warm with recent reads and writes.
+type localFallback struct {
+	mutex         sync.Mutex
+	capacity      int
+	order         *list.List
+	entries       map[string]*list.Element
+	active        bool
+	retryAt       time.Time
+	retryInterval time.Duration
+}
+
+// newLocalFallback creates a fallback holding up to capacity values
+func newLocalFallback(capacity int, retryInterval time.Duration) *localFallback {
+	return &localFallback{
+		capacity:      capacity,
+		order:         list.New(),
+		entries:       make(map[string]*list.Element),
+		retryInterval: retryInterval,
+	}
+}
+
+// isConnectionError reports whether err means the server could not be reached
+func isConnectionError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// Active reports whether the client is currently serving from the fallback
+func (f *localFallback) Active() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.active
+}
+
+// skipRemote reports whether the server is known to be down and should not
+// be retried yet
+func (f *localFallback) skipRemote() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.active && time.Now().Before(f.retryAt)
+}
+
+// fail switches to fallback mode after a connection error
+func (f *localFallback) fail(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.active {
+		log.Printf("cache server unreachable, serving from local fallback: %v", err)
+	}
+	f.active = true
+	f.retryAt = time.Now().Add(f.retryInterval)
+}
+
+// recover leaves fallback mode and returns the writes made while it was
+// active, so the caller can replay them to the server
+func (f *localFallback) recover() []fallbackEntry {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.active {
+		return nil
+	}
+	f.active = false
+
+	var dirty []fallbackEntry
+	for elem := f.order.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*fallbackEntry)
+		if entry.dirty && (entry.expiresAt.IsZero() || time.Now().Before(entry.expiresAt)) {
+			dirty = append(dirty, *entry)
+		}
+		entry.dirty = false
+	}
+	log.Printf("cache server is back, re-syncing %d local writes", len(dirty))
+	return dirty
+}
+
+// get returns a value from the fallback
+func (f *localFallback) get(key string) (interface{}, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	elem, exists := f.entries[key]
+	if !exists {
+		return nil, false
+	}
+
+	entry := elem.Value.(*fallbackEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		f.order.Remove(elem)
+		delete(f.entries, key)
+		return nil, false
+	}
+
+	f.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// set stores a value in the fallback, evicting the least recently used
+// value when full
+func (f *localFallback) set(key string, value interface{}, ttl int, tags []string, dirty bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	entry := &fallbackEntry{key: key, value: value, ttl: ttl, tags: tags, dirty: dirty}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+
+	if elem, exists := f.entries[key]; exists {
+		elem.Value = entry
+		f.order.MoveToFront(elem)
+		return
+	}
+
+	f.entries[key] = f.order.PushFront(entry)
+	for f.order.Len() > f.capacity {
+		oldest := f.order.Back()
+		f.order.Remove(oldest)
+		delete(f.entries, oldest.Value.(*fallbackEntry).key)
+	}
+}
+
+// remove drops a value from the fallback
+func (f *localFallback) remove(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if elem, exists := f.entries[key]; exists {
+		f.order.Remove(elem)
+		delete(f.entries, key)
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// NewCacheClientWithValidation creates a cache client like NewCacheClient,
+// but first checks that the server at baseURL is reachable and healthy
+func NewCacheClientWithValidation(baseURL string) (*CacheClient, error) {
+	client := NewCacheClient(baseURL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Ping checks the health endpoint of the server at BaseURL and returns an
+// error if it is unreachable or does not report itself healthy
+func (c *CacheClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/api/v1/health", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("ping", c.BaseURL, resp)
+	}
+
+	var health struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return err
+	}
+	if health.Status != "healthy" {
+		return fmt.Errorf("cache server %s is %q", c.BaseURL, health.Status)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1n1nth/DistroCache/pkg/cachetest"
+	"github.com/1n1nth/DistroCache/pkg/client"
+)
+
+func TestNewCacheClientWithValidation(t *testing.T) {
+	running := cachetest.StartTestServer(t, nil)
+	c, err := client.NewCacheClientWithValidation(running.BaseURL)
+	if err != nil {
+		t.Fatalf("validating a running server: %v", err)
+	}
+	c.Close()
+
+	// A server that has gone away
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+	if _, err := client.NewCacheClientWithValidation(gone.URL); err == nil {
+		t.Error("validating a server that is not running succeeded")
+	}
+
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"degraded"}`))
+	}))
+	t.Cleanup(unhealthy.Close)
+	if _, err := client.NewCacheClientWithValidation(unhealthy.URL); err == nil {
+		t.Error("validating a server reporting itself degraded succeeded")
+	}
+}
+
+func TestPing(t *testing.T) {
+	c := cachetest.StartTestServer(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.Ping(ctx); err != nil {
+		t.Errorf("Ping of a running server: %v", err)
+	}
+	cancel()
+	if err := c.Ping(ctx); err == nil {
+		t.Error("Ping with a cancelled context succeeded")
+	}
+}
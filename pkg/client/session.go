@@ -0,0 +1,62 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// sessionWrite records the node that accepted a write made by this client
+type sessionWrite struct {
+	node      string
+	version   int64
+	expiresAt time.Time
+}
+
+// writeSession routes reads of keys this client wrote recently to the node
+// that accepted the write, until every node has acknowledged it or the
+// staleness window elapses. This gives read-your-writes while replication
+// to the other nodes is still in flight.
+type writeSession struct {
+	mutex  sync.Mutex
+	window time.Duration
+	writes map[string]sessionWrite
+}
+
+func newWriteSession(window time.Duration) *writeSession {
+	return &writeSession{window: window, writes: make(map[string]sessionWrite)}
+}
+
+// record remembers that node accepted version of key
+func (s *writeSession) record(key, node string, version int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.writes[key] = sessionWrite{node: node, version: version, expiresAt: time.Now().Add(s.window)}
+}
+
+// confirm forgets a write once every node holds it, unless the key has been
+// written again since
+func (s *writeSession) confirm(key string, version int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if write, exists := s.writes[key]; exists && write.version == version {
+		delete(s.writes, key)
+	}
+}
+
+// lookup returns the unconfirmed write of key still inside the window
+func (s *writeSession) lookup(key string) (sessionWrite, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	write, exists := s.writes[key]
+	if !exists {
+		return sessionWrite{}, false
+	}
+	if time.Now().After(write.expiresAt) {
+		delete(s.writes, key)
+		return sessionWrite{}, false
+	}
+	return write, true
+}